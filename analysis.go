@@ -0,0 +1,75 @@
+package golox
+
+// Diagnostic is an error found in a program without running it. Token marks
+// the source the error is about.
+type Diagnostic struct {
+	Token   Token
+	Message string
+}
+
+// SymbolKind says what declared a Symbol.
+type SymbolKind int
+
+const (
+	VariableSymbol SymbolKind = iota
+	ParameterSymbol
+	FunctionSymbol
+	ClassSymbol
+	MethodSymbol
+)
+
+// Symbol is a name declared in a program.
+type Symbol struct {
+	// Name is the token that declares the symbol
+	Name Token
+	Kind SymbolKind
+	// Detail describes the declaration, like "fun add(a, b)"
+	Detail string
+	// Children are the declarations directly inside a function or class,
+	// except for parameters
+	Children []*Symbol
+}
+
+// Reference is a use of a variable, or an assignment to it. Symbol is nil
+// when the name isn't declared in the program, such as a native function.
+type Reference struct {
+	Token  Token
+	Symbol *Symbol
+}
+
+// Analysis is everything that can be learned about a program without
+// running it.
+type Analysis struct {
+	Tokens []Token
+	// Statements are the statements that parsed
+	Statements  []Stmt
+	Diagnostics []Diagnostic
+	// Symbols are the top level declarations, the rest hang off them as
+	// Children
+	Symbols    []*Symbol
+	References []Reference
+}
+
+// Analyze scans, parses and resolves source, collecting every error found
+// along the way instead of stopping at the first stage that fails.
+func Analyze(source string) *Analysis {
+	ignoreError := func(string) {}
+	s := scanner{source: source}
+	tokens, _ := s.scanTokens(ignoreError)
+	p := parser{tokens: tokens, displayError: ignoreError}
+	statements, _ := p.parseProgram()
+	r := newResolver(ignoreError)
+	r.resolve(statements)
+
+	var diagnostics []Diagnostic
+	diagnostics = append(diagnostics, s.diagnostics...)
+	diagnostics = append(diagnostics, p.diagnostics...)
+	diagnostics = append(diagnostics, r.diagnostics...)
+	return &Analysis{
+		Tokens:      tokens,
+		Statements:  statements,
+		Diagnostics: diagnostics,
+		Symbols:     r.symbols,
+		References:  r.references,
+	}
+}
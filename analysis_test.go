@@ -0,0 +1,81 @@
+package golox
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestResolverDiagnostics(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		// diagnostics are "line at 'lexeme': message" for each diagnostic
+		diagnostics []string
+	}{
+		{"clean", "var a = 1;\nfun f(b) { return a + b; }\nprint f(2);\n", nil},
+		{"return from top level", "return 1;\n", []string{"1 at 'return': Can't return from top-level code"}},
+		{"value returned from an initializer", "class A {\n  init() { return 1; }\n}\n", []string{"2 at 'return': Can't return a value from an initializer"}},
+		{"class inheriting from itself", "class A < A {}\n", []string{"1 at 'A': A class can't inherit from itself"}},
+		{"local read in its own initializer", "{\n  var a = a;\n}\n", []string{"2 at 'a': Can't read local variable in its own initializer"}},
+		{"this outside a class", "print this;\n", []string{"1 at 'this': Can't use 'this' outside of a class"}},
+		{"super outside a class", "print super.x;\n", []string{"1 at 'super': Can't use 'super' outside of a class"}},
+		{"super without a superclass", "class A {\n  f() { return super.f(); }\n}\n", []string{"2 at 'super': Can't use 'super' in a class with no superclass"}},
+		{"local declared twice", "{\n  var a = 1;\n  var a = 2;\n}\n", []string{"3 at 'a': Already a variable with this name in this scope"}},
+		{"global declared twice", "var a = 1;\nvar a = 2;\n", nil},
+		{
+			"errors from every stage",
+			"@\nprint ;\nreturn 1;\n",
+			[]string{"1 at '@': Unexpected character '@'", "2 at ';': Expected expression", "3 at 'return': Can't return from top-level code"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got []string
+			for _, d := range Analyze(test.source).Diagnostics {
+				got = append(got, fmt.Sprintf("%d at '%s': %s", d.Token.Line, d.Token.Lexeme, d.Message))
+			}
+			if !reflect.DeepEqual(got, test.diagnostics) {
+				t.Errorf("Reported %q, want %q", got, test.diagnostics)
+			}
+		})
+	}
+}
+
+func TestAnalyzeSymbols(t *testing.T) {
+	source := "var total = 0;\nfun add(a, b) {\n  var sum = a + b;\n  return sum;\n}\nclass Counter {\n  inc() { total = add(total, 1); }\n}\nprint clock();\n"
+	analysis := Analyze(source)
+	if len(analysis.Diagnostics) > 0 {
+		t.Fatal(analysis.Diagnostics)
+	}
+
+	var describe func(symbols []*Symbol, indent string) string
+	describe = func(symbols []*Symbol, indent string) string {
+		var s string
+		for _, symbol := range symbols {
+			s += fmt.Sprintf("%s%d:%s %d %s\n", indent, symbol.Name.Line, symbol.Name.Lexeme, symbol.Kind, symbol.Detail)
+			s += describe(symbol.Children, indent+"  ")
+		}
+		return s
+	}
+	want := fmt.Sprintf("1:total %d var total\n2:add %d fun add(a, b)\n  3:sum %d var sum\n6:Counter %d class Counter\n  7:inc %d Counter.inc()\n",
+		VariableSymbol, FunctionSymbol, VariableSymbol, ClassSymbol, MethodSymbol)
+	if got := describe(analysis.Symbols, ""); got != want {
+		t.Errorf("Declared\n%s\nwant\n%s", got, want)
+	}
+
+	// Each use points at the symbol that declares it, names the program
+	// doesn't declare point at nothing
+	var got []string
+	for _, ref := range analysis.References {
+		declared := "undeclared"
+		if ref.Symbol != nil {
+			declared = fmt.Sprintf("line %d", ref.Symbol.Name.Line)
+		}
+		got = append(got, fmt.Sprintf("%d:%s %s", ref.Token.Line, ref.Token.Lexeme, declared))
+	}
+	wantRefs := []string{"3:a line 2", "3:b line 2", "4:sum line 3", "7:add line 2", "7:total line 1", "7:total line 1", "9:clock undeclared"}
+	if !reflect.DeepEqual(got, wantRefs) {
+		t.Errorf("Referenced %q, want %q", got, wantRefs)
+	}
+}
@@ -0,0 +1,982 @@
+// Code generated by generate-ast from ast.txt. DO NOT EDIT.
+
+package golox
+
+import "fmt"
+
+// ExprKind identifies the node types implementing Expr. The values don't
+// change, new node types get new kinds after the existing ones.
+type ExprKind int
+
+const (
+	UnknownExprKind ExprKind = iota
+	BinaryExprKind
+	UnaryExprKind
+	LiteralExprKind
+	GroupingExprKind
+	VariableExprKind
+	AssignExprKind
+	LogicalExprKind
+	CallExprKind
+	GetExprKind
+	SetExprKind
+	ThisExprKind
+	SuperExprKind
+	ListExprKind
+	MapExprKind
+	IndexExprKind
+	IndexSetExprKind
+	PostfixExprKind
+	ConditionalExprKind
+	CommaExprKind
+)
+
+var exprKindNames = []string{"Unknown", "Binary", "Unary", "Literal", "Grouping", "Variable", "Assign", "Logical", "Call", "Get", "Set", "This", "Super", "List", "Map", "Index", "IndexSet", "Postfix", "Conditional", "Comma"}
+
+func (k ExprKind) String() string {
+	if k < 0 || int(k) >= len(exprKindNames) {
+		return fmt.Sprintf("ExprKind(%d)", int(k))
+	}
+	return exprKindNames[k]
+}
+
+// UnknownExpr stands in for an expression the parser hasn't parsed yet, or
+// failed to parse.
+type UnknownExpr struct {
+	order int
+	span  Span
+}
+
+func (expr *UnknownExpr) Kind() ExprKind {
+	return UnknownExprKind
+}
+
+func (expr *UnknownExpr) Children() []Expr {
+	return nil
+}
+
+func (expr *UnknownExpr) Copy() Expr {
+	return &UnknownExpr{expr.order, expr.span}
+}
+
+func (expr *UnknownExpr) Order() int {
+	return expr.order
+}
+
+func (expr *UnknownExpr) Span() Span {
+	return expr.span
+}
+
+func (expr *UnknownExpr) setSpan(span Span) {
+	expr.span = span
+}
+
+// BinaryExpr is an arithmetic or comparison operator between two operands,
+// like a + b.
+type BinaryExpr struct {
+	Left     Expr
+	Operator Token
+	Right    Expr
+	order    int
+	span     Span
+}
+
+func (expr *BinaryExpr) Kind() ExprKind {
+	return BinaryExprKind
+}
+
+func (expr *BinaryExpr) Children() []Expr {
+	return []Expr{expr.Left, expr.Right}
+}
+
+func (expr *BinaryExpr) Copy() Expr {
+	return &BinaryExpr{expr.Left.Copy(), expr.Operator, expr.Right.Copy(), expr.order, expr.span}
+}
+
+func (expr *BinaryExpr) Order() int {
+	return expr.order
+}
+
+func (expr *BinaryExpr) Span() Span {
+	return expr.span
+}
+
+func (expr *BinaryExpr) setSpan(span Span) {
+	expr.span = span
+}
+
+// UnaryExpr is a prefix operator, either - or !.
+type UnaryExpr struct {
+	Operator Token
+	Right    Expr
+	order    int
+	span     Span
+}
+
+func (expr *UnaryExpr) Kind() ExprKind {
+	return UnaryExprKind
+}
+
+func (expr *UnaryExpr) Children() []Expr {
+	return []Expr{expr.Right}
+}
+
+func (expr *UnaryExpr) Copy() Expr {
+	return &UnaryExpr{expr.Operator, expr.Right.Copy(), expr.order, expr.span}
+}
+
+func (expr *UnaryExpr) Order() int {
+	return expr.order
+}
+
+func (expr *UnaryExpr) Span() Span {
+	return expr.span
+}
+
+func (expr *UnaryExpr) setSpan(span Span) {
+	expr.span = span
+}
+
+// LiteralExpr is a number, string, true, false or nil written in the source.
+// Value is a float64, string, bool or nil. Other packages make one with
+// NewLiteralExpr.
+type LiteralExpr struct {
+	Value interface{}
+	token Token
+	order int
+	span  Span
+}
+
+func (expr *LiteralExpr) Kind() ExprKind {
+	return LiteralExprKind
+}
+
+func (expr *LiteralExpr) Children() []Expr {
+	return nil
+}
+
+func (expr *LiteralExpr) Copy() Expr {
+	return &LiteralExpr{expr.Value, expr.token, expr.order, expr.span}
+}
+
+func (expr *LiteralExpr) Order() int {
+	return expr.order
+}
+
+func (expr *LiteralExpr) Span() Span {
+	return expr.span
+}
+
+func (expr *LiteralExpr) setSpan(span Span) {
+	expr.span = span
+}
+
+// GroupingExpr is an expression in parentheses, Paren is the opening one.
+type GroupingExpr struct {
+	Expression Expr
+	Paren      Token
+	order      int
+	span       Span
+}
+
+func (expr *GroupingExpr) Kind() ExprKind {
+	return GroupingExprKind
+}
+
+func (expr *GroupingExpr) Children() []Expr {
+	return []Expr{expr.Expression}
+}
+
+func (expr *GroupingExpr) Copy() Expr {
+	return &GroupingExpr{expr.Expression.Copy(), expr.Paren, expr.order, expr.span}
+}
+
+func (expr *GroupingExpr) Order() int {
+	return expr.order
+}
+
+func (expr *GroupingExpr) Span() Span {
+	return expr.span
+}
+
+func (expr *GroupingExpr) setSpan(span Span) {
+	expr.span = span
+}
+
+// VariableExpr reads a variable.
+type VariableExpr struct {
+	Name  Token
+	order int
+	span  Span
+}
+
+func (expr *VariableExpr) Kind() ExprKind {
+	return VariableExprKind
+}
+
+func (expr *VariableExpr) Children() []Expr {
+	return nil
+}
+
+func (expr *VariableExpr) Copy() Expr {
+	return &VariableExpr{expr.Name, expr.order, expr.span}
+}
+
+func (expr *VariableExpr) Order() int {
+	return expr.order
+}
+
+func (expr *VariableExpr) Span() Span {
+	return expr.span
+}
+
+func (expr *VariableExpr) setSpan(span Span) {
+	expr.span = span
+}
+
+// AssignExpr assigns to a variable.
+type AssignExpr struct {
+	Name  Token
+	Value Expr
+	order int
+	span  Span
+}
+
+func (expr *AssignExpr) Kind() ExprKind {
+	return AssignExprKind
+}
+
+func (expr *AssignExpr) Children() []Expr {
+	return []Expr{expr.Value}
+}
+
+func (expr *AssignExpr) Copy() Expr {
+	return &AssignExpr{expr.Name, expr.Value.Copy(), expr.order, expr.span}
+}
+
+func (expr *AssignExpr) Order() int {
+	return expr.order
+}
+
+func (expr *AssignExpr) Span() Span {
+	return expr.span
+}
+
+func (expr *AssignExpr) setSpan(span Span) {
+	expr.span = span
+}
+
+// LogicalExpr is an and or an or, which only evaluates Right when it needs
+// to.
+type LogicalExpr struct {
+	Left     Expr
+	Operator Token
+	Right    Expr
+	order    int
+	span     Span
+}
+
+func (expr *LogicalExpr) Kind() ExprKind {
+	return LogicalExprKind
+}
+
+func (expr *LogicalExpr) Children() []Expr {
+	return []Expr{expr.Left, expr.Right}
+}
+
+func (expr *LogicalExpr) Copy() Expr {
+	return &LogicalExpr{expr.Left.Copy(), expr.Operator, expr.Right.Copy(), expr.order, expr.span}
+}
+
+func (expr *LogicalExpr) Order() int {
+	return expr.order
+}
+
+func (expr *LogicalExpr) Span() Span {
+	return expr.span
+}
+
+func (expr *LogicalExpr) setSpan(span Span) {
+	expr.span = span
+}
+
+// CallExpr is a call, Paren is the closing parenthesis.
+type CallExpr struct {
+	Callee    Expr
+	Paren     Token
+	Arguments []Expr
+	order     int
+	span      Span
+}
+
+func (expr *CallExpr) Kind() ExprKind {
+	return CallExprKind
+}
+
+func (expr *CallExpr) Children() []Expr {
+	children := []Expr{expr.Callee}
+	children = append(children, expr.Arguments...)
+	return children
+}
+
+func (expr *CallExpr) Copy() Expr {
+	return &CallExpr{expr.Callee.Copy(), expr.Paren, copyExprs(expr.Arguments), expr.order, expr.span}
+}
+
+func (expr *CallExpr) Order() int {
+	return expr.order
+}
+
+func (expr *CallExpr) Span() Span {
+	return expr.span
+}
+
+func (expr *CallExpr) setSpan(span Span) {
+	expr.span = span
+}
+
+// GetExpr reads a property of an instance, like object.name.
+type GetExpr struct {
+	Object Expr
+	Name   Token
+	order  int
+	span   Span
+}
+
+func (expr *GetExpr) Kind() ExprKind {
+	return GetExprKind
+}
+
+func (expr *GetExpr) Children() []Expr {
+	return []Expr{expr.Object}
+}
+
+func (expr *GetExpr) Copy() Expr {
+	return &GetExpr{expr.Object.Copy(), expr.Name, expr.order, expr.span}
+}
+
+func (expr *GetExpr) Order() int {
+	return expr.order
+}
+
+func (expr *GetExpr) Span() Span {
+	return expr.span
+}
+
+func (expr *GetExpr) setSpan(span Span) {
+	expr.span = span
+}
+
+// SetExpr assigns to a property of an instance.
+type SetExpr struct {
+	Object Expr
+	Name   Token
+	Value  Expr
+	order  int
+	span   Span
+}
+
+func (expr *SetExpr) Kind() ExprKind {
+	return SetExprKind
+}
+
+func (expr *SetExpr) Children() []Expr {
+	return []Expr{expr.Object, expr.Value}
+}
+
+func (expr *SetExpr) Copy() Expr {
+	return &SetExpr{expr.Object.Copy(), expr.Name, expr.Value.Copy(), expr.order, expr.span}
+}
+
+func (expr *SetExpr) Order() int {
+	return expr.order
+}
+
+func (expr *SetExpr) Span() Span {
+	return expr.span
+}
+
+func (expr *SetExpr) setSpan(span Span) {
+	expr.span = span
+}
+
+// ThisExpr is this inside a method.
+type ThisExpr struct {
+	Keyword Token
+	order   int
+	span    Span
+}
+
+func (expr *ThisExpr) Kind() ExprKind {
+	return ThisExprKind
+}
+
+func (expr *ThisExpr) Children() []Expr {
+	return nil
+}
+
+func (expr *ThisExpr) Copy() Expr {
+	return &ThisExpr{expr.Keyword, expr.order, expr.span}
+}
+
+func (expr *ThisExpr) Order() int {
+	return expr.order
+}
+
+func (expr *ThisExpr) Span() Span {
+	return expr.span
+}
+
+func (expr *ThisExpr) setSpan(span Span) {
+	expr.span = span
+}
+
+// SuperExpr looks up Method on the superclass, like super.init.
+type SuperExpr struct {
+	Keyword Token
+	Method  Token
+	order   int
+	span    Span
+}
+
+func (expr *SuperExpr) Kind() ExprKind {
+	return SuperExprKind
+}
+
+func (expr *SuperExpr) Children() []Expr {
+	return nil
+}
+
+func (expr *SuperExpr) Copy() Expr {
+	return &SuperExpr{expr.Keyword, expr.Method, expr.order, expr.span}
+}
+
+func (expr *SuperExpr) Order() int {
+	return expr.order
+}
+
+func (expr *SuperExpr) Span() Span {
+	return expr.span
+}
+
+func (expr *SuperExpr) setSpan(span Span) {
+	expr.span = span
+}
+
+// ListExpr is a list literal, like [1, 2].
+type ListExpr struct {
+	Bracket  Token
+	Elements []Expr
+	order    int
+	span     Span
+}
+
+func (expr *ListExpr) Kind() ExprKind {
+	return ListExprKind
+}
+
+func (expr *ListExpr) Children() []Expr {
+	return expr.Elements
+}
+
+func (expr *ListExpr) Copy() Expr {
+	return &ListExpr{expr.Bracket, copyExprs(expr.Elements), expr.order, expr.span}
+}
+
+func (expr *ListExpr) Order() int {
+	return expr.order
+}
+
+func (expr *ListExpr) Span() Span {
+	return expr.span
+}
+
+func (expr *ListExpr) setSpan(span Span) {
+	expr.span = span
+}
+
+// MapExpr is a map literal, like {"a": 1}. Keys[i] maps to Values[i].
+type MapExpr struct {
+	Brace  Token
+	Keys   []Expr
+	Values []Expr
+	order  int
+	span   Span
+}
+
+func (expr *MapExpr) Kind() ExprKind {
+	return MapExprKind
+}
+
+func (expr *MapExpr) Copy() Expr {
+	return &MapExpr{expr.Brace, copyExprs(expr.Keys), copyExprs(expr.Values), expr.order, expr.span}
+}
+
+func (expr *MapExpr) Order() int {
+	return expr.order
+}
+
+func (expr *MapExpr) Span() Span {
+	return expr.span
+}
+
+func (expr *MapExpr) setSpan(span Span) {
+	expr.span = span
+}
+
+// IndexExpr reads an element of a list or map, like object[index].
+type IndexExpr struct {
+	Object  Expr
+	Bracket Token
+	Index   Expr
+	order   int
+	span    Span
+}
+
+func (expr *IndexExpr) Kind() ExprKind {
+	return IndexExprKind
+}
+
+func (expr *IndexExpr) Children() []Expr {
+	return []Expr{expr.Object, expr.Index}
+}
+
+func (expr *IndexExpr) Copy() Expr {
+	return &IndexExpr{expr.Object.Copy(), expr.Bracket, expr.Index.Copy(), expr.order, expr.span}
+}
+
+func (expr *IndexExpr) Order() int {
+	return expr.order
+}
+
+func (expr *IndexExpr) Span() Span {
+	return expr.span
+}
+
+func (expr *IndexExpr) setSpan(span Span) {
+	expr.span = span
+}
+
+// IndexSetExpr assigns to an element of a list or map.
+type IndexSetExpr struct {
+	Object  Expr
+	Bracket Token
+	Index   Expr
+	Value   Expr
+	order   int
+	span    Span
+}
+
+func (expr *IndexSetExpr) Kind() ExprKind {
+	return IndexSetExprKind
+}
+
+func (expr *IndexSetExpr) Children() []Expr {
+	return []Expr{expr.Object, expr.Index, expr.Value}
+}
+
+func (expr *IndexSetExpr) Copy() Expr {
+	return &IndexSetExpr{expr.Object.Copy(), expr.Bracket, expr.Index.Copy(), expr.Value.Copy(), expr.order, expr.span}
+}
+
+func (expr *IndexSetExpr) Order() int {
+	return expr.order
+}
+
+func (expr *IndexSetExpr) Span() Span {
+	return expr.span
+}
+
+func (expr *IndexSetExpr) setSpan(span Span) {
+	expr.span = span
+}
+
+// PostfixExpr is an operator after its operand. Lox doesn't have any, they
+// only come from custom operator tables.
+type PostfixExpr struct {
+	Left     Expr
+	Operator Token
+	order    int
+	span     Span
+}
+
+func (expr *PostfixExpr) Kind() ExprKind {
+	return PostfixExprKind
+}
+
+func (expr *PostfixExpr) Children() []Expr {
+	return []Expr{expr.Left}
+}
+
+func (expr *PostfixExpr) Copy() Expr {
+	return &PostfixExpr{expr.Left.Copy(), expr.Operator, expr.order, expr.span}
+}
+
+func (expr *PostfixExpr) Order() int {
+	return expr.order
+}
+
+func (expr *PostfixExpr) Span() Span {
+	return expr.span
+}
+
+func (expr *PostfixExpr) setSpan(span Span) {
+	expr.span = span
+}
+
+// ConditionalExpr is cond ? a : b, which only evaluates the branch it picks.
+type ConditionalExpr struct {
+	Condition Expr
+	Question  Token
+	Then      Expr
+	Colon     Token
+	Else      Expr
+	order     int
+	span      Span
+}
+
+func (expr *ConditionalExpr) Kind() ExprKind {
+	return ConditionalExprKind
+}
+
+func (expr *ConditionalExpr) Children() []Expr {
+	return []Expr{expr.Condition, expr.Then, expr.Else}
+}
+
+func (expr *ConditionalExpr) Copy() Expr {
+	return &ConditionalExpr{expr.Condition.Copy(), expr.Question, expr.Then.Copy(), expr.Colon, expr.Else.Copy(), expr.order, expr.span}
+}
+
+func (expr *ConditionalExpr) Order() int {
+	return expr.order
+}
+
+func (expr *ConditionalExpr) Span() Span {
+	return expr.span
+}
+
+func (expr *ConditionalExpr) setSpan(span Span) {
+	expr.span = span
+}
+
+// CommaExpr evaluates Left then Right, and is the value of Right.
+type CommaExpr struct {
+	Left     Expr
+	Operator Token
+	Right    Expr
+	order    int
+	span     Span
+}
+
+func (expr *CommaExpr) Kind() ExprKind {
+	return CommaExprKind
+}
+
+func (expr *CommaExpr) Children() []Expr {
+	return []Expr{expr.Left, expr.Right}
+}
+
+func (expr *CommaExpr) Copy() Expr {
+	return &CommaExpr{expr.Left.Copy(), expr.Operator, expr.Right.Copy(), expr.order, expr.span}
+}
+
+func (expr *CommaExpr) Order() int {
+	return expr.order
+}
+
+func (expr *CommaExpr) Span() Span {
+	return expr.span
+}
+
+func (expr *CommaExpr) setSpan(span Span) {
+	expr.span = span
+}
+
+// ExprVisitor is a pass over Expr nodes, R is what each visit returns.
+type ExprVisitor[R any] interface {
+	VisitUnknownExpr(expr *UnknownExpr) R
+	VisitBinaryExpr(expr *BinaryExpr) R
+	VisitUnaryExpr(expr *UnaryExpr) R
+	VisitLiteralExpr(expr *LiteralExpr) R
+	VisitGroupingExpr(expr *GroupingExpr) R
+	VisitVariableExpr(expr *VariableExpr) R
+	VisitAssignExpr(expr *AssignExpr) R
+	VisitLogicalExpr(expr *LogicalExpr) R
+	VisitCallExpr(expr *CallExpr) R
+	VisitGetExpr(expr *GetExpr) R
+	VisitSetExpr(expr *SetExpr) R
+	VisitThisExpr(expr *ThisExpr) R
+	VisitSuperExpr(expr *SuperExpr) R
+	VisitListExpr(expr *ListExpr) R
+	VisitMapExpr(expr *MapExpr) R
+	VisitIndexExpr(expr *IndexExpr) R
+	VisitIndexSetExpr(expr *IndexSetExpr) R
+	VisitPostfixExpr(expr *PostfixExpr) R
+	VisitConditionalExpr(expr *ConditionalExpr) R
+	VisitCommaExpr(expr *CommaExpr) R
+}
+
+// AcceptExpr calls the method of visitor for the type of expr. It returns
+// the zero value of R for a Expr type visitor doesn't know about.
+func AcceptExpr[R any](expr Expr, visitor ExprVisitor[R]) R {
+	switch expr := expr.(type) {
+	case *UnknownExpr:
+		return visitor.VisitUnknownExpr(expr)
+	case *BinaryExpr:
+		return visitor.VisitBinaryExpr(expr)
+	case *UnaryExpr:
+		return visitor.VisitUnaryExpr(expr)
+	case *LiteralExpr:
+		return visitor.VisitLiteralExpr(expr)
+	case *GroupingExpr:
+		return visitor.VisitGroupingExpr(expr)
+	case *VariableExpr:
+		return visitor.VisitVariableExpr(expr)
+	case *AssignExpr:
+		return visitor.VisitAssignExpr(expr)
+	case *LogicalExpr:
+		return visitor.VisitLogicalExpr(expr)
+	case *CallExpr:
+		return visitor.VisitCallExpr(expr)
+	case *GetExpr:
+		return visitor.VisitGetExpr(expr)
+	case *SetExpr:
+		return visitor.VisitSetExpr(expr)
+	case *ThisExpr:
+		return visitor.VisitThisExpr(expr)
+	case *SuperExpr:
+		return visitor.VisitSuperExpr(expr)
+	case *ListExpr:
+		return visitor.VisitListExpr(expr)
+	case *MapExpr:
+		return visitor.VisitMapExpr(expr)
+	case *IndexExpr:
+		return visitor.VisitIndexExpr(expr)
+	case *IndexSetExpr:
+		return visitor.VisitIndexSetExpr(expr)
+	case *PostfixExpr:
+		return visitor.VisitPostfixExpr(expr)
+	case *ConditionalExpr:
+		return visitor.VisitConditionalExpr(expr)
+	case *CommaExpr:
+		return visitor.VisitCommaExpr(expr)
+	}
+	var zero R
+	return zero
+}
+
+// copyExprWith copies expr like Copy, but with each child copied by
+// copyChild. Nodes with a hand written Copy use that instead.
+func copyExprWith(expr Expr, copyChild func(Expr) Expr) Expr {
+	switch expr := expr.(type) {
+	case *UnknownExpr:
+		return &UnknownExpr{expr.order, expr.span}
+	case *BinaryExpr:
+		return &BinaryExpr{copyChild(expr.Left), expr.Operator, copyChild(expr.Right), expr.order, expr.span}
+	case *UnaryExpr:
+		return &UnaryExpr{expr.Operator, copyChild(expr.Right), expr.order, expr.span}
+	case *LiteralExpr:
+		return &LiteralExpr{expr.Value, expr.token, expr.order, expr.span}
+	case *GroupingExpr:
+		return &GroupingExpr{copyChild(expr.Expression), expr.Paren, expr.order, expr.span}
+	case *VariableExpr:
+		return &VariableExpr{expr.Name, expr.order, expr.span}
+	case *AssignExpr:
+		return &AssignExpr{expr.Name, copyChild(expr.Value), expr.order, expr.span}
+	case *LogicalExpr:
+		return &LogicalExpr{copyChild(expr.Left), expr.Operator, copyChild(expr.Right), expr.order, expr.span}
+	case *CallExpr:
+		return &CallExpr{copyChild(expr.Callee), expr.Paren, copyExprsWith(expr.Arguments, copyChild), expr.order, expr.span}
+	case *GetExpr:
+		return &GetExpr{copyChild(expr.Object), expr.Name, expr.order, expr.span}
+	case *SetExpr:
+		return &SetExpr{copyChild(expr.Object), expr.Name, copyChild(expr.Value), expr.order, expr.span}
+	case *ThisExpr:
+		return &ThisExpr{expr.Keyword, expr.order, expr.span}
+	case *SuperExpr:
+		return &SuperExpr{expr.Keyword, expr.Method, expr.order, expr.span}
+	case *ListExpr:
+		return &ListExpr{expr.Bracket, copyExprsWith(expr.Elements, copyChild), expr.order, expr.span}
+	case *MapExpr:
+		return &MapExpr{expr.Brace, copyExprsWith(expr.Keys, copyChild), copyExprsWith(expr.Values, copyChild), expr.order, expr.span}
+	case *IndexExpr:
+		return &IndexExpr{copyChild(expr.Object), expr.Bracket, copyChild(expr.Index), expr.order, expr.span}
+	case *IndexSetExpr:
+		return &IndexSetExpr{copyChild(expr.Object), expr.Bracket, copyChild(expr.Index), copyChild(expr.Value), expr.order, expr.span}
+	case *PostfixExpr:
+		return &PostfixExpr{copyChild(expr.Left), expr.Operator, expr.order, expr.span}
+	case *ConditionalExpr:
+		return &ConditionalExpr{copyChild(expr.Condition), expr.Question, copyChild(expr.Then), expr.Colon, copyChild(expr.Else), expr.order, expr.span}
+	case *CommaExpr:
+		return &CommaExpr{copyChild(expr.Left), expr.Operator, copyChild(expr.Right), expr.order, expr.span}
+	}
+	return expr.Copy()
+}
+
+// StmtKind identifies the node types implementing Stmt. The values don't
+// change, new node types get new kinds after the existing ones.
+type StmtKind int
+
+const (
+	ExpressionStmtKind StmtKind = iota
+	PrintStmtKind
+	VarStmtKind
+	BlockStmtKind
+	IfStmtKind
+	WhileStmtKind
+	FunctionStmtKind
+	ReturnStmtKind
+	ClassStmtKind
+)
+
+var stmtKindNames = []string{"Expression", "Print", "Var", "Block", "If", "While", "Function", "Return", "Class"}
+
+func (k StmtKind) String() string {
+	if k < 0 || int(k) >= len(stmtKindNames) {
+		return fmt.Sprintf("StmtKind(%d)", int(k))
+	}
+	return stmtKindNames[k]
+}
+
+// ExpressionStmt evaluates an expression for its side effects. Other packages
+// make one with NewExpressionStmt.
+type ExpressionStmt struct {
+	Expression Expr
+	token      Token
+}
+
+func (stmt *ExpressionStmt) Kind() StmtKind {
+	return ExpressionStmtKind
+}
+
+// PrintStmt prints the value of an expression.
+type PrintStmt struct {
+	Keyword    Token
+	Expression Expr
+}
+
+func (stmt *PrintStmt) Kind() StmtKind {
+	return PrintStmtKind
+}
+
+// VarStmt declares a variable, Initializer is nil when there isn't one. Type
+// is the name in its type annotation, the zero Token without one.
+type VarStmt struct {
+	Keyword     Token
+	Name        Token
+	Initializer Expr
+	Type        Token
+}
+
+func (stmt *VarStmt) Kind() StmtKind {
+	return VarStmtKind
+}
+
+// BlockStmt is a list of statements in braces, with a scope of its own.
+type BlockStmt struct {
+	Brace      Token
+	Statements []Stmt
+}
+
+func (stmt *BlockStmt) Kind() StmtKind {
+	return BlockStmtKind
+}
+
+// IfStmt runs ThenBranch or ElseBranch, which can be nil.
+type IfStmt struct {
+	Keyword    Token
+	Condition  Expr
+	ThenBranch Stmt
+	ElseBranch Stmt
+}
+
+func (stmt *IfStmt) Kind() StmtKind {
+	return IfStmtKind
+}
+
+// WhileStmt is a while loop. For loops are parsed into while loops.
+type WhileStmt struct {
+	Keyword   Token
+	Condition Expr
+	Body      Stmt
+}
+
+func (stmt *WhileStmt) Kind() StmtKind {
+	return WhileStmtKind
+}
+
+// FunctionStmt declares a function, or a method inside a class. ParamTypes
+// has a type annotation for each of Params and ReturnType is the one after
+// the parameters, with zero Tokens where there aren't any.
+type FunctionStmt struct {
+	Name       Token
+	Params     []Token
+	Body       []Stmt
+	ParamTypes []Token
+	ReturnType Token
+}
+
+func (stmt *FunctionStmt) Kind() StmtKind {
+	return FunctionStmtKind
+}
+
+// ReturnStmt returns from a function, Value is nil for a bare return.
+type ReturnStmt struct {
+	Keyword Token
+	Value   Expr
+}
+
+func (stmt *ReturnStmt) Kind() StmtKind {
+	return ReturnStmtKind
+}
+
+// ClassStmt declares a class, Superclass is nil when it doesn't have one.
+type ClassStmt struct {
+	Name       Token
+	Superclass *VariableExpr
+	Methods    []*FunctionStmt
+}
+
+func (stmt *ClassStmt) Kind() StmtKind {
+	return ClassStmtKind
+}
+
+// StmtVisitor is a pass over Stmt nodes, R is what each visit returns.
+type StmtVisitor[R any] interface {
+	VisitExpressionStmt(stmt *ExpressionStmt) R
+	VisitPrintStmt(stmt *PrintStmt) R
+	VisitVarStmt(stmt *VarStmt) R
+	VisitBlockStmt(stmt *BlockStmt) R
+	VisitIfStmt(stmt *IfStmt) R
+	VisitWhileStmt(stmt *WhileStmt) R
+	VisitFunctionStmt(stmt *FunctionStmt) R
+	VisitReturnStmt(stmt *ReturnStmt) R
+	VisitClassStmt(stmt *ClassStmt) R
+}
+
+// AcceptStmt calls the method of visitor for the type of stmt. It returns
+// the zero value of R for a Stmt type visitor doesn't know about.
+func AcceptStmt[R any](stmt Stmt, visitor StmtVisitor[R]) R {
+	switch stmt := stmt.(type) {
+	case *ExpressionStmt:
+		return visitor.VisitExpressionStmt(stmt)
+	case *PrintStmt:
+		return visitor.VisitPrintStmt(stmt)
+	case *VarStmt:
+		return visitor.VisitVarStmt(stmt)
+	case *BlockStmt:
+		return visitor.VisitBlockStmt(stmt)
+	case *IfStmt:
+		return visitor.VisitIfStmt(stmt)
+	case *WhileStmt:
+		return visitor.VisitWhileStmt(stmt)
+	case *FunctionStmt:
+		return visitor.VisitFunctionStmt(stmt)
+	case *ReturnStmt:
+		return visitor.VisitReturnStmt(stmt)
+	case *ClassStmt:
+		return visitor.VisitClassStmt(stmt)
+	}
+	var zero R
+	return zero
+}
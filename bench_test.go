@@ -0,0 +1,81 @@
+package golox
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+// generateProgram writes a Lox program of units copies of a block that uses
+// most of the language, each with its own names
+func generateProgram(units int) string {
+	var b strings.Builder
+	for n := 0; n < units; n++ {
+		fmt.Fprintf(&b, `// Unit %[1]d
+fun fib%[1]d(n) {
+  if (n < 2) return n;
+  return fib%[1]d(n - 1) + fib%[1]d(n - 2);
+}
+
+class Point%[1]d {
+  init(x, y) {
+    this.x = x;
+    this.y = y;
+  }
+  sum() { return this.x + this.y; }
+}
+
+var name%[1]d = "unit number %[1]d";
+var items%[1]d = [1, 2.5, "three", nil, true, {"key": "value"}];
+var point%[1]d = Point%[1]d(%[1]d, 2);
+var total%[1]d = 0;
+for (var i = 0; i < 10; i = i + 1) {
+  total%[1]d = total%[1]d + point%[1]d.sum() * i;
+}
+while (total%[1]d > 100 and !(total%[1]d == nil)) {
+  total%[1]d = total%[1]d / 2;
+}
+print name%[1]d + ": " + "done";
+print fib%[1]d(8) - total%[1]d;
+
+`, n)
+	}
+	return b.String()
+}
+
+var benchmarkProgram = generateProgram(500)
+
+func BenchmarkScan(b *testing.B) {
+	b.SetBytes(int64(len(benchmarkProgram)))
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		RunScanner(benchmarkProgram, func(message string) {
+			b.Fatal(message)
+		})
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	displayError := func(message string) {
+		b.Fatal(message)
+	}
+	tokens := RunScanner(benchmarkProgram, displayError)
+	b.SetBytes(int64(len(benchmarkProgram)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		p := parser{tokens: tokens, displayError: displayError}
+		p.parseProgram()
+	}
+}
+
+func BenchmarkEval(b *testing.B) {
+	b.SetBytes(int64(len(benchmarkProgram)))
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		if err := NewInterpreter(io.Discard).Run(benchmarkProgram); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package golox
+
+import "fmt"
+
+// callable is implemented by every Lox value that can appear before a call's
+// parentheses. An arity of -1 means the callable checks its own arguments.
+type callable interface {
+	arity() int
+	call(interp *Interpreter, arguments []interface{}) (interface{}, error)
+}
+
+// NativeFunc is a Go function made callable from Lox with Interpreter.Define.
+// Arguments arrive as Lox values and the result is converted with ToLox. An
+// error returned by the function is reported as a runtime error at the call.
+type NativeFunc func(args []interface{}) (interface{}, error)
+
+// params is the number of arguments the interpreter checks for before calling
+// fn, or -1 to leave checking to fn
+type nativeFunction struct {
+	name   string
+	params int
+	fn     NativeFunc
+}
+
+func (native *nativeFunction) arity() int {
+	return native.params
+}
+
+func (native *nativeFunction) call(interp *Interpreter, arguments []interface{}) (interface{}, error) {
+	result, err := native.fn(arguments)
+	if err != nil {
+		return nil, err
+	}
+	return ToLox(result)
+}
+
+func (native *nativeFunction) String() string {
+	return fmt.Sprintf("<native fn %s>", native.name)
+}
+
+type loxFunction struct {
+	declaration   *FunctionStmt
+	closure       *environment
+	isInitializer bool
+}
+
+func (function *loxFunction) arity() int {
+	return len(function.declaration.Params)
+}
+
+func (function *loxFunction) call(interp *Interpreter, arguments []interface{}) (interface{}, error) {
+	env := newEnvironment(function.closure)
+	for i, param := range function.declaration.Params {
+		env.define(param.Lexeme, arguments[i])
+	}
+
+	err := interp.executeBlock(function.declaration.Body, env)
+	if ret, ok := err.(*returnValue); ok {
+		if function.isInitializer {
+			return function.closure.values["this"], nil
+		}
+		return ret.value, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if function.isInitializer {
+		return function.closure.values["this"], nil
+	}
+	return nil, nil
+}
+
+func (function *loxFunction) bind(instance *loxInstance) *loxFunction {
+	env := newEnvironment(function.closure)
+	env.define("this", instance)
+	return &loxFunction{function.declaration, env, function.isInitializer}
+}
+
+func (function *loxFunction) String() string {
+	return fmt.Sprintf("<fn %s>", function.declaration.Name.Lexeme)
+}
+
+// returnValue unwinds the Go stack from a return statement back to the
+// function call that is returning
+type returnValue struct {
+	value interface{}
+}
+
+func (ret *returnValue) Error() string {
+	return "return outside of a function"
+}
+
+type loxClass struct {
+	name       string
+	superclass *loxClass
+	methods    map[string]*loxFunction
+}
+
+func (class *loxClass) findMethod(name string) *loxFunction {
+	if method, ok := class.methods[name]; ok {
+		return method
+	}
+	if class.superclass != nil {
+		return class.superclass.findMethod(name)
+	}
+	return nil
+}
+
+func (class *loxClass) arity() int {
+	if initializer := class.findMethod("init"); initializer != nil {
+		return initializer.arity()
+	}
+	return 0
+}
+
+func (class *loxClass) call(interp *Interpreter, arguments []interface{}) (interface{}, error) {
+	instance := &loxInstance{class, make(map[string]interface{})}
+	if initializer := class.findMethod("init"); initializer != nil {
+		_, err := initializer.bind(instance).call(interp, arguments)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return instance, nil
+}
+
+func (class *loxClass) String() string {
+	return class.name
+}
+
+type loxInstance struct {
+	class  *loxClass
+	fields map[string]interface{}
+}
+
+func (instance *loxInstance) get(name Token) (interface{}, error) {
+	if value, ok := instance.fields[name.Lexeme]; ok {
+		return value, nil
+	}
+	if method := instance.class.findMethod(name.Lexeme); method != nil {
+		return method.bind(instance), nil
+	}
+	return nil, &RuntimeError{name, fmt.Sprintf("Undefined property '%s'", name.Lexeme), nil}
+}
+
+func (instance *loxInstance) set(name Token, value interface{}) {
+	instance.fields[name.Lexeme] = value
+}
+
+func (instance *loxInstance) String() string {
+	return instance.class.name + " instance"
+}
@@ -0,0 +1,307 @@
+// generate-ast writes the syntax tree node types described in a grammar file,
+// the way the book's GenerateAst does.
+//
+// Usage:
+//
+//	generate-ast <grammar file> <output file>
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+)
+
+func main() {
+	if len(os.Args) != 3 {
+		fmt.Println("Usage: generate-ast <grammar file> <output file>")
+		os.Exit(64)
+	}
+	groups, err := readGrammar(os.Args[1])
+	if err != nil {
+		log.Fatal(err)
+	}
+	source, err := format.Source(generate(os.Args[1], groups))
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = ioutil.WriteFile(os.Args[2], source, 0644)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// group is the nodes implementing one interface, like Expr
+type group struct {
+	base  string
+	nodes []node
+}
+
+type node struct {
+	name   string
+	doc    []string
+	fields []field
+	// custom methods are written by hand
+	custom map[string]bool
+}
+
+type field struct {
+	name  string
+	ftype string
+}
+
+func readGrammar(path string) ([]*group, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var groups []*group
+	var doc []string
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			doc = nil
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			doc = append(doc, strings.TrimSpace(strings.TrimPrefix(line, "#")))
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			groups = append(groups, &group{base: line[1 : len(line)-1]})
+			continue
+		}
+		if len(groups) == 0 {
+			return nil, fmt.Errorf("%s:%d: node outside of a [section]", path, lineNumber)
+		}
+		n, err := parseNode(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, lineNumber, err)
+		}
+		n.doc = doc
+		doc = nil
+		g := groups[len(groups)-1]
+		g.nodes = append(g.nodes, n)
+	}
+	return groups, scanner.Err()
+}
+
+func parseNode(line string) (node, error) {
+	colon := strings.Index(line, ":")
+	if colon < 0 {
+		return node{}, fmt.Errorf("expected ':' after the node name")
+	}
+	n := node{name: strings.TrimSpace(line[:colon]), custom: make(map[string]bool)}
+	rest := line[colon+1:]
+	if bang := strings.Index(rest, "!"); bang >= 0 {
+		for _, method := range strings.Fields(rest[bang+1:]) {
+			n.custom[method] = true
+		}
+		rest = rest[:bang]
+	}
+	for _, f := range strings.Split(rest, ",") {
+		parts := strings.Fields(f)
+		if len(parts) == 0 {
+			continue
+		}
+		if len(parts) != 2 {
+			return node{}, fmt.Errorf("expected a field name and type, got %q", strings.TrimSpace(f))
+		}
+		n.fields = append(n.fields, field{parts[0], parts[1]})
+	}
+	return n, nil
+}
+
+func generate(grammarPath string, groups []*group) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by generate-ast from %s. DO NOT EDIT.\n\n", grammarPath)
+	fmt.Fprintf(&b, "package golox\n\nimport \"fmt\"\n")
+	for _, g := range groups {
+		writeKinds(&b, g)
+		for _, n := range g.nodes {
+			writeNode(&b, g, n)
+		}
+		writeVisitor(&b, g)
+		if g.base == "Expr" {
+			writeCopyWith(&b, g)
+		}
+	}
+	return b.Bytes()
+}
+
+func writeNode(b *bytes.Buffer, g *group, n node) {
+	typeName := n.name + g.base
+	receiver := strings.ToLower(g.base)
+	fmt.Fprintf(b, "\n")
+	for _, line := range n.doc {
+		fmt.Fprintf(b, "// %s\n", line)
+	}
+	fmt.Fprintf(b, "type %s struct {\n", typeName)
+	for _, f := range n.fields {
+		fmt.Fprintf(b, "%s %s\n", f.name, f.ftype)
+	}
+	if g.base == "Expr" {
+		fmt.Fprintf(b, "order int\n")
+		fmt.Fprintf(b, "span Span\n")
+	}
+	fmt.Fprintf(b, "}\n")
+
+	fmt.Fprintf(b, "\nfunc (%s *%s) Kind() %sKind {\n", receiver, typeName, g.base)
+	fmt.Fprintf(b, "return %sKind\n", typeName)
+	fmt.Fprintf(b, "}\n")
+	if g.base != "Expr" {
+		return
+	}
+
+	if !n.custom["Children"] {
+		fmt.Fprintf(b, "\nfunc (%s *%s) Children() []Expr {\n", receiver, typeName)
+		// Single children go in a slice literal until the first list of
+		// them, which the rest are appended after
+		var single []string
+		var appended []string
+		for _, f := range n.fields {
+			switch {
+			case f.ftype == "Expr" && len(appended) == 0:
+				single = append(single, fmt.Sprintf("%s.%s", receiver, f.name))
+			case f.ftype == "Expr":
+				appended = append(appended, fmt.Sprintf("%s.%s", receiver, f.name))
+			case f.ftype == "[]Expr":
+				appended = append(appended, fmt.Sprintf("%s.%s...", receiver, f.name))
+			}
+		}
+		switch {
+		case len(single) == 0 && len(appended) == 0:
+			fmt.Fprintf(b, "return nil\n")
+		case len(appended) == 0:
+			fmt.Fprintf(b, "return []Expr{%s}\n", strings.Join(single, ", "))
+		case len(single) == 0 && len(appended) == 1:
+			fmt.Fprintf(b, "return %s\n", strings.TrimSuffix(appended[0], "..."))
+		default:
+			fmt.Fprintf(b, "children := []Expr{%s}\n", strings.Join(single, ", "))
+			for _, child := range appended {
+				fmt.Fprintf(b, "children = append(children, %s)\n", child)
+			}
+			fmt.Fprintf(b, "return children\n")
+		}
+		fmt.Fprintf(b, "}\n")
+	}
+
+	if !n.custom["Copy"] {
+		fmt.Fprintf(b, "\nfunc (%s *%s) Copy() Expr {\n", receiver, typeName)
+		values := copyValues(n, receiver, "%s.Copy()", "copyExprs(%s)")
+		fmt.Fprintf(b, "return &%s{%s}\n", typeName, strings.Join(values, ", "))
+		fmt.Fprintf(b, "}\n")
+	}
+
+	if !n.custom["Order"] {
+		fmt.Fprintf(b, "\nfunc (%s *%s) Order() int {\n", receiver, typeName)
+		fmt.Fprintf(b, "return %s.order\n", receiver)
+		fmt.Fprintf(b, "}\n")
+	}
+
+	if !n.custom["Span"] {
+		fmt.Fprintf(b, "\nfunc (%s *%s) Span() Span {\n", receiver, typeName)
+		fmt.Fprintf(b, "return %s.span\n", receiver)
+		fmt.Fprintf(b, "}\n")
+		fmt.Fprintf(b, "\nfunc (%s *%s) setSpan(span Span) {\n", receiver, typeName)
+		fmt.Fprintf(b, "%s.span = span\n", receiver)
+		fmt.Fprintf(b, "}\n")
+	}
+}
+
+func writeKinds(b *bytes.Buffer, g *group) {
+	kindType := g.base + "Kind"
+	fmt.Fprintf(b, "\n// %s identifies the node types implementing %s. The values don't\n", kindType, g.base)
+	fmt.Fprintf(b, "// change, new node types get new kinds after the existing ones.\n")
+	fmt.Fprintf(b, "type %s int\n", kindType)
+	fmt.Fprintf(b, "\nconst (\n")
+	for i, n := range g.nodes {
+		if i == 0 {
+			fmt.Fprintf(b, "%s%s %s = iota\n", n.name, kindType, kindType)
+		} else {
+			fmt.Fprintf(b, "%s%s\n", n.name, kindType)
+		}
+	}
+	fmt.Fprintf(b, ")\n")
+
+	names := make([]string, len(g.nodes))
+	for i, n := range g.nodes {
+		names[i] = fmt.Sprintf("%q", n.name)
+	}
+	fmt.Fprintf(b, "\nvar %sNames = []string{%s}\n", strings.ToLower(g.base)+"Kind", strings.Join(names, ", "))
+	fmt.Fprintf(b, "\nfunc (k %s) String() string {\n", kindType)
+	fmt.Fprintf(b, "if k < 0 || int(k) >= len(%sKindNames) {\n", strings.ToLower(g.base))
+	fmt.Fprintf(b, "return fmt.Sprintf(\"%s(%%d)\", int(k))\n", kindType)
+	fmt.Fprintf(b, "}\n")
+	fmt.Fprintf(b, "return %sKindNames[k]\n", strings.ToLower(g.base))
+	fmt.Fprintf(b, "}\n")
+}
+
+func writeVisitor(b *bytes.Buffer, g *group) {
+	receiver := strings.ToLower(g.base)
+	fmt.Fprintf(b, "\n// %sVisitor is a pass over %s nodes, R is what each visit returns.\n", g.base, g.base)
+	fmt.Fprintf(b, "type %sVisitor[R any] interface {\n", g.base)
+	for _, n := range g.nodes {
+		typeName := n.name + g.base
+		fmt.Fprintf(b, "Visit%s(%s *%s) R\n", typeName, receiver, typeName)
+	}
+	fmt.Fprintf(b, "}\n")
+
+	fmt.Fprintf(b, "\n// Accept%s calls the method of visitor for the type of %s. It returns\n", g.base, receiver)
+	fmt.Fprintf(b, "// the zero value of R for a %s type visitor doesn't know about.\n", g.base)
+	fmt.Fprintf(b, "func Accept%s[R any](%s %s, visitor %sVisitor[R]) R {\n", g.base, receiver, g.base, g.base)
+	fmt.Fprintf(b, "switch %s := %s.(type) {\n", receiver, receiver)
+	for _, n := range g.nodes {
+		typeName := n.name + g.base
+		fmt.Fprintf(b, "case *%s:\n", typeName)
+		fmt.Fprintf(b, "return visitor.Visit%s(%s)\n", typeName, receiver)
+	}
+	fmt.Fprintf(b, "}\n")
+	fmt.Fprintf(b, "var zero R\n")
+	fmt.Fprintf(b, "return zero\n")
+	fmt.Fprintf(b, "}\n")
+}
+
+// copyValues lists the values for a composite literal copying n, with its
+// Expr and []Expr fields copied by the given format strings
+func copyValues(n node, receiver string, copyExpr string, copyList string) []string {
+	var values []string
+	for _, f := range n.fields {
+		value := fmt.Sprintf("%s.%s", receiver, f.name)
+		switch f.ftype {
+		case "Expr":
+			value = fmt.Sprintf(copyExpr, value)
+		case "[]Expr":
+			value = fmt.Sprintf(copyList, value)
+		}
+		values = append(values, value)
+	}
+	return append(values, receiver+".order", receiver+".span")
+}
+
+func writeCopyWith(b *bytes.Buffer, g *group) {
+	fmt.Fprintf(b, "\n// copyExprWith copies expr like Copy, but with each child copied by\n")
+	fmt.Fprintf(b, "// copyChild. Nodes with a hand written Copy use that instead.\n")
+	fmt.Fprintf(b, "func copyExprWith(expr Expr, copyChild func(Expr) Expr) Expr {\n")
+	fmt.Fprintf(b, "switch expr := expr.(type) {\n")
+	for _, n := range g.nodes {
+		if n.custom["Copy"] {
+			continue
+		}
+		typeName := n.name + g.base
+		values := copyValues(n, "expr", "copyChild(%s)", "copyExprsWith(%s, copyChild)")
+		fmt.Fprintf(b, "case *%s:\n", typeName)
+		fmt.Fprintf(b, "return &%s{%s}\n", typeName, strings.Join(values, ", "))
+	}
+	fmt.Fprintf(b, "}\n")
+	fmt.Fprintf(b, "return expr.Copy()\n")
+	fmt.Fprintf(b, "}\n")
+}
@@ -4,45 +4,187 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strings"
 
 	"github.com/samGbos/golox"
 )
 
 func main() {
-	args := os.Args
-	if len(args) > 2 {
-		fmt.Println("Usage golox [script]")
+	if len(os.Args) > 1 && os.Args[1] == "lint" {
+		runLint(os.Args[2:])
+		return
+	}
+
+	passes := golox.NewPassManager()
+	optimize := flag.Bool("O", false, "Run all of the optimization passes")
+	enabled := make(map[string]*bool)
+	for _, pass := range passes.Passes() {
+		enabled[pass.Name] = flag.Bool(pass.Name, false, pass.Description)
+	}
+	showPasses := flag.Bool("show-passes", false, "Print what each optimization pass changed to stderr")
+	checkTypes := flag.Bool("check-types", false, "Check the types in the script before running it")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage golox [flags] [script]")
+		fmt.Fprintln(os.Stderr, "      golox lint [flags] script...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	anyEnabled := false
+	for name, on := range enabled {
+		passes.Enable(name, *optimize || *on)
+		anyEnabled = anyEnabled || *optimize || *on
+	}
+	if !anyEnabled {
+		passes = nil
+	} else if *showPasses {
+		passes.Report = printReport
+	}
+
+	args := flag.Args()
+	if len(args) > 1 {
+		flag.Usage()
 		os.Exit(64)
-	} else if len(args) == 2 {
-		runFile(args[1])
+	} else if len(args) == 1 {
+		runFile(args[0], passes, *checkTypes)
 	} else {
-		runPrompt()
+		runPrompt(passes, *checkTypes)
 	}
 }
 
-func runPrompt() {
+func printReport(report golox.PassReport) {
+	if len(report.Changes) == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%s:\n%s", report.Pass, report.Diff())
+}
+
+func runPrompt(passes *golox.PassManager, checkTypes bool) {
 	reader := bufio.NewReader(os.Stdin)
+	interpreter := golox.NewInterpreter(os.Stdout)
+	interpreter.SetTypeChecking(checkTypes)
+	if passes != nil {
+		interpreter.SetPassManager(passes)
+	}
+	interpreter.SetInput(reader)
+	interpreter.Allow(golox.CapabilityInput | golox.CapabilityReadFile)
 	for {
 		fmt.Print("> ")
-		text, _ := reader.ReadString('\n')
-		expr := golox.RunParser(text)
-		fmt.Println(expr)
+		text, err := reader.ReadString('\n')
+		if err != nil {
+			fmt.Println()
+			return
+		}
+		err = interpreter.Run(text)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
 	}
 }
 
-func runFile(script string) {
+func runFile(script string, passes *golox.PassManager, checkTypes bool) {
 	b, err := ioutil.ReadFile(script)
 	if err != nil {
-		fmt.Print(err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(66)
+	}
+	interpreter := golox.NewInterpreter(os.Stdout)
+	interpreter.SetTypeChecking(checkTypes)
+	if passes != nil {
+		interpreter.SetPassManager(passes)
+	}
+	interpreter.Allow(golox.CapabilityInput | golox.CapabilityReadFile)
+	err = interpreter.Run(string(b))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		switch err.(type) {
+		case *golox.SyntaxError:
+			os.Exit(65)
+		case *golox.RuntimeError:
+			os.Exit(70)
+		}
 	}
-	tokens := golox.RunScanner(string(b))
-	for _, token := range tokens {
-		fmt.Printf("%#v\n", token)
+}
+
+// runLint lints each script, exiting with 1 when anything was found and 65
+// when a script has errors
+func runLint(args []string) {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	fix := flags.Bool("fix", false, "Fix what can be fixed, rewriting the scripts")
+	disable := flags.String("disable", "", "Comma separated IDs of rules to turn off")
+	listRules := flags.Bool("rules", false, "List the rules")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage golox lint [flags] script...")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if *listRules {
+		for _, rule := range golox.DefaultRules() {
+			fmt.Printf("%-20s %-8s %s\n", rule.ID, rule.Severity, rule.Description)
+		}
+		return
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(64)
+	}
+
+	off := make(map[string]bool)
+	if *disable != "" {
+		for _, id := range strings.Split(*disable, ",") {
+			off[id] = true
+		}
+	}
+	var rules []*golox.Rule
+	for _, rule := range golox.DefaultRules() {
+		if off[rule.ID] {
+			delete(off, rule.ID)
+		} else {
+			rules = append(rules, rule)
+		}
+	}
+	for id := range off {
+		fmt.Fprintf(os.Stderr, "Unknown rule '%s'\n", id)
+		os.Exit(64)
+	}
+
+	status := 0
+	for _, script := range flags.Args() {
+		b, err := ioutil.ReadFile(script)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(66)
+		}
+		source := string(b)
+		findings, diagnostics := golox.Lint(source, rules)
+		if *fix {
+			fixed, made := golox.ApplyFixes(source, findings)
+			if made > 0 {
+				err = ioutil.WriteFile(script, []byte(fixed), 0644)
+				if err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					os.Exit(74)
+				}
+				fmt.Fprintf(os.Stderr, "%s: %d fixed\n", script, made)
+				findings, diagnostics = golox.Lint(fixed, rules)
+			}
+		}
+
+		for _, diagnostic := range diagnostics {
+			fmt.Printf("%s:%d:%d: error: %s\n", script, diagnostic.Token.StartLine, diagnostic.Token.Start+1, diagnostic.Message)
+			status = 65
+		}
+		for _, finding := range findings {
+			fmt.Printf("%s:%d:%d: %s: %s [%s]\n", script, finding.Token.StartLine, finding.Token.Start+1, finding.Severity, finding.Message, finding.Rule)
+			if status == 0 {
+				status = 1
+			}
+		}
 	}
-	// if hadError {
-	// os.Exit(65)
-	// }
+	os.Exit(status)
 }
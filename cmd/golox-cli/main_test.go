@@ -0,0 +1,119 @@
+// +build !js
+
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestMain runs the CLI instead of the tests when runCLI runs the test
+// binary as itself
+func TestMain(m *testing.M) {
+	if os.Getenv("GOLOX_TEST_MAIN") == "1" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runCLI runs the CLI with args, returning its output and exit code
+func runCLI(t *testing.T, args ...string) (string, int) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), "GOLOX_TEST_MAIN=1")
+	out, err := cmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return string(out), exitErr.ExitCode()
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	return string(out), 0
+}
+
+func writeScript(t *testing.T, source string) string {
+	t.Helper()
+	script := filepath.Join(t.TempDir(), "script.lox")
+	if err := ioutil.WriteFile(script, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return script
+}
+
+func TestLintExitCodes(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		source string
+		code   int
+		output string
+	}{
+		{"clean", nil, "print 1;\n", 0, ""},
+		{"finding", nil, "fun f(a) { return 1; }\nprint f(1);\n", 1, "script.lox:1:7: hint: Parameter 'a' is never used [unused-param]"},
+		{"disabled rule", []string{"-disable", "unused-param"}, "fun f(a) { return 1; }\nprint f(1);\n", 0, ""},
+		{"unknown rule", []string{"-disable", "nope"}, "print 1;\n", 64, "Unknown rule 'nope'"},
+		{"syntax error", nil, "print ;\n", 65, "script.lox:1:7: error: Expected expression"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			script := writeScript(t, test.source)
+			args := append(append([]string{"lint"}, test.args...), script)
+			out, code := runCLI(t, args...)
+			if code != test.code {
+				t.Errorf("Exited with %d, want %d\n%s", code, test.code, out)
+			}
+			if !strings.Contains(out, test.output) {
+				t.Errorf("Printed %q, want %q", out, test.output)
+			}
+		})
+	}
+
+	if out, code := runCLI(t, "lint"); code != 64 {
+		t.Errorf("Linting nothing exited with %d, want 64\n%s", code, out)
+	}
+	if out, code := runCLI(t, "lint", filepath.Join(t.TempDir(), "missing.lox")); code != 66 {
+		t.Errorf("Linting a missing file exited with %d, want 66\n%s", code, out)
+	}
+}
+
+func TestLintFix(t *testing.T) {
+	script := writeScript(t, "fun f(a) {\n  return 1;\n  print 2;\n}\nprint f(1);\n")
+	out, code := runCLI(t, "lint", "-fix", script)
+	if code != 0 {
+		t.Errorf("Exited with %d, want 0\n%s", code, out)
+	}
+	if !strings.Contains(out, "2 fixed") {
+		t.Errorf("Printed %q, want it to say 2 were fixed", out)
+	}
+	b, err := ioutil.ReadFile(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "fun f(_a) {\n  return 1;\n}\nprint f(1);\n"; string(b) != want {
+		t.Errorf("Fixed to %q, want %q", b, want)
+	}
+}
+
+func TestRunExitCodes(t *testing.T) {
+	tests := []struct {
+		source string
+		code   int
+	}{
+		{"print 1;", 0},
+		{"print ;", 65},
+		{"print -\"a\";", 70},
+		{"fun f(n) { return f(n + 1); } f(1);", 70},
+	}
+	for _, test := range tests {
+		out, code := runCLI(t, writeScript(t, test.source))
+		if code != test.code {
+			t.Errorf("%q exited with %d, want %d\n%s", test.source, code, test.code, out)
+		}
+	}
+}
@@ -0,0 +1,496 @@
+// +build !js
+
+// golox-dap is a Debug Adapter Protocol server for Lox, spoken over stdin and
+// stdout.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/samGbos/golox"
+)
+
+func main() {
+	s := newSession(os.Stdin, os.Stdout)
+	err := s.serve()
+	if err != nil && err != io.EOF {
+		log.Fatal(err)
+	}
+}
+
+// The script runs on a single thread, which is the only one reported
+const threadID = 1
+
+type message struct {
+	Seq        int             `json:"seq"`
+	Type       string          `json:"type"`
+	Command    string          `json:"command,omitempty"`
+	Arguments  json.RawMessage `json:"arguments,omitempty"`
+	RequestSeq int             `json:"request_seq,omitempty"`
+	Success    bool            `json:"success"`
+	Message    string          `json:"message,omitempty"`
+	Event      string          `json:"event,omitempty"`
+	Body       interface{}     `json:"body,omitempty"`
+}
+
+type source struct {
+	Name string `json:"name,omitempty"`
+	Path string `json:"path"`
+}
+
+type breakpoint struct {
+	Line      int    `json:"line"`
+	Condition string `json:"condition,omitempty"`
+}
+
+// action is what a paused script is told to do next
+type action int
+
+const (
+	continueAction action = iota
+	nextAction
+	stepInAction
+	stepOutAction
+	terminateAction
+)
+
+var errTerminated = errors.New("Debugging stopped")
+
+// session is one debugging session. Requests are handled on the goroutine
+// calling serve while the script runs on its own goroutine. The script's
+// goroutine blocks in hook whenever it is paused, and everything below mu is
+// shared between the two.
+type session struct {
+	in      *bufio.Reader
+	out     io.Writer
+	writeMu sync.Mutex
+	seq     int
+
+	interpreter *golox.Interpreter
+	program     string
+	source      string
+	stopOnEntry bool
+	resume      chan action
+	done        chan struct{}
+
+	mu          sync.Mutex
+	breakpoints map[int]string
+	paused      bool
+	pauseWanted bool
+	mode        action
+	stepDepth   int
+	lastLine    int
+	lastColumn  int
+	lastDepth   int
+	// handles maps variablesReference numbers to the variables they
+	// expand to, they only last until the script resumes
+	handles [][]golox.Variable
+}
+
+func newSession(in io.Reader, out io.Writer) *session {
+	return &session{
+		in:          bufio.NewReader(in),
+		out:         out,
+		resume:      make(chan action),
+		done:        make(chan struct{}),
+		breakpoints: make(map[int]string),
+	}
+}
+
+func (s *session) serve() error {
+	for {
+		msg, err := s.read()
+		if err != nil {
+			return err
+		}
+		if msg.Type != "request" {
+			continue
+		}
+		if s.handle(msg) {
+			return nil
+		}
+	}
+}
+
+func (s *session) read() (*message, error) {
+	length := -1
+	for {
+		line, err := s.in.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		if strings.HasPrefix(line, "Content-Length:") {
+			length, err = strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "Content-Length:")))
+			if err != nil {
+				return nil, fmt.Errorf("bad Content-Length header: %v", err)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("message has no Content-Length header")
+	}
+	body := make([]byte, length)
+	_, err := io.ReadFull(s.in, body)
+	if err != nil {
+		return nil, err
+	}
+	var msg message
+	err = json.Unmarshal(body, &msg)
+	if err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+func (s *session) write(msg *message) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.seq++
+	msg.Seq = s.seq
+	body, err := json.Marshal(msg)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+func (s *session) respond(request *message, body interface{}) {
+	s.write(&message{Type: "response", Command: request.Command, RequestSeq: request.Seq, Success: true, Body: body})
+}
+
+func (s *session) fail(request *message, errorMsg string) {
+	s.write(&message{Type: "response", Command: request.Command, RequestSeq: request.Seq, Message: errorMsg})
+}
+
+func (s *session) event(name string, body interface{}) {
+	s.write(&message{Type: "event", Event: name, Body: body})
+}
+
+// handle answers a request, it returns true when the session is over
+func (s *session) handle(msg *message) bool {
+	switch msg.Command {
+	case "initialize":
+		s.respond(msg, map[string]interface{}{
+			"supportsConfigurationDoneRequest": true,
+			"supportsConditionalBreakpoints":   true,
+			"supportsEvaluateForHovers":        true,
+			"supportsTerminateRequest":         true,
+		})
+		s.event("initialized", nil)
+	case "launch":
+		var args struct {
+			Program     string `json:"program"`
+			StopOnEntry bool   `json:"stopOnEntry"`
+		}
+		json.Unmarshal(msg.Arguments, &args)
+		b, err := ioutil.ReadFile(args.Program)
+		if err != nil {
+			s.fail(msg, err.Error())
+			return false
+		}
+		s.program = args.Program
+		s.source = string(b)
+		s.stopOnEntry = args.StopOnEntry
+		s.respond(msg, nil)
+	case "setBreakpoints":
+		var args struct {
+			Source      source       `json:"source"`
+			Breakpoints []breakpoint `json:"breakpoints"`
+		}
+		json.Unmarshal(msg.Arguments, &args)
+		// Only the launched program is ever run, so every request is
+		// about its breakpoints
+		s.mu.Lock()
+		s.breakpoints = make(map[int]string)
+		verified := []map[string]interface{}{}
+		for _, bp := range args.Breakpoints {
+			s.breakpoints[bp.Line] = bp.Condition
+			verified = append(verified, map[string]interface{}{"verified": true, "line": bp.Line})
+		}
+		s.mu.Unlock()
+		s.respond(msg, map[string]interface{}{"breakpoints": verified})
+	case "configurationDone":
+		s.respond(msg, nil)
+		s.start()
+	case "threads":
+		s.respond(msg, map[string]interface{}{
+			"threads": []map[string]interface{}{{"id": threadID, "name": "main"}},
+		})
+	case "stackTrace":
+		if !s.isPaused() {
+			s.fail(msg, "The script is not paused")
+			return false
+		}
+		frames := []map[string]interface{}{}
+		for idx, frame := range s.interpreter.StackTrace() {
+			frames = append(frames, map[string]interface{}{
+				"id":     idx,
+				"name":   frame.Name,
+				"line":   frame.Line,
+				"column": 1,
+				"source": source{Name: s.program, Path: s.program},
+			})
+		}
+		s.respond(msg, map[string]interface{}{"stackFrames": frames, "totalFrames": len(frames)})
+	case "scopes":
+		var args struct {
+			FrameID int `json:"frameId"`
+		}
+		json.Unmarshal(msg.Arguments, &args)
+		frame, ok := s.frame(args.FrameID)
+		if !ok {
+			s.fail(msg, "No such frame")
+			return false
+		}
+		scopes := []map[string]interface{}{}
+		for _, scope := range frame.Scopes() {
+			scopes = append(scopes, map[string]interface{}{
+				"name":               scope.Name,
+				"variablesReference": s.reference(scope.Variables),
+				"expensive":          scope.Name == "Globals",
+			})
+		}
+		s.respond(msg, map[string]interface{}{"scopes": scopes})
+	case "variables":
+		var args struct {
+			VariablesReference int `json:"variablesReference"`
+		}
+		json.Unmarshal(msg.Arguments, &args)
+		s.mu.Lock()
+		var variables []golox.Variable
+		if args.VariablesReference > 0 && args.VariablesReference <= len(s.handles) {
+			variables = s.handles[args.VariablesReference-1]
+		}
+		s.mu.Unlock()
+		result := []map[string]interface{}{}
+		for _, v := range variables {
+			result = append(result, s.variable(v))
+		}
+		s.respond(msg, map[string]interface{}{"variables": result})
+	case "evaluate":
+		var args struct {
+			Expression string `json:"expression"`
+			FrameID    *int   `json:"frameId"`
+		}
+		json.Unmarshal(msg.Arguments, &args)
+		frameID := 0
+		if args.FrameID != nil {
+			frameID = *args.FrameID
+		}
+		frame, ok := s.frame(frameID)
+		if !ok {
+			s.fail(msg, "Expressions can only be evaluated while the script is paused")
+			return false
+		}
+		v, err := s.interpreter.Evaluate(frame, args.Expression)
+		if err != nil {
+			s.fail(msg, err.Error())
+			return false
+		}
+		body := s.variable(v)
+		body["result"] = body["value"]
+		s.respond(msg, body)
+	case "continue":
+		s.respond(msg, map[string]interface{}{"allThreadsContinued": true})
+		s.resumeWith(continueAction)
+	case "next":
+		s.respond(msg, nil)
+		s.resumeWith(nextAction)
+	case "stepIn":
+		s.respond(msg, nil)
+		s.resumeWith(stepInAction)
+	case "stepOut":
+		s.respond(msg, nil)
+		s.resumeWith(stepOutAction)
+	case "pause":
+		s.mu.Lock()
+		s.pauseWanted = true
+		s.mu.Unlock()
+		s.respond(msg, nil)
+	case "disconnect", "terminate":
+		s.resumeWith(terminateAction)
+		s.respond(msg, nil)
+		return msg.Command == "disconnect"
+	default:
+		s.fail(msg, "Unsupported request: "+msg.Command)
+	}
+	return false
+}
+
+// start runs the script on its own goroutine
+func (s *session) start() {
+	if s.interpreter != nil {
+		return
+	}
+	s.interpreter = golox.NewInterpreter(&outputWriter{s, "stdout"})
+	s.interpreter.SetDebugHook(s.hook)
+	if s.stopOnEntry {
+		s.mode = stepInAction
+	}
+	go func() {
+		defer close(s.done)
+		err := s.interpreter.Run(s.source)
+		exitCode := 0
+		if err == errTerminated {
+			exitCode = 1
+		} else if err != nil {
+			s.event("output", map[string]interface{}{"category": "stderr", "output": err.Error() + "\n"})
+			exitCode = 1
+		}
+		s.event("exited", map[string]interface{}{"exitCode": exitCode})
+		s.event("terminated", nil)
+	}()
+}
+
+// hook runs on the script's goroutine before each statement and blocks for
+// as long as the script is paused
+func (s *session) hook(line int, column int, depth int) error {
+	s.mu.Lock()
+	reason := s.stopReason(line, column, depth)
+	s.lastLine, s.lastColumn, s.lastDepth = line, column, depth
+	if reason == "" {
+		s.mu.Unlock()
+		return nil
+	}
+	s.paused = true
+	s.pauseWanted = false
+	s.handles = nil
+	s.mu.Unlock()
+
+	s.event("stopped", map[string]interface{}{"reason": reason, "threadId": threadID, "allThreadsStopped": true})
+	next := <-s.resume
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.paused = false
+	s.handles = nil
+	s.mode = next
+	s.stepDepth = depth
+	if next == terminateAction {
+		return errTerminated
+	}
+	return nil
+}
+
+// stopReason decides whether to pause before a statement, and says why
+func (s *session) stopReason(line int, column int, depth int) string {
+	if s.pauseWanted {
+		return "pause"
+	}
+	switch s.mode {
+	case stepInAction:
+		if s.interpreter != nil && s.lastLine == 0 && s.stopOnEntry {
+			return "entry"
+		}
+		return "step"
+	case nextAction:
+		if depth <= s.stepDepth {
+			return "step"
+		}
+	case stepOutAction:
+		if depth < s.stepDepth {
+			return "step"
+		}
+	}
+
+	condition, ok := s.breakpoints[line]
+	if !ok || (line == s.lastLine && depth == s.lastDepth && column > s.lastColumn) {
+		// Several statements on one line only stop once, but coming back
+		// to the line, like a loop does, stops again
+		return ""
+	}
+	if condition != "" {
+		frame := s.interpreter.StackTrace()[0]
+		v, err := s.interpreter.Evaluate(frame, condition)
+		if err != nil {
+			s.event("output", map[string]interface{}{"category": "stderr", "output": fmt.Sprintf("Breakpoint condition on line %d failed: %v\n", line, err)})
+			return "breakpoint"
+		}
+		if !v.Truthy() {
+			return ""
+		}
+	}
+	return "breakpoint"
+}
+
+func (s *session) resumeWith(next action) {
+	if !s.isPaused() {
+		if next == terminateAction && s.interpreter != nil {
+			// Stop at the next statement instead
+			s.mu.Lock()
+			s.pauseWanted = true
+			s.mu.Unlock()
+			go func() {
+				select {
+				case s.resume <- terminateAction:
+				case <-s.done:
+				}
+			}()
+		}
+		return
+	}
+	s.resume <- next
+}
+
+func (s *session) isPaused() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.paused
+}
+
+func (s *session) frame(id int) (golox.StackFrame, bool) {
+	if !s.isPaused() {
+		return golox.StackFrame{}, false
+	}
+	frames := s.interpreter.StackTrace()
+	if id < 0 || id >= len(frames) {
+		return golox.StackFrame{}, false
+	}
+	return frames[id], true
+}
+
+// reference hands out a variablesReference for variables
+func (s *session) reference(variables []golox.Variable) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handles = append(s.handles, variables)
+	return len(s.handles)
+}
+
+func (s *session) variable(v golox.Variable) map[string]interface{} {
+	reference := 0
+	if members := v.Members(); len(members) > 0 {
+		reference = s.reference(members)
+	}
+	return map[string]interface{}{
+		"name":               v.Name,
+		"value":              v.Value,
+		"variablesReference": reference,
+	}
+}
+
+// outputWriter turns what the script prints into output events
+type outputWriter struct {
+	s        *session
+	category string
+}
+
+func (w *outputWriter) Write(p []byte) (int, error) {
+	w.s.event("output", map[string]interface{}{"category": w.category, "output": string(p)})
+	return len(p), nil
+}
@@ -0,0 +1,181 @@
+// +build !js
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// client drives a session the way an editor would
+type client struct {
+	t        *testing.T
+	requests io.Writer
+	messages chan *message
+	seq      int
+}
+
+func newClient(t *testing.T) *client {
+	inReader, inWriter := io.Pipe()
+	outReader, outWriter := io.Pipe()
+	go newSession(inReader, outWriter).serve()
+
+	c := &client{t: t, requests: inWriter, messages: make(chan *message, 100)}
+	go func() {
+		defer close(c.messages)
+		reader := &session{in: bufio.NewReader(outReader)}
+		for {
+			msg, err := reader.read()
+			if err != nil {
+				return
+			}
+			c.messages <- msg
+		}
+	}()
+	t.Cleanup(func() {
+		inWriter.Close()
+		outReader.Close()
+	})
+	return c
+}
+
+// next returns the next message that isn't output from the script
+func (c *client) next() *message {
+	c.t.Helper()
+	for {
+		select {
+		case msg, ok := <-c.messages:
+			if !ok {
+				c.t.Fatal("The session closed its output")
+			}
+			if msg.Event == "output" {
+				continue
+			}
+			return msg
+		case <-time.After(5 * time.Second):
+			c.t.Fatal("Timed out waiting for a message")
+		}
+	}
+}
+
+// expect checks that the next message is the event or response called name
+func (c *client) expect(kind string, name string) *message {
+	c.t.Helper()
+	msg := c.next()
+	if msg.Type != kind || (msg.Event != name && msg.Command != name) {
+		c.t.Fatalf("Got %s %s%s, want %s %s", msg.Type, msg.Event, msg.Command, kind, name)
+	}
+	return msg
+}
+
+// request sends a request and waits for its response
+func (c *client) request(command string, arguments interface{}) map[string]interface{} {
+	c.t.Helper()
+	c.seq++
+	body, err := json.Marshal(map[string]interface{}{
+		"seq":       c.seq,
+		"type":      "request",
+		"command":   command,
+		"arguments": arguments,
+	})
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	fmt.Fprintf(c.requests, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	response := c.expect("response", command)
+	if !response.Success {
+		c.t.Fatalf("%s failed: %s", command, response.Message)
+	}
+	result, _ := response.Body.(map[string]interface{})
+	return result
+}
+
+func TestBreakpoints(t *testing.T) {
+	tests := []struct {
+		name        string
+		source      string
+		breakpoints []int
+		watch       string
+		// want is the line and watched value at each stop
+		want []string
+	}{
+		{
+			name:        "loop body",
+			source:      "var i = 0;\nwhile (i < 3) {\n  i = i + 1;\n}\n",
+			breakpoints: []int{3},
+			watch:       "i",
+			want:        []string{"3: 0", "3: 1", "3: 2"},
+		},
+		{
+			name:        "loop on one line",
+			source:      "var i = 0;\nwhile (i < 3) { i = i + 1; }\n",
+			breakpoints: []int{2},
+			watch:       "i",
+			want:        []string{"2: 0", "2: 1", "2: 2"},
+		},
+		{
+			name:        "statements on one line",
+			source:      "var a = 1;\nvar b = 2; var c = 3;\nprint a + b + c;\n",
+			breakpoints: []int{2},
+			watch:       "a",
+			want:        []string{"2: 1"},
+		},
+		{
+			name:        "function called in a loop",
+			source:      "fun f(n) {\n  return n * 2;\n}\nfor (var i = 0; i < 2; i = i + 1) print f(i);\n",
+			breakpoints: []int{2},
+			watch:       "n",
+			want:        []string{"2: 0", "2: 1"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			program := filepath.Join(t.TempDir(), "test.lox")
+			if err := ioutil.WriteFile(program, []byte(test.source), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			c := newClient(t)
+			c.request("initialize", map[string]interface{}{"adapterID": "golox"})
+			c.expect("event", "initialized")
+			c.request("launch", map[string]interface{}{"program": program})
+			var breakpoints []map[string]interface{}
+			for _, line := range test.breakpoints {
+				breakpoints = append(breakpoints, map[string]interface{}{"line": line})
+			}
+			c.request("setBreakpoints", map[string]interface{}{
+				"source":      map[string]interface{}{"path": program},
+				"breakpoints": breakpoints,
+			})
+			c.request("configurationDone", nil)
+
+			var stops []string
+			for {
+				msg := c.next()
+				if msg.Event == "exited" {
+					break
+				}
+				if msg.Event != "stopped" {
+					t.Fatalf("Got %s %s%s, want a stopped event", msg.Type, msg.Event, msg.Command)
+				}
+				trace := c.request("stackTrace", map[string]interface{}{"threadId": threadID})
+				frame := trace["stackFrames"].([]interface{})[0].(map[string]interface{})
+				value := c.request("evaluate", map[string]interface{}{"expression": test.watch, "frameId": 0})
+				stops = append(stops, fmt.Sprintf("%v: %v", frame["line"], value["result"]))
+				c.request("continue", map[string]interface{}{"threadId": threadID})
+			}
+			c.expect("event", "terminated")
+
+			if !reflect.DeepEqual(stops, test.want) {
+				t.Errorf("Stopped at %q, want %q", stops, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,475 @@
+// +build !js
+
+// golox-lsp is a Language Server Protocol server for Lox, spoken over stdin
+// and stdout.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/samGbos/golox"
+)
+
+func main() {
+	s := &server{
+		in:   bufio.NewReader(os.Stdin),
+		out:  os.Stdout,
+		docs: make(map[string]*document),
+	}
+	err := s.run()
+	if err != nil && err != io.EOF {
+		log.Fatal(err)
+	}
+}
+
+type server struct {
+	in   *bufio.Reader
+	out  io.Writer
+	docs map[string]*document
+}
+
+// document is an open file and what analysis found in it
+type document struct {
+	lines    []string
+	analysis *golox.Analysis
+}
+
+func newDocument(text string) *document {
+	return &document{
+		lines:    strings.Split(text, "\n"),
+		analysis: golox.Analyze(text),
+	}
+}
+
+func (s *server) run() error {
+	for {
+		msg, err := s.readMessage()
+		if err != nil {
+			return err
+		}
+		s.handle(msg)
+	}
+}
+
+func (s *server) readMessage() (*message, error) {
+	length := -1
+	for {
+		line, err := s.in.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		if strings.HasPrefix(line, "Content-Length:") {
+			length, err = strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "Content-Length:")))
+			if err != nil {
+				return nil, fmt.Errorf("bad Content-Length header: %v", err)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("message has no Content-Length header")
+	}
+	body := make([]byte, length)
+	_, err := io.ReadFull(s.in, body)
+	if err != nil {
+		return nil, err
+	}
+	var msg message
+	err = json.Unmarshal(body, &msg)
+	if err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+func (s *server) write(msg *message) {
+	msg.JSONRPC = "2.0"
+	body, err := json.Marshal(msg)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+func (s *server) reply(request *message, result interface{}) {
+	if result == nil {
+		result = json.RawMessage("null")
+	}
+	s.write(&message{ID: request.ID, Result: result})
+}
+
+func (s *server) replyError(request *message, code int, errorMsg string) {
+	s.write(&message{ID: request.ID, Error: &responseError{code, errorMsg}})
+}
+
+func (s *server) notify(method string, params interface{}) {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	s.write(&message{Method: method, Params: raw})
+}
+
+func (s *server) handle(msg *message) {
+	switch msg.Method {
+	case "initialize":
+		s.reply(msg, map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync":       1,
+				"definitionProvider":     true,
+				"referencesProvider":     true,
+				"hoverProvider":          true,
+				"documentSymbolProvider": true,
+				"semanticTokensProvider": map[string]interface{}{
+					"legend": map[string]interface{}{
+						"tokenTypes":     semanticTokenTypes,
+						"tokenModifiers": []string{},
+					},
+					"full": true,
+				},
+			},
+			"serverInfo": map[string]string{"name": "golox-lsp"},
+		})
+	case "shutdown":
+		s.reply(msg, nil)
+	case "exit":
+		os.Exit(0)
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if json.Unmarshal(msg.Params, &params) == nil {
+			s.update(params.TextDocument.URI, params.TextDocument.Text)
+		}
+	case "textDocument/didChange":
+		var params didChangeParams
+		if json.Unmarshal(msg.Params, &params) == nil && len(params.ContentChanges) > 0 {
+			// Full sync, the last change holds the whole document
+			text := params.ContentChanges[len(params.ContentChanges)-1].Text
+			s.update(params.TextDocument.URI, text)
+		}
+	case "textDocument/didClose":
+		var params didCloseParams
+		if json.Unmarshal(msg.Params, &params) == nil {
+			delete(s.docs, params.TextDocument.URI)
+			s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{params.TextDocument.URI, []diagnostic{}})
+		}
+	case "textDocument/definition":
+		var params textDocumentPositionParams
+		doc, ok := s.request(msg, &params)
+		if !ok {
+			return
+		}
+		symbol, _ := doc.symbolAt(params.Position)
+		if symbol == nil {
+			s.reply(msg, nil)
+			return
+		}
+		s.reply(msg, location{params.TextDocument.URI, doc.tokenRange(symbol.Name)})
+	case "textDocument/references":
+		var params referenceParams
+		doc, ok := s.request(msg, &params)
+		if !ok {
+			return
+		}
+		symbol, _ := doc.symbolAt(params.Position)
+		locations := []location{}
+		if symbol != nil {
+			if params.Context.IncludeDeclaration {
+				locations = append(locations, location{params.TextDocument.URI, doc.tokenRange(symbol.Name)})
+			}
+			for _, ref := range doc.analysis.References {
+				if ref.Symbol == symbol {
+					locations = append(locations, location{params.TextDocument.URI, doc.tokenRange(ref.Token)})
+				}
+			}
+		}
+		s.reply(msg, locations)
+	case "textDocument/hover":
+		var params textDocumentPositionParams
+		doc, ok := s.request(msg, &params)
+		if !ok {
+			return
+		}
+		symbol, token := doc.symbolAt(params.Position)
+		if symbol == nil {
+			s.reply(msg, nil)
+			return
+		}
+		s.reply(msg, hover{
+			Contents: markupContent{"markdown", fmt.Sprintf("```lox\n%s\n```\nDeclared on line %d", symbol.Detail, symbol.Name.Line)},
+			Range:    doc.tokenRange(token),
+		})
+	case "textDocument/documentSymbol":
+		var params struct {
+			TextDocument textDocumentIdentifier `json:"textDocument"`
+		}
+		doc, ok := s.request(msg, &params)
+		if !ok {
+			return
+		}
+		s.reply(msg, doc.documentSymbols(doc.analysis.Symbols))
+	case "textDocument/semanticTokens/full":
+		var params struct {
+			TextDocument textDocumentIdentifier `json:"textDocument"`
+		}
+		doc, ok := s.request(msg, &params)
+		if !ok {
+			return
+		}
+		s.reply(msg, semanticTokens{doc.semanticTokens()})
+	default:
+		if msg.ID != nil {
+			s.replyError(msg, methodNotFound, "Method not supported: "+msg.Method)
+		}
+	}
+}
+
+// request decodes a request's params into params and finds the document it
+// is about. It replies with an error itself when that fails.
+func (s *server) request(msg *message, params interface{}) (*document, bool) {
+	err := json.Unmarshal(msg.Params, params)
+	if err != nil {
+		s.replyError(msg, invalidParams, err.Error())
+		return nil, false
+	}
+	var target struct {
+		TextDocument textDocumentIdentifier `json:"textDocument"`
+	}
+	json.Unmarshal(msg.Params, &target)
+	doc, ok := s.docs[target.TextDocument.URI]
+	if !ok {
+		s.replyError(msg, invalidParams, "Document is not open: "+target.TextDocument.URI)
+		return nil, false
+	}
+	return doc, true
+}
+
+func (s *server) update(uri string, text string) {
+	doc := newDocument(text)
+	s.docs[uri] = doc
+
+	diagnostics := []diagnostic{}
+	for _, d := range doc.analysis.Diagnostics {
+		diagnostics = append(diagnostics, diagnostic{
+			Range:    doc.tokenRange(d.Token),
+			Severity: 1,
+			Source:   "golox",
+			Message:  d.Message,
+		})
+	}
+	s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{uri, diagnostics})
+}
+
+// utf16Column converts a byte column on a line, as tokens hold them, to the
+// UTF-16 column LSP uses
+func (doc *document) utf16Column(line int, column int) int {
+	if line < 0 || line >= len(doc.lines) {
+		return 0
+	}
+	text := doc.lines[line]
+	if column < 0 {
+		column = 0
+	}
+	if column > len(text) {
+		column = len(text)
+	}
+	return len(utf16.Encode([]rune(text[:column])))
+}
+
+// byteColumn is the inverse of utf16Column
+func (doc *document) byteColumn(pos position) int {
+	if pos.Line < 0 || pos.Line >= len(doc.lines) {
+		return 0
+	}
+	units := 0
+	for idx, r := range doc.lines[pos.Line] {
+		if units >= pos.Character {
+			return idx
+		}
+		units += len(utf16.Encode([]rune{r}))
+	}
+	return len(doc.lines[pos.Line])
+}
+
+func (doc *document) tokenRange(t golox.Token) lspRange {
+	startLine, line := t.StartLine-1, t.Line-1
+	return lspRange{
+		Start: position{startLine, doc.utf16Column(startLine, t.Start)},
+		End:   position{line, doc.utf16Column(line, t.End)},
+	}
+}
+
+func (doc *document) contains(t golox.Token, pos position) bool {
+	column := doc.byteColumn(pos)
+	if pos.Line < t.StartLine-1 || (pos.Line == t.StartLine-1 && column < t.Start) {
+		return false
+	}
+	return pos.Line < t.Line-1 || (pos.Line == t.Line-1 && column <= t.End)
+}
+
+// symbolAt finds the declaration of the name under pos, along with the token
+// under pos
+func (doc *document) symbolAt(pos position) (*golox.Symbol, golox.Token) {
+	for _, ref := range doc.analysis.References {
+		if ref.Symbol != nil && doc.contains(ref.Token, pos) {
+			return ref.Symbol, ref.Token
+		}
+	}
+	for _, symbol := range doc.allSymbols() {
+		if doc.contains(symbol.Name, pos) {
+			return symbol, symbol.Name
+		}
+	}
+	return nil, golox.Token{}
+}
+
+// allSymbols lists every declaration, including parameters, which are only
+// reachable through the references to them
+func (doc *document) allSymbols() []*golox.Symbol {
+	var symbols []*golox.Symbol
+	seen := make(map[*golox.Symbol]bool)
+	var walk func([]*golox.Symbol)
+	walk = func(list []*golox.Symbol) {
+		for _, symbol := range list {
+			if !seen[symbol] {
+				seen[symbol] = true
+				symbols = append(symbols, symbol)
+			}
+			walk(symbol.Children)
+		}
+	}
+	walk(doc.analysis.Symbols)
+	for _, ref := range doc.analysis.References {
+		if ref.Symbol != nil && !seen[ref.Symbol] {
+			seen[ref.Symbol] = true
+			symbols = append(symbols, ref.Symbol)
+		}
+	}
+	return symbols
+}
+
+func (doc *document) documentSymbols(symbols []*golox.Symbol) []documentSymbol {
+	result := []documentSymbol{}
+	for _, symbol := range symbols {
+		var kind int
+		switch symbol.Kind {
+		case golox.ClassSymbol:
+			kind = symbolKindClass
+		case golox.FunctionSymbol:
+			kind = symbolKindFunction
+		case golox.MethodSymbol:
+			kind = symbolKindMethod
+		default:
+			kind = symbolKindVariable
+		}
+		r := doc.tokenRange(symbol.Name)
+		result = append(result, documentSymbol{
+			Name:           symbol.Name.Lexeme,
+			Detail:         symbol.Detail,
+			Kind:           kind,
+			Range:          r,
+			SelectionRange: r,
+			Children:       doc.documentSymbols(symbol.Children),
+		})
+	}
+	return result
+}
+
+type tokenPosition struct {
+	line  int
+	start int
+}
+
+// semanticTokens encodes every token as LSP's relative line, relative start,
+// length, type and modifiers quintuple
+func (doc *document) semanticTokens() []int {
+	kinds := make(map[tokenPosition]golox.SymbolKind)
+	for _, symbol := range doc.allSymbols() {
+		kinds[tokenPosition{symbol.Name.Line, symbol.Name.Start}] = symbol.Kind
+	}
+	for _, ref := range doc.analysis.References {
+		if ref.Symbol != nil {
+			kinds[tokenPosition{ref.Token.Line, ref.Token.Start}] = ref.Symbol.Kind
+		}
+	}
+
+	data := []int{}
+	prevLine, prevStart := 0, 0
+	tokens := doc.analysis.Tokens
+	for idx, t := range tokens {
+		if t.StartLine != t.Line || t.End <= t.Start {
+			// Skip Eof and strings spanning lines, which LSP can't
+			// represent as a single token
+			continue
+		}
+		tokenType, ok := classify(tokens, idx, kinds)
+		if !ok {
+			continue
+		}
+		line := t.Line - 1
+		start := doc.utf16Column(line, t.Start)
+		length := doc.utf16Column(line, t.End) - start
+		if line != prevLine {
+			prevStart = 0
+		}
+		data = append(data, line-prevLine, start-prevStart, length, tokenType, 0)
+		prevLine, prevStart = line, start
+	}
+	return data
+}
+
+func classify(tokens []golox.Token, idx int, kinds map[tokenPosition]golox.SymbolKind) (int, bool) {
+	t := tokens[idx]
+	switch {
+	case t.Ttype >= golox.AndKeyword && t.Ttype <= golox.WhileKeyword:
+		return tokenKeyword, true
+	case t.Ttype == golox.StringLiteral:
+		return tokenString, true
+	case t.Ttype == golox.Number:
+		return tokenNumber, true
+	case t.Ttype == golox.Identifier:
+		nextIsCall := idx+1 < len(tokens) && tokens[idx+1].Ttype == golox.LeftParen
+		if idx > 0 && tokens[idx-1].Ttype == golox.Dot {
+			if nextIsCall {
+				return tokenMethod, true
+			}
+			return tokenProperty, true
+		}
+		kind, ok := kinds[tokenPosition{t.Line, t.Start}]
+		if !ok {
+			if nextIsCall {
+				return tokenFunction, true
+			}
+			return tokenVariable, true
+		}
+		switch kind {
+		case golox.ParameterSymbol:
+			return tokenParameter, true
+		case golox.FunctionSymbol:
+			return tokenFunction, true
+		case golox.ClassSymbol:
+			return tokenClass, true
+		case golox.MethodSymbol:
+			return tokenMethod, true
+		}
+		return tokenVariable, true
+	}
+	switch t.Ttype {
+	case golox.Minus, golox.Plus, golox.Slash, golox.Star, golox.Bang, golox.BangEqual,
+		golox.Equal, golox.EqualEqual, golox.Greater, golox.GreaterEqual, golox.Less, golox.LessEqual:
+		return tokenOperator, true
+	}
+	return 0, false
+}
@@ -0,0 +1,208 @@
+// +build !js
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// client drives a server the way an editor would
+type client struct {
+	t        *testing.T
+	requests io.Writer
+	messages chan *message
+	id       int
+}
+
+func newClient(t *testing.T) *client {
+	inReader, inWriter := io.Pipe()
+	outReader, outWriter := io.Pipe()
+	s := &server{in: bufio.NewReader(inReader), out: outWriter, docs: make(map[string]*document)}
+	go s.run()
+
+	c := &client{t: t, requests: inWriter, messages: make(chan *message, 100)}
+	go func() {
+		defer close(c.messages)
+		reader := &server{in: bufio.NewReader(outReader)}
+		for {
+			msg, err := reader.readMessage()
+			if err != nil {
+				return
+			}
+			c.messages <- msg
+		}
+	}()
+	t.Cleanup(func() {
+		inWriter.Close()
+		outReader.Close()
+	})
+	return c
+}
+
+func (c *client) send(msg map[string]interface{}) {
+	c.t.Helper()
+	msg["jsonrpc"] = "2.0"
+	body, err := json.Marshal(msg)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	fmt.Fprintf(c.requests, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+func (c *client) next() *message {
+	c.t.Helper()
+	select {
+	case msg, ok := <-c.messages:
+		if !ok {
+			c.t.Fatal("The server closed its output")
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("Timed out waiting for a message")
+	}
+	return nil
+}
+
+// call sends a request and waits for its response
+func (c *client) call(method string, params interface{}) *message {
+	c.t.Helper()
+	c.id++
+	c.send(map[string]interface{}{"id": c.id, "method": method, "params": params})
+	msg := c.next()
+	var id int
+	if msg.ID == nil || json.Unmarshal(*msg.ID, &id) != nil || id != c.id {
+		c.t.Fatalf("Got %+v, want the response to request %d", msg, c.id)
+	}
+	return msg
+}
+
+// result sends a request and returns its result, failing if it's an error
+func (c *client) result(method string, params interface{}) interface{} {
+	c.t.Helper()
+	msg := c.call(method, params)
+	if msg.Error != nil {
+		c.t.Fatalf("%s failed: %s", method, msg.Error.Message)
+	}
+	return msg.Result
+}
+
+// notify sends a notification, which gets no response
+func (c *client) notify(method string, params interface{}) {
+	c.t.Helper()
+	c.send(map[string]interface{}{"method": method, "params": params})
+}
+
+// diagnostics waits for the diagnostics published for uri, returning them
+// as "line:character message"
+func (c *client) diagnostics(uri string) []string {
+	c.t.Helper()
+	msg := c.next()
+	var params publishDiagnosticsParams
+	if msg.Method != "textDocument/publishDiagnostics" || json.Unmarshal(msg.Params, &params) != nil || params.URI != uri {
+		c.t.Fatalf("Got %+v, want diagnostics for %s", msg, uri)
+	}
+	got := []string{}
+	for _, d := range params.Diagnostics {
+		got = append(got, fmt.Sprintf("%d:%d %s", d.Range.Start.Line, d.Range.Start.Character, d.Message))
+	}
+	return got
+}
+
+// at is the params of a request about a position in uri
+func at(uri string, line int, character int) map[string]interface{} {
+	return map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri},
+		"position":     map[string]interface{}{"line": line, "character": character},
+	}
+}
+
+// describeLocations writes locations as "line:start-end"
+func describeLocations(result interface{}) []string {
+	got := []string{}
+	locations, _ := result.([]interface{})
+	for _, l := range locations {
+		r := l.(map[string]interface{})["range"].(map[string]interface{})
+		start := r["start"].(map[string]interface{})
+		end := r["end"].(map[string]interface{})
+		got = append(got, fmt.Sprintf("%v:%v-%v", start["line"], start["character"], end["character"]))
+	}
+	return got
+}
+
+func TestSession(t *testing.T) {
+	const uri = "file:///test.lox"
+	c := newClient(t)
+
+	capabilities := c.result("initialize", map[string]interface{}{})
+	provider := capabilities.(map[string]interface{})["capabilities"].(map[string]interface{})["definitionProvider"]
+	if provider != true {
+		t.Errorf("Says definitionProvider is %v, want true", provider)
+	}
+	c.notify("initialized", map[string]interface{}{})
+
+	source := "var total = 0;\nfun add(a, b) {\n  return a + b;\n}\ntotal = add(total, 1);\nprint ;\n"
+	c.notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri, "languageId": "lox", "version": 1, "text": source},
+	})
+	if got, want := c.diagnostics(uri), []string{"5:6 Expected expression"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Published %q, want %q", got, want)
+	}
+
+	if got, want := describeLocations([]interface{}{c.result("textDocument/definition", at(uri, 4, 9))}), []string{"1:4-7"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Defined at %q, want %q", got, want)
+	}
+	if result := c.result("textDocument/definition", at(uri, 4, 6)); result != nil {
+		t.Errorf("Defined '=' at %v, want nothing", result)
+	}
+
+	references := at(uri, 0, 5)
+	references["context"] = map[string]interface{}{"includeDeclaration": true}
+	if got, want := describeLocations(c.result("textDocument/references", references)), []string{"0:4-9", "4:12-17", "4:0-5"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Referenced at %q, want %q", got, want)
+	}
+
+	hover := c.result("textDocument/hover", at(uri, 2, 9)).(map[string]interface{})
+	if got, want := hover["contents"].(map[string]interface{})["value"], "```lox\nparameter a\n```\nDeclared on line 2"; got != want {
+		t.Errorf("Hovered %q, want %q", got, want)
+	}
+
+	var names []string
+	symbols, _ := c.result("textDocument/documentSymbol", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri},
+	}).([]interface{})
+	for _, s := range symbols {
+		symbol := s.(map[string]interface{})
+		names = append(names, fmt.Sprintf("%v %v", symbol["name"], symbol["kind"]))
+	}
+	if want := []string{fmt.Sprint("total ", symbolKindVariable), fmt.Sprint("add ", symbolKindFunction)}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Listed %q, want %q", names, want)
+	}
+
+	// Fixing the error clears its diagnostic
+	c.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": uri, "version": 2},
+		"contentChanges": []interface{}{map[string]interface{}{"text": "print 1;\n"}},
+	})
+	if got := c.diagnostics(uri); len(got) > 0 {
+		t.Errorf("Published %q after the fix, want nothing", got)
+	}
+
+	if msg := c.call("textDocument/hover", at("file:///closed.lox", 0, 0)); msg.Error == nil || msg.Error.Code != invalidParams {
+		t.Errorf("Hovering in a document that isn't open replied %+v, want an invalid params error", msg)
+	}
+	if msg := c.call("workspace/symbol", map[string]interface{}{"query": ""}); msg.Error == nil || msg.Error.Code != methodNotFound {
+		t.Errorf("An unsupported request replied %+v, want a method not found error", msg)
+	}
+
+	c.notify("textDocument/didClose", map[string]interface{}{"textDocument": map[string]interface{}{"uri": uri}})
+	if got := c.diagnostics(uri); len(got) > 0 {
+		t.Errorf("Published %q on close, want nothing", got)
+	}
+	c.result("shutdown", nil)
+}
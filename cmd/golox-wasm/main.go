@@ -4,6 +4,7 @@ package main
 
 import (
 	"fmt"
+	"strings"
 	"syscall/js"
 
 	"github.com/samGbos/golox"
@@ -14,6 +15,12 @@ func main() {
 	c := make(chan bool)
 	js.Global().Set("runScanner", js.FuncOf(runScanner))
 	js.Global().Set("runParser", js.FuncOf(runParser))
+	js.Global().Set("runPrattParser", js.FuncOf(runPrattParser))
+	js.Global().Set("scannerPlayer", js.FuncOf(scannerPlayer))
+	js.Global().Set("parserPlayer", js.FuncOf(parserPlayer))
+	js.Global().Set("evaluatorPlayer", js.FuncOf(evaluatorPlayer))
+	js.Global().Set("runTrace", js.FuncOf(runTrace))
+	js.Global().Set("runOptimizer", js.FuncOf(runOptimizer))
 	<-c
 }
 
@@ -42,8 +49,9 @@ func convertParserStep(step golox.ParserStep) map[string]interface{} {
 	}
 
 	return map[string]interface{}{
-		"exprs": exprs,
-		"logs":  logs,
+		"exprs":   exprs,
+		"logs":    logs,
+		"current": step.Current,
 	}
 }
 
@@ -53,6 +61,7 @@ func convertToken(t golox.Token) map[string]interface{} {
 		"lexeme":     t.Lexeme,
 		"literal":    t.Literal,
 		"line":       t.Line,
+		"startLine":  t.StartLine,
 		"start":      t.Start,
 		"end":        t.End,
 	}
@@ -65,10 +74,21 @@ func convertExpr(expr golox.Expr) map[string]interface{} {
 		children[idx] = convertExpr(child)
 	}
 	return map[string]interface{}{
-		"name":     expr.Name(),
+		"kind":     expr.Kind().String(),
+		"name":     expr.Label(),
 		"children": children,
 		"order":    expr.Order(),
 		"token":    convertToken(expr.Token()),
+		"span":     convertSpan(expr.Span()),
+	}
+}
+
+func convertSpan(span golox.Span) map[string]interface{} {
+	return map[string]interface{}{
+		"startLine": span.StartLine,
+		"start":     span.Start,
+		"endLine":   span.EndLine,
+		"end":       span.End,
 	}
 }
 
@@ -101,6 +121,34 @@ func runParser(this js.Value, inputs []js.Value) interface{} {
 	}
 
 	steps, tokens := golox.RunParserForSteps(message, displayError)
+	return convertParserSteps(steps, tokens)
+}
+
+// runPrattParser is runParser with the Pratt parser, whose logs also show the
+// binding power decisions. An optional third input is an operator table in
+// the format ParseOperatorTable reads.
+func runPrattParser(this js.Value, inputs []js.Value) interface{} {
+	message := inputs[0].String()
+	errorHandler := inputs[1]
+
+	displayError := func(errorMsg string) {
+		errorHandler.Invoke(errorMsg)
+	}
+
+	var table *golox.OperatorTable
+	if len(inputs) > 2 && inputs[2].Type() == js.TypeString {
+		var err error
+		table, err = golox.ParseOperatorTable(inputs[2].String())
+		if err != nil {
+			displayError(err.Error())
+			return nil
+		}
+	}
+	steps, tokens := golox.RunPrattParserForSteps(message, table, true, displayError)
+	return convertParserSteps(steps, tokens)
+}
+
+func convertParserSteps(steps []golox.ParserStep, tokens []golox.Token) interface{} {
 	serializedSteps := make([]interface{}, len(steps))
 	for istep, step := range steps {
 		serializedSteps[istep] = convertParserStep(step)
@@ -116,3 +164,181 @@ func runParser(this js.Value, inputs []js.Value) interface{} {
 	}
 	return jsVal
 }
+
+// runOptimizer shows the optimization passes folding an expression, with
+// steps like runParser's
+func runOptimizer(this js.Value, inputs []js.Value) interface{} {
+	displayError := func(errorMsg string) {
+		inputs[1].Invoke(errorMsg)
+	}
+	steps, tokens := golox.RunOptimizerForSteps(inputs[0].String(), displayError)
+	return convertParserSteps(steps, tokens)
+}
+
+// scannerPlayer makes a player over the scanner's steps, see newPlayer
+func scannerPlayer(this js.Value, inputs []js.Value) interface{} {
+	displayError := func(errorMsg string) {
+		inputs[1].Invoke(errorMsg)
+	}
+	recording := golox.RunScannerForRecording(inputs[0].String(), displayError)
+	return newPlayer(recording, func(n int) interface{} {
+		return convertScannerStep(recording.Step(n))
+	})
+}
+
+// parserPlayer makes a player over the parser's steps. An optional third
+// input is an operator table, which parses with the Pratt parser.
+func parserPlayer(this js.Value, inputs []js.Value) interface{} {
+	displayError := func(errorMsg string) {
+		inputs[1].Invoke(errorMsg)
+	}
+	var recording *golox.ParserRecording
+	if len(inputs) > 2 && inputs[2].Type() == js.TypeString {
+		table, err := golox.ParseOperatorTable(inputs[2].String())
+		if err != nil {
+			displayError(err.Error())
+			return nil
+		}
+		recording, _ = golox.RunPrattParserForRecording(inputs[0].String(), table, true, displayError)
+	} else {
+		recording, _ = golox.RunParserForRecording(inputs[0].String(), displayError)
+	}
+	return newPlayer(recording, func(n int) interface{} {
+		return convertParserStep(recording.Step(n))
+	})
+}
+
+// evaluatorPlayer runs a program and makes a player over what it did
+func evaluatorPlayer(this js.Value, inputs []js.Value) interface{} {
+	var output strings.Builder
+	recording, err := golox.NewInterpreter(&output).RunForRecording(inputs[0].String())
+	if err != nil {
+		inputs[1].Invoke(err.Error())
+	}
+	return newPlayer(recording, func(n int) interface{} {
+		return convertEvalStep(recording, n)
+	})
+}
+
+func convertEvalStep(recording *golox.EvaluatorRecording, n int) map[string]interface{} {
+	step := recording.Step(n)
+	return map[string]interface{}{
+		"kind":       step.Kind.String(),
+		"token":      convertToken(step.Token),
+		"tokenIndex": recording.TokenIndex(n),
+		"order":      step.Order,
+		"value":      step.Value,
+	}
+}
+
+// runTrace scans, parses and evaluates an expression. The stages' steps all
+// index into the one list of tokens, and nodes maps each expression's order
+// to the first and last of the tokens it was parsed from.
+func runTrace(this js.Value, inputs []js.Value) interface{} {
+	displayError := func(errorMsg string) {
+		inputs[1].Invoke(errorMsg)
+	}
+	trace := golox.RunTrace(inputs[0].String(), displayError)
+
+	tokens := make([]interface{}, len(trace.Tokens))
+	for idx, token := range trace.Tokens {
+		tokens[idx] = convertToken(token)
+	}
+	scannerSteps := make([]interface{}, trace.Scanner.Len())
+	for n := range scannerSteps {
+		step := convertScannerStep(trace.Scanner.Step(n))
+		step["tokenIndex"] = trace.Scanner.TokenIndex(n)
+		scannerSteps[n] = step
+	}
+	parserSteps := make([]interface{}, trace.Parser.Len())
+	for n := range parserSteps {
+		parserSteps[n] = convertParserStep(trace.Parser.Step(n))
+	}
+	evaluatorSteps := make([]interface{}, trace.Evaluator.Len())
+	for n := range evaluatorSteps {
+		evaluatorSteps[n] = convertEvalStep(trace.Evaluator, n)
+	}
+	nodes := make(map[string]interface{})
+	var addNode func(expr golox.Expr)
+	addNode = func(expr golox.Expr) {
+		if tokens, ok := trace.NodeTokens(expr.Order()); ok {
+			nodes[fmt.Sprint(expr.Order())] = []interface{}{tokens.First, tokens.Last}
+		}
+		for _, child := range expr.Children() {
+			addNode(child)
+		}
+	}
+	addNode(trace.Expr)
+
+	return map[string]interface{}{
+		"tokens":         tokens,
+		"scannerSteps":   scannerSteps,
+		"parserSteps":    parserSteps,
+		"evaluatorSteps": evaluatorSteps,
+		"nodes":          nodes,
+		"value":          trace.Value,
+	}
+}
+
+// newPlayer wraps a StepPlayer for JS. Its methods return whether they
+// moved, and step() converts the current step with convert.
+func newPlayer(recording golox.Recording, convert func(n int) interface{}) interface{} {
+	player := golox.NewStepPlayer(recording)
+	method := func(fn func(inputs []js.Value) interface{}) js.Func {
+		return js.FuncOf(func(this js.Value, inputs []js.Value) interface{} {
+			return fn(inputs)
+		})
+	}
+	return map[string]interface{}{
+		"len": method(func(inputs []js.Value) interface{} {
+			return player.Len()
+		}),
+		"current": method(func(inputs []js.Value) interface{} {
+			return player.Current()
+		}),
+		"kind": method(func(inputs []js.Value) interface{} {
+			return recording.Kind(player.Current()).String()
+		}),
+		"step": method(func(inputs []js.Value) interface{} {
+			return convert(player.Current())
+		}),
+		"next": method(func(inputs []js.Value) interface{} {
+			return player.Next()
+		}),
+		"prev": method(func(inputs []js.Value) interface{} {
+			return player.Prev()
+		}),
+		"continue": method(func(inputs []js.Value) interface{} {
+			return player.Continue()
+		}),
+		"reverseContinue": method(func(inputs []js.Value) interface{} {
+			return player.ReverseContinue()
+		}),
+		"seek": method(func(inputs []js.Value) interface{} {
+			return player.Seek(inputs[0].Int())
+		}),
+		"seekToToken": method(func(inputs []js.Value) interface{} {
+			return player.SeekToToken(inputs[0].Int())
+		}),
+		"seekToNode": method(func(inputs []js.Value) interface{} {
+			return player.SeekToNode(inputs[0].Int())
+		}),
+		// setFilter takes kind names like "Token" or "ExprPush"
+		"setFilter": method(func(inputs []js.Value) interface{} {
+			var kinds []golox.StepKind
+			for _, input := range inputs {
+				for kind := golox.MoveStep; kind <= golox.ValueStep; kind++ {
+					if kind.String() == input.String() {
+						kinds = append(kinds, kind)
+					}
+				}
+			}
+			player.SetFilter(kinds...)
+			return nil
+		}),
+		"setBreakpoint": method(func(inputs []js.Value) interface{} {
+			player.SetBreakpoint(inputs[0].Int(), len(inputs) < 2 || inputs[1].Bool())
+			return nil
+		}),
+	}
+}
@@ -0,0 +1,200 @@
+package golox
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+type loxList struct {
+	elements []interface{}
+}
+
+func (list *loxList) get(name Token) (interface{}, error) {
+	switch name.Lexeme {
+	case "length":
+		return &nativeFunction{"length", 0, func(args []interface{}) (interface{}, error) {
+			return len(list.elements), nil
+		}}, nil
+	case "push":
+		return &nativeFunction{"push", 1, func(args []interface{}) (interface{}, error) {
+			list.elements = append(list.elements, args[0])
+			return nil, nil
+		}}, nil
+	case "pop":
+		return &nativeFunction{"pop", 0, func(args []interface{}) (interface{}, error) {
+			if len(list.elements) == 0 {
+				return nil, fmt.Errorf("Can't pop from an empty list")
+			}
+			last := list.elements[len(list.elements)-1]
+			list.elements = list.elements[:len(list.elements)-1]
+			return last, nil
+		}}, nil
+	}
+	return nil, &RuntimeError{name, fmt.Sprintf("Lists have no method '%s'", name.Lexeme), nil}
+}
+
+// index checks that value can be used to index into the list
+func (list *loxList) index(bracket Token, value interface{}) (int, error) {
+	n, ok := value.(float64)
+	if !ok || n != math.Trunc(n) || math.IsInf(n, 0) {
+		return 0, &RuntimeError{bracket, "List index must be a whole number", nil}
+	}
+	// Compare as floats, huge indexes don't fit in an int
+	if n < 0 || n >= float64(len(list.elements)) {
+		return 0, &RuntimeError{bracket, fmt.Sprintf("List index %s is out of range for a list of length %d", stringify(n), len(list.elements)), nil}
+	}
+	return int(n), nil
+}
+
+func (list *loxList) String() string {
+	return list.stringify(make(map[interface{}]bool))
+}
+
+// stringify prints the list inside the collections in seen, which print as
+// [...] or {...} when they turn up inside themselves instead of recursing
+// forever
+func (list *loxList) stringify(seen map[interface{}]bool) string {
+	if seen[list] {
+		return "[...]"
+	}
+	seen[list] = true
+	defer delete(seen, list)
+	elements := make([]string, len(list.elements))
+	for i, element := range list.elements {
+		elements[i] = stringifyNested(element, seen)
+	}
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+// loxMap remembers the order keys were added in so keys() and printing are
+// predictable
+type loxMap struct {
+	entries map[interface{}]interface{}
+	keys    []interface{}
+}
+
+func newLoxMap() *loxMap {
+	return &loxMap{entries: make(map[interface{}]interface{})}
+}
+
+// set adds or replaces the entry for key, token is where an error is
+// reported. NaN isn't equal to itself, so it can't be a key.
+func (m *loxMap) set(token Token, key interface{}, value interface{}) error {
+	if n, ok := key.(float64); ok && math.IsNaN(n) {
+		return &RuntimeError{token, "Map keys can't be NaN", nil}
+	}
+	if _, ok := m.entries[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.entries[key] = value
+	return nil
+}
+
+func (m *loxMap) get(name Token) (interface{}, error) {
+	switch name.Lexeme {
+	case "length":
+		return &nativeFunction{"length", 0, func(args []interface{}) (interface{}, error) {
+			return len(m.keys), nil
+		}}, nil
+	case "keys":
+		return &nativeFunction{"keys", 0, func(args []interface{}) (interface{}, error) {
+			keys := make([]interface{}, len(m.keys))
+			copy(keys, m.keys)
+			return &loxList{keys}, nil
+		}}, nil
+	}
+	return nil, &RuntimeError{name, fmt.Sprintf("Maps have no method '%s'", name.Lexeme), nil}
+}
+
+func (m *loxMap) String() string {
+	return m.stringify(make(map[interface{}]bool))
+}
+
+// stringify is loxList's stringify for maps
+func (m *loxMap) stringify(seen map[interface{}]bool) string {
+	if seen[m] {
+		return "{...}"
+	}
+	seen[m] = true
+	defer delete(seen, m)
+	entries := make([]string, len(m.keys))
+	for i, key := range m.keys {
+		entries[i] = stringifyNested(key, seen) + ": " + stringifyNested(m.entries[key], seen)
+	}
+	return "{" + strings.Join(entries, ", ") + "}"
+}
+
+// stringifyElement quotes strings so they can be told apart from other values
+// when printed inside a collection
+func stringifyElement(value interface{}) string {
+	return stringifyNested(value, make(map[interface{}]bool))
+}
+
+// stringifyNested is stringifyElement for a value inside the collections
+// in seen
+func stringifyNested(value interface{}, seen map[interface{}]bool) string {
+	switch v := value.(type) {
+	case string:
+		return "\"" + v + "\""
+	case *loxList:
+		return v.stringify(seen)
+	case *loxMap:
+		return v.stringify(seen)
+	}
+	return stringify(value)
+}
+
+func (i *Interpreter) evaluateIndex(expr *IndexExpr) (interface{}, error) {
+	object, err := i.evaluate(expr.Object)
+	if err != nil {
+		return nil, err
+	}
+	index, err := i.evaluate(expr.Index)
+	if err != nil {
+		return nil, err
+	}
+
+	switch object := object.(type) {
+	case *loxList:
+		n, err := object.index(expr.Bracket, index)
+		if err != nil {
+			return nil, err
+		}
+		return object.elements[n], nil
+	case *loxMap:
+		return object.entries[index], nil
+	}
+	return nil, &RuntimeError{expr.Bracket, "Only lists and maps can be indexed", nil}
+}
+
+func (i *Interpreter) evaluateIndexSet(expr *IndexSetExpr) (interface{}, error) {
+	object, err := i.evaluate(expr.Object)
+	if err != nil {
+		return nil, err
+	}
+	index, err := i.evaluate(expr.Index)
+	if err != nil {
+		return nil, err
+	}
+	value, err := i.evaluate(expr.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	switch object := object.(type) {
+	case *loxList:
+		n, err := object.index(expr.Bracket, index)
+		if err != nil {
+			return nil, err
+		}
+		object.elements[n] = value
+		return value, nil
+	case *loxMap:
+		if err := object.set(expr.Bracket, index, value); err != nil {
+			return nil, err
+		}
+		return value, nil
+	}
+	return nil, &RuntimeError{expr.Bracket, "Only lists and maps can be indexed", nil}
+}
@@ -0,0 +1,55 @@
+package golox
+
+import "testing"
+
+func TestListIndexOutOfRange(t *testing.T) {
+	tests := []struct {
+		source  string
+		message string
+	}{
+		{"var xs = [1, 2]; print xs[2];", "List index 2 is out of range"},
+		{"var xs = [1, 2]; print xs[-1];", "List index -1 is out of range"},
+		{"var xs = [1, 2]; print xs[100000000000000000000000];", "out of range for a list of length 2"},
+		{"var xs = [1, 2]; xs[100000000000000000000000] = 3;", "out of range for a list of length 2"},
+		{"var xs = [1, 2]; xs[-100000000000000000000000] = 3;", "out of range for a list of length 2"},
+		{"var xs = [1, 2]; xs[2] = 3;", "List index 2 is out of range"},
+		{"var xs = [1, 2]; print xs[0.5];", "List index must be a whole number"},
+		{"var xs = [1, 2]; xs[0/0] = 3;", "List index must be a whole number"},
+		{"var xs = [1, 2]; print xs[1/0];", "List index must be a whole number"},
+		{"var xs = [1, 2]; xs[\"0\"] = 3;", "List index must be a whole number"},
+	}
+	for _, test := range tests {
+		_, err := runLox(t, test.source)
+		wantRuntimeError(t, test.source, err, test.message)
+	}
+}
+
+func TestListIndex(t *testing.T) {
+	source := "var xs = [1, 2, 3]; xs[0] = xs[2]; print xs[0]; print xs;"
+	out, err := runLox(t, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "3\n[3, 2, 3]\n"; out != want {
+		t.Errorf("%q printed %q, want %q", source, out, want)
+	}
+}
+
+func TestMapNaNKeys(t *testing.T) {
+	for _, source := range []string{
+		"var m = {}; m[0/0] = 1;",
+		"var m = {0/0: 1};",
+	} {
+		_, err := runLox(t, source)
+		wantRuntimeError(t, source, err, "Map keys can't be NaN")
+	}
+
+	source := "var m = {}; m[1] = \"a\"; m[1] = \"b\"; print m[0/0]; print m.length();"
+	out, err := runLox(t, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "nil\n1\n"; out != want {
+		t.Errorf("%q printed %q, want %q", source, out, want)
+	}
+}
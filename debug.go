@@ -0,0 +1,190 @@
+package golox
+
+import "sort"
+
+// DebugHook is called before each statement runs. line and column are where
+// the statement is and depth is how many calls deep it is, starting at 1 for
+// the top level of the script. Returning an error stops the script with that
+// error.
+//
+// The interpreter is paused for as long as the hook runs, which is when
+// StackTrace, StackFrame.Scopes and Evaluate can be used.
+type DebugHook func(line int, column int, depth int) error
+
+// StackFrame is a call in progress. Line is the line of the statement being
+// run in it.
+type StackFrame struct {
+	Name string
+	Line int
+	env  *environment
+	i    *Interpreter
+}
+
+// Scope is one level of variables visible from a stack frame.
+type Scope struct {
+	Name      string
+	Variables []Variable
+}
+
+// Variable is a variable in a scope, or one of the elements, entries or
+// fields of a list, map or instance. Value is printed the way print would.
+type Variable struct {
+	Name  string
+	Value string
+	value interface{}
+}
+
+type callFrame struct {
+	name string
+	line int
+	// env is where the frame was when it made the call above it
+	env *environment
+}
+
+// SetDebugHook sets the hook called before every statement, or removes it
+// when hook is nil. Call stacks are only tracked while a hook is set.
+func (i *Interpreter) SetDebugHook(hook DebugHook) {
+	i.debugHook = hook
+	i.frames = []*callFrame{{name: "script"}}
+}
+
+// StackTrace returns the calls in progress, innermost first.
+func (i *Interpreter) StackTrace() []StackFrame {
+	stack := make([]StackFrame, len(i.frames))
+	for idx, frame := range i.frames {
+		env := frame.env
+		if idx == len(i.frames)-1 {
+			env = i.environment
+		}
+		stack[len(i.frames)-1-idx] = StackFrame{frame.name, frame.line, env, i}
+	}
+	return stack
+}
+
+// Scopes lists the variables visible from the frame, innermost scope first
+// and globals last.
+func (f StackFrame) Scopes() []Scope {
+	var scopes []Scope
+	for env := f.env; env != nil; env = env.enclosing {
+		name := "Locals"
+		if env == f.i.globals {
+			name = "Globals"
+		} else if len(scopes) > 0 {
+			name = "Enclosing"
+		}
+		names := make([]string, 0, len(env.values))
+		for n := range env.values {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		variables := make([]Variable, len(names))
+		for idx, n := range names {
+			variables[idx] = newVariable(n, env.values[n])
+		}
+		scopes = append(scopes, Scope{name, variables})
+	}
+	return scopes
+}
+
+func newVariable(name string, value interface{}) Variable {
+	return Variable{name, stringifyElement(value), value}
+}
+
+// Members lists the elements of a list, the entries of a map or the fields
+// of an instance. It is empty for every other value.
+func (v Variable) Members() []Variable {
+	var members []Variable
+	switch value := v.value.(type) {
+	case *loxList:
+		for idx, element := range value.elements {
+			members = append(members, newVariable(stringify(float64(idx)), element))
+		}
+	case *loxMap:
+		for _, key := range value.keys {
+			members = append(members, newVariable(stringifyElement(key), value.entries[key]))
+		}
+	case *loxInstance:
+		names := make([]string, 0, len(value.fields))
+		for name := range value.fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			members = append(members, newVariable(name, value.fields[name]))
+		}
+	}
+	return members
+}
+
+// Truthy reports whether Lox treats the value as true.
+func (v Variable) Truthy() bool {
+	return isTruthy(v.value)
+}
+
+// Evaluate parses expression and evaluates it in the scope of frame. The
+// debug hook is not called while it runs.
+func (i *Interpreter) Evaluate(frame StackFrame, expression string) (Variable, error) {
+	var messages []string
+	displayError := func(errorMsg string) {
+		messages = append(messages, errorMsg)
+	}
+	s := scanner{source: expression}
+	tokens, _ := s.scanTokens(displayError)
+	p := parser{tokens: tokens, displayError: displayError}
+	expr := p.parse()
+	if len(messages) == 0 && !p.isAtEnd() {
+		p.error(p.peek(), "Expected end of expression")
+	}
+	if len(messages) > 0 {
+		return Variable{}, &SyntaxError{messages}
+	}
+
+	previousEnv, previousHook := i.environment, i.debugHook
+	i.environment, i.debugHook = frame.env, nil
+	i.dynamicLookup = true
+	defer func() {
+		i.environment, i.debugHook = previousEnv, previousHook
+		i.dynamicLookup = false
+	}()
+
+	value, err := i.evaluate(expr)
+	if err != nil {
+		return Variable{}, err
+	}
+	return newVariable(expression, value), nil
+}
+
+// pushFrame records a call for StackTrace, it returns false when frames
+// aren't being tracked
+func (i *Interpreter) pushFrame(function callable) bool {
+	if i.debugHook == nil {
+		return false
+	}
+	var name string
+	switch function := function.(type) {
+	case *loxFunction:
+		name = function.declaration.Name.Lexeme
+	case *loxClass:
+		name = function.name
+	case *nativeFunction:
+		name = function.name
+	}
+	i.frames[len(i.frames)-1].env = i.environment
+	i.frames = append(i.frames, &callFrame{name: name})
+	return true
+}
+
+func (i *Interpreter) popFrame() {
+	i.frames = i.frames[:len(i.frames)-1]
+}
+
+// debugStatement runs the debug hook for a statement
+func (i *Interpreter) debugStatement(stmt Stmt) error {
+	if _, ok := stmt.(*BlockStmt); ok {
+		// Stop on the statements inside blocks, not the braces
+		return nil
+	}
+	token := stmt.Token()
+	i.frames[len(i.frames)-1].line = token.Line
+	return i.debugHook(token.Line, token.End, len(i.frames))
+}
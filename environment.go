@@ -0,0 +1,51 @@
+package golox
+
+import "fmt"
+
+type environment struct {
+	values    map[string]interface{}
+	enclosing *environment
+}
+
+func newEnvironment(enclosing *environment) *environment {
+	return &environment{values: make(map[string]interface{}), enclosing: enclosing}
+}
+
+func (env *environment) define(name string, value interface{}) {
+	env.values[name] = value
+}
+
+func (env *environment) get(name Token) (interface{}, error) {
+	for e := env; e != nil; e = e.enclosing {
+		if value, ok := e.values[name.Lexeme]; ok {
+			return value, nil
+		}
+	}
+	return nil, &RuntimeError{name, fmt.Sprintf("Undefined variable '%s'", name.Lexeme), nil}
+}
+
+func (env *environment) assign(name Token, value interface{}) error {
+	for e := env; e != nil; e = e.enclosing {
+		if _, ok := e.values[name.Lexeme]; ok {
+			e.values[name.Lexeme] = value
+			return nil
+		}
+	}
+	return &RuntimeError{name, fmt.Sprintf("Undefined variable '%s'", name.Lexeme), nil}
+}
+
+func (env *environment) ancestor(distance int) *environment {
+	e := env
+	for i := 0; i < distance; i++ {
+		e = e.enclosing
+	}
+	return e
+}
+
+func (env *environment) getAt(distance int, name string) interface{} {
+	return env.ancestor(distance).values[name]
+}
+
+func (env *environment) assignAt(distance int, name Token, value interface{}) {
+	env.ancestor(distance).values[name.Lexeme] = value
+}
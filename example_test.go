@@ -0,0 +1,21 @@
+package golox_test
+
+import (
+	"fmt"
+
+	"github.com/samGbos/golox"
+)
+
+func ExampleNewLiteralExpr() {
+	number := golox.Token{Ttype: golox.Number, Lexeme: "1.5", Literal: 1.5, Line: 1, StartLine: 1, End: 3}
+	literal := golox.NewLiteralExpr(1.5, number)
+	fmt.Println(golox.ExprSource(literal), literal.Token().Line)
+	// Output: 1.5 1
+}
+
+func ExampleNewExpressionStmt() {
+	semicolon := golox.Token{Ttype: golox.Semicolon, Lexeme: ";", Line: 2, StartLine: 2}
+	stmt := golox.NewExpressionStmt(golox.NewLiteralExpr(true, golox.Token{}), semicolon)
+	fmt.Println(golox.FormatStmt(stmt), stmt.Token().Line)
+	// Output: (expr true) 2
+}
@@ -1,160 +1,297 @@
 package golox
 
+//go:generate go run ./cmd/generate-ast ast.txt ast.go
+
+// Expr is an expression in a Lox program. The node types are generated from
+// ast.txt, the methods below are the ones that differ from node to node.
+//
+// Kind says which node type an expression is. Label, Order and
+// UpdateChildExpr are for showing the tree as it is parsed, UpdateChildExpr
+// fills in the child the parser is working on. Token is the one token that
+// best stands for the expression, like a binary expression's operator, Span
+// is all of the source it was parsed from.
 type Expr interface {
-	Name() interface{}
+	Kind() ExprKind
+	Label() interface{}
 	Children() []Expr
 	UpdateChildExpr(Expr)
 	Copy() Expr
 	Order() int
 	Token() Token
+	Span() Span
+	setSpan(Span)
 }
 
-type unknownExpr struct {
-	order int
+func (expr *UnknownExpr) Label() interface{} {
+	return "??"
 }
 
-func (expr unknownExpr) Name() interface{} {
-	return "??"
+func (expr *UnknownExpr) UpdateChildExpr(child Expr) {
+	// do nothing
+}
+
+func (expr *UnknownExpr) Token() Token {
+	return Token{}
+}
+
+func (expr *BinaryExpr) Label() interface{} {
+	return expr.Operator.Lexeme
+}
+
+func (expr *BinaryExpr) UpdateChildExpr(child Expr) {
+	expr.Right = child
+}
+
+func (expr *BinaryExpr) Token() Token {
+	return expr.Operator
+}
+
+func (expr *UnaryExpr) Label() interface{} {
+	return expr.Operator.Lexeme
+}
+
+func (expr *UnaryExpr) UpdateChildExpr(child Expr) {
+	expr.Right = child
+}
+
+func (expr *UnaryExpr) Token() Token {
+	return expr.Operator
+}
+
+// NewLiteralExpr makes a LiteralExpr for value, written as token.
+func NewLiteralExpr(value interface{}, token Token) *LiteralExpr {
+	return &LiteralExpr{Value: value, token: token}
 }
 
-func (expr unknownExpr) Children() []Expr {
-	return nil
+func (expr *LiteralExpr) Label() interface{} {
+	return expr.Value
 }
 
-func (expr *unknownExpr) UpdateChildExpr(child Expr) {
+func (expr *LiteralExpr) UpdateChildExpr(child Expr) {
 	// do nothing
 }
 
-func (expr *unknownExpr) Copy() Expr {
-	return &unknownExpr{expr.order}
+func (expr *LiteralExpr) Token() Token {
+	return expr.token
 }
 
-func (expr *unknownExpr) Order() int {
-	return expr.order
+func (expr *GroupingExpr) Label() interface{} {
+	return "()"
 }
 
-func (expr *unknownExpr) Token() Token {
-	return Token{}
+func (expr *GroupingExpr) UpdateChildExpr(child Expr) {
+	expr.Expression = child
 }
 
-type binaryExpr struct {
-	left     Expr
-	operator Token
-	right    Expr
-	order    int
+func (expr *GroupingExpr) Token() Token {
+	return expr.Paren
 }
 
-func (expr binaryExpr) Name() interface{} {
-	return expr.operator.Lexeme
+func (expr *VariableExpr) Label() interface{} {
+	return expr.Name.Lexeme
 }
 
-func (expr binaryExpr) Children() []Expr {
-	return []Expr{expr.left, expr.right}
+func (expr *VariableExpr) UpdateChildExpr(child Expr) {
+	// do nothing
 }
 
-func (expr *binaryExpr) UpdateChildExpr(child Expr) {
-	expr.right = child
+func (expr *VariableExpr) Token() Token {
+	return expr.Name
 }
 
-func (expr *binaryExpr) Copy() Expr {
-	return &binaryExpr{expr.left.Copy(), expr.operator, expr.right.Copy(), expr.Order()}
+func (expr *AssignExpr) Label() interface{} {
+	return expr.Name.Lexeme + " ="
 }
 
-func (expr *binaryExpr) Order() int {
-	return expr.order
+func (expr *AssignExpr) UpdateChildExpr(child Expr) {
+	expr.Value = child
 }
 
-func (expr *binaryExpr) Token() Token {
-	return expr.operator
+func (expr *AssignExpr) Token() Token {
+	return expr.Name
 }
 
-type unaryExpr struct {
-	operator Token
-	right    Expr
-	order    int
+func (expr *LogicalExpr) Label() interface{} {
+	return expr.Operator.Lexeme
 }
 
-func (expr unaryExpr) Name() interface{} {
-	return expr.operator.Lexeme
+func (expr *LogicalExpr) UpdateChildExpr(child Expr) {
+	expr.Right = child
 }
 
-func (expr unaryExpr) Children() []Expr {
-	return []Expr{expr.right}
+func (expr *LogicalExpr) Token() Token {
+	return expr.Operator
 }
 
-func (expr *unaryExpr) UpdateChildExpr(child Expr) {
-	expr.right = child
+func (expr *CallExpr) Label() interface{} {
+	return "call"
 }
 
-func (expr *unaryExpr) Copy() Expr {
-	return &unaryExpr{expr.operator, expr.right.Copy(), expr.Order()}
+// UpdateChildExpr fills in the argument currently being parsed
+func (expr *CallExpr) UpdateChildExpr(child Expr) {
+	if len(expr.Arguments) > 0 {
+		expr.Arguments[len(expr.Arguments)-1] = child
+	}
 }
 
-func (expr *unaryExpr) Order() int {
-	return expr.order
+func (expr *CallExpr) Token() Token {
+	return expr.Paren
 }
 
-func (expr *unaryExpr) Token() Token {
-	return expr.operator
+func (expr *GetExpr) Label() interface{} {
+	return "." + expr.Name.Lexeme
 }
 
-type literalExpr struct {
-	value interface{}
-	order int
-	token Token
+func (expr *GetExpr) UpdateChildExpr(child Expr) {
+	// do nothing
 }
 
-func (expr literalExpr) Name() interface{} {
-	return expr.value
+func (expr *GetExpr) Token() Token {
+	return expr.Name
 }
 
-func (expr literalExpr) Children() []Expr {
-	return nil
+func (expr *SetExpr) Label() interface{} {
+	return "." + expr.Name.Lexeme + " ="
 }
 
-func (expr literalExpr) UpdateChildExpr(child Expr) {
+func (expr *SetExpr) UpdateChildExpr(child Expr) {
+	expr.Value = child
+}
+
+func (expr *SetExpr) Token() Token {
+	return expr.Name
+}
+
+func (expr *ThisExpr) Label() interface{} {
+	return "this"
+}
+
+func (expr *ThisExpr) UpdateChildExpr(child Expr) {
 	// do nothing
 }
 
-func (expr *literalExpr) Copy() Expr {
-	return &literalExpr{expr.value, expr.Order(), expr.Token()}
+func (expr *ThisExpr) Token() Token {
+	return expr.Keyword
 }
 
-func (expr *literalExpr) Order() int {
-	return expr.order
+func (expr *SuperExpr) Label() interface{} {
+	return "super." + expr.Method.Lexeme
 }
 
-func (expr *literalExpr) Token() Token {
-	return expr.token
+func (expr *SuperExpr) UpdateChildExpr(child Expr) {
+	// do nothing
 }
 
-type groupingExpr struct {
-	expression Expr
-	order      int
-	token      Token
+func (expr *SuperExpr) Token() Token {
+	return expr.Keyword
 }
 
-func (expr groupingExpr) Name() interface{} {
-	return "()"
+func (expr *ListExpr) Label() interface{} {
+	return "[]"
 }
 
-func (expr groupingExpr) Children() []Expr {
-	return []Expr{expr.expression}
+// UpdateChildExpr fills in the element currently being parsed
+func (expr *ListExpr) UpdateChildExpr(child Expr) {
+	if len(expr.Elements) > 0 {
+		expr.Elements[len(expr.Elements)-1] = child
+	}
 }
 
-func (expr *groupingExpr) UpdateChildExpr(child Expr) {
+func (expr *ListExpr) Token() Token {
+	return expr.Bracket
+}
+
+func (expr *MapExpr) Label() interface{} {
+	return "{}"
+}
+
+// Children alternates keys and values
+func (expr *MapExpr) Children() []Expr {
+	children := make([]Expr, 0, len(expr.Keys)+len(expr.Values))
+	for i, key := range expr.Keys {
+		children = append(children, key)
+		if i < len(expr.Values) {
+			children = append(children, expr.Values[i])
+		}
+	}
+	return children
+}
+
+// UpdateChildExpr fills in the key or value currently being parsed. A key
+// without a value yet is the one being parsed, otherwise it's the last value.
+func (expr *MapExpr) UpdateChildExpr(child Expr) {
+	if len(expr.Keys) > len(expr.Values) {
+		expr.Keys[len(expr.Keys)-1] = child
+	} else if len(expr.Values) > 0 {
+		expr.Values[len(expr.Values)-1] = child
+	}
+}
+
+func (expr *MapExpr) Token() Token {
+	return expr.Brace
+}
+
+func (expr *IndexExpr) Label() interface{} {
+	return "[i]"
+}
+
+func (expr *IndexExpr) UpdateChildExpr(child Expr) {
+	expr.Index = child
+}
+
+func (expr *IndexExpr) Token() Token {
+	return expr.Bracket
+}
+
+func (expr *IndexSetExpr) Label() interface{} {
+	return "[i] ="
+}
+
+func (expr *IndexSetExpr) UpdateChildExpr(child Expr) {
+	expr.Value = child
+}
+
+func (expr *IndexSetExpr) Token() Token {
+	return expr.Bracket
+}
+
+func (expr *PostfixExpr) Label() interface{} {
+	return expr.Operator.Lexeme
+}
+
+func (expr *PostfixExpr) UpdateChildExpr(child Expr) {
 	// do nothing
-	expr.expression = child
 }
 
-func (expr *groupingExpr) Copy() Expr {
-	return &groupingExpr{expr.expression.Copy(), expr.Order(), expr.Token()}
+func (expr *PostfixExpr) Token() Token {
+	return expr.Operator
 }
 
-func (expr *groupingExpr) Order() int {
-	return expr.order
+func (expr *ConditionalExpr) Label() interface{} {
+	return "?:"
 }
 
-func (expr *groupingExpr) Token() Token {
-	return expr.token
+// UpdateChildExpr fills in Then until the ':' has been parsed, then Else
+func (expr *ConditionalExpr) UpdateChildExpr(child Expr) {
+	if expr.Colon.Lexeme == "" {
+		expr.Then = child
+	} else {
+		expr.Else = child
+	}
+}
+
+func (expr *ConditionalExpr) Token() Token {
+	return expr.Question
+}
+
+func (expr *CommaExpr) Label() interface{} {
+	return ","
+}
+
+func (expr *CommaExpr) UpdateChildExpr(child Expr) {
+	expr.Right = child
+}
+
+func (expr *CommaExpr) Token() Token {
+	return expr.Operator
 }
@@ -0,0 +1,235 @@
+package golox
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FormatExpr prints expr as an s-expression of its labels, like
+// (+ 1 (* 2 3)).
+func FormatExpr(expr Expr) string {
+	var b strings.Builder
+	writeExpr(&b, expr)
+	return b.String()
+}
+
+func writeExpr(b *strings.Builder, expr Expr) {
+	if literal, ok := expr.(*LiteralExpr); ok {
+		b.WriteString(stringifyElement(literal.Value))
+		return
+	}
+	children := expr.Children()
+	if len(children) == 0 {
+		fmt.Fprint(b, expr.Label())
+		return
+	}
+	fmt.Fprintf(b, "(%v", expr.Label())
+	for _, child := range children {
+		b.WriteString(" ")
+		writeExpr(b, child)
+	}
+	b.WriteString(")")
+}
+
+// FormatStmts prints a program as s-expressions, a statement to a line with
+// the statements in a body indented under it.
+func FormatStmts(statements []Stmt) string {
+	f := &stmtFormatter{}
+	for _, stmt := range statements {
+		f.stmt(stmt, 0)
+	}
+	return strings.Join(f.lines, "\n")
+}
+
+// FormatStmt is FormatStmts for one statement.
+func FormatStmt(stmt Stmt) string {
+	return FormatStmts([]Stmt{stmt})
+}
+
+type stmtFormatter struct {
+	lines []string
+}
+
+func (f *stmtFormatter) line(depth int, text string) {
+	f.lines = append(f.lines, strings.Repeat("  ", depth)+text)
+}
+
+// close ends the statement on the last line
+func (f *stmtFormatter) close() {
+	f.lines[len(f.lines)-1] += ")"
+}
+
+func (f *stmtFormatter) stmt(stmt Stmt, depth int) {
+	switch stmt := stmt.(type) {
+	case *ExpressionStmt:
+		f.line(depth, "(expr "+FormatExpr(stmt.Expression)+")")
+	case *PrintStmt:
+		f.line(depth, "(print "+FormatExpr(stmt.Expression)+")")
+	case *VarStmt:
+		name := stmt.Name.Lexeme + annotation(stmt.Type)
+		if stmt.Initializer == nil {
+			f.line(depth, "(var "+name+")")
+		} else {
+			f.line(depth, "(var "+name+" "+FormatExpr(stmt.Initializer)+")")
+		}
+	case *BlockStmt:
+		f.line(depth, "(block")
+		f.body(stmt.Statements, depth+1)
+	case *IfStmt:
+		f.line(depth, "(if "+FormatExpr(stmt.Condition))
+		f.stmt(stmt.ThenBranch, depth+1)
+		if stmt.ElseBranch != nil {
+			f.stmt(stmt.ElseBranch, depth+1)
+		}
+		f.close()
+	case *WhileStmt:
+		f.line(depth, "(while "+FormatExpr(stmt.Condition))
+		f.stmt(stmt.Body, depth+1)
+		f.close()
+	case *FunctionStmt:
+		f.function(stmt, depth)
+	case *ReturnStmt:
+		if stmt.Value == nil {
+			f.line(depth, "(return)")
+		} else {
+			f.line(depth, "(return "+FormatExpr(stmt.Value)+")")
+		}
+	case *ClassStmt:
+		header := "(class " + stmt.Name.Lexeme
+		if stmt.Superclass != nil {
+			header += " < " + stmt.Superclass.Name.Lexeme
+		}
+		f.line(depth, header)
+		for _, method := range stmt.Methods {
+			f.function(method, depth+1)
+		}
+		f.close()
+	}
+}
+
+func (f *stmtFormatter) function(stmt *FunctionStmt, depth int) {
+	params := make([]string, len(stmt.Params))
+	for idx, param := range stmt.Params {
+		params[idx] = param.Lexeme + annotation(stmt.ParamTypes[idx])
+	}
+	f.line(depth, "(fun "+stmt.Name.Lexeme+" ("+strings.Join(params, " ")+")"+annotation(stmt.ReturnType))
+	f.body(stmt.Body, depth+1)
+}
+
+// body writes the statements of a block or function, whose header is on
+// the last line, and closes it
+func (f *stmtFormatter) body(statements []Stmt, depth int) {
+	for _, stmt := range statements {
+		f.stmt(stmt, depth)
+	}
+	f.close()
+}
+
+// ExprSource writes expr back out as Lox source. Parentheses only come from
+// GroupingExprs, so a tree parsed without errors prints as source that
+// parses to the same tree.
+func ExprSource(expr Expr) string {
+	return AcceptExpr[string](expr, sourcePrinter{})
+}
+
+// sourcePrinter is the ExprVisitor behind ExprSource
+type sourcePrinter struct{}
+
+func (p sourcePrinter) exprs(exprs []Expr) string {
+	sources := make([]string, len(exprs))
+	for idx, expr := range exprs {
+		sources[idx] = ExprSource(expr)
+	}
+	return strings.Join(sources, ", ")
+}
+
+func (p sourcePrinter) VisitUnknownExpr(expr *UnknownExpr) string {
+	return "?"
+}
+
+func (p sourcePrinter) VisitBinaryExpr(expr *BinaryExpr) string {
+	return ExprSource(expr.Left) + " " + expr.Operator.Lexeme + " " + ExprSource(expr.Right)
+}
+
+func (p sourcePrinter) VisitUnaryExpr(expr *UnaryExpr) string {
+	// A space keeps "- -a" from running together
+	return expr.Operator.Lexeme + " " + ExprSource(expr.Right)
+}
+
+func (p sourcePrinter) VisitLiteralExpr(expr *LiteralExpr) string {
+	if expr.token.Lexeme != "" {
+		return expr.token.Lexeme
+	}
+	if value, ok := expr.Value.(string); ok {
+		return `"` + value + `"`
+	}
+	return stringifyElement(expr.Value)
+}
+
+func (p sourcePrinter) VisitGroupingExpr(expr *GroupingExpr) string {
+	return "(" + ExprSource(expr.Expression) + ")"
+}
+
+func (p sourcePrinter) VisitVariableExpr(expr *VariableExpr) string {
+	return expr.Name.Lexeme
+}
+
+func (p sourcePrinter) VisitAssignExpr(expr *AssignExpr) string {
+	return expr.Name.Lexeme + " = " + ExprSource(expr.Value)
+}
+
+func (p sourcePrinter) VisitLogicalExpr(expr *LogicalExpr) string {
+	return ExprSource(expr.Left) + " " + expr.Operator.Lexeme + " " + ExprSource(expr.Right)
+}
+
+func (p sourcePrinter) VisitCallExpr(expr *CallExpr) string {
+	return ExprSource(expr.Callee) + "(" + p.exprs(expr.Arguments) + ")"
+}
+
+func (p sourcePrinter) VisitGetExpr(expr *GetExpr) string {
+	return ExprSource(expr.Object) + "." + expr.Name.Lexeme
+}
+
+func (p sourcePrinter) VisitSetExpr(expr *SetExpr) string {
+	return ExprSource(expr.Object) + "." + expr.Name.Lexeme + " = " + ExprSource(expr.Value)
+}
+
+func (p sourcePrinter) VisitThisExpr(expr *ThisExpr) string {
+	return "this"
+}
+
+func (p sourcePrinter) VisitSuperExpr(expr *SuperExpr) string {
+	return "super." + expr.Method.Lexeme
+}
+
+func (p sourcePrinter) VisitListExpr(expr *ListExpr) string {
+	return "[" + p.exprs(expr.Elements) + "]"
+}
+
+func (p sourcePrinter) VisitMapExpr(expr *MapExpr) string {
+	entries := make([]string, len(expr.Keys))
+	for idx, key := range expr.Keys {
+		entries[idx] = ExprSource(key) + ": " + ExprSource(expr.Values[idx])
+	}
+	return "{" + strings.Join(entries, ", ") + "}"
+}
+
+func (p sourcePrinter) VisitIndexExpr(expr *IndexExpr) string {
+	return ExprSource(expr.Object) + "[" + ExprSource(expr.Index) + "]"
+}
+
+func (p sourcePrinter) VisitIndexSetExpr(expr *IndexSetExpr) string {
+	return ExprSource(expr.Object) + "[" + ExprSource(expr.Index) + "] = " + ExprSource(expr.Value)
+}
+
+func (p sourcePrinter) VisitPostfixExpr(expr *PostfixExpr) string {
+	return ExprSource(expr.Left) + " " + expr.Operator.Lexeme
+}
+
+func (p sourcePrinter) VisitConditionalExpr(expr *ConditionalExpr) string {
+	return ExprSource(expr.Condition) + " ? " + ExprSource(expr.Then) + " : " + ExprSource(expr.Else)
+}
+
+func (p sourcePrinter) VisitCommaExpr(expr *CommaExpr) string {
+	return ExprSource(expr.Left) + ", " + ExprSource(expr.Right)
+}
@@ -0,0 +1,226 @@
+package golox
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// seedPrograms cover every part of the language, along with the mistakes
+// the scanner and parser have to recover from
+var seedPrograms = []string{
+	"",
+	"print 1 + 2 * 3 - 4 / 5;",
+	"var a = \"hello\"; var b = a + \" world\"; print b;",
+	"var s = \"a string\nspanning\nlines\"; print s;",
+	"// a comment\nvar x = 1; // trailing\nprint x;",
+	"var i = 0; while (i < 3) { print i; i = i + 1; }",
+	"for (var i = 0; i < 10; i = i + 1) { if (i == 5) print i; else print -i; }",
+	"fun add(a, b) { return a + b; } print add(1, 2);",
+	"fun counter() { var n = 0; fun inc() { n = n + 1; return n; } return inc; }",
+	"class A { init(x) { this.x = x; } get() { return this.x; } }\nclass B < A { get() { return super.get() * 2; } }\nprint B(2).get();",
+	"var xs = [1, 2, 3]; xs[0] = xs[1] + xs[2]; xs.push(4); print xs;",
+	"var m = {\"a\": 1, \"b\": [true, false, nil]}; m[\"c\"] = m; print m.keys();",
+	"print true and !false or nil == nil;",
+	"print 1 < 2 ? \"yes\" : \"no\"; print (1, 2);",
+	"var n: number = 1; fun f(a: string): string { return a; }",
+	"print \"unterminated;",
+	"var @ = 1;",
+	"* 3; == 4;",
+	"print (1 + ;",
+	"class { }",
+	"fun f(",
+	"}{)(][",
+	"1.5.x",
+}
+
+// seedExprs are expressions for the parser on its own
+var seedExprs = []string{
+	"1 + 2 * 3",
+	"(1 + 2) * 3",
+	"-(-1) - - 2",
+	"!true == false",
+	"a = b = c",
+	"a.b.c = d.e(f, g)[h]",
+	"[1, [2, 3], {\"k\": 4}][0]",
+	"x ? y ? 1 : 2 : z ? 3 : 4",
+	"(a, b), c",
+	"f(1)(2)(3)",
+	"this.x + super.y",
+	"\"multi\nline\" + \"string\"",
+	"a and b or c and d",
+	"1 >= 2 != 3 <= 4",
+	"",
+	"* 3",
+	"(1 +",
+	"[1, 2",
+	"{\"a\" 1}",
+	"a.b = ",
+}
+
+func FuzzRunScanner(f *testing.F) {
+	for _, source := range seedPrograms {
+		f.Add(source)
+	}
+	for _, source := range seedExprs {
+		f.Add(source)
+	}
+	f.Fuzz(func(t *testing.T, source string) {
+		tokens := RunScanner(source, func(string) {})
+		if len(tokens) == 0 || tokens[len(tokens)-1].Ttype != Eof {
+			t.Fatalf("tokens don't end with Eof: %v", tokens)
+		}
+		checkTokens(t, source, tokens)
+	})
+}
+
+// seedEdits are edits to seedPrograms that join, split and retype tokens,
+// including strings and comments spanning lines
+var seedEdits = []struct {
+	program int
+	edit    Edit
+}{
+	{1, Edit{6, 1, "10"}},
+	{1, Edit{8, 1, ""}},
+	{2, Edit{8, 0, "\""}},
+	{3, Edit{17, 1, " "}},
+	{4, Edit{0, 2, ""}},
+	{4, Edit{13, 0, "\n"}},
+	{6, Edit{0, 3, "while"}},
+	{7, Edit{20, 1, "a+b"}},
+	{9, Edit{30, 10, ""}},
+	{13, Edit{8, 0, "."}},
+	{15, Edit{20, 0, "\""}},
+	{22, Edit{2, 1, ""}},
+}
+
+func FuzzRunRescanner(f *testing.F) {
+	for _, seed := range seedEdits {
+		f.Add(seedPrograms[seed.program], seed.edit.Offset, seed.edit.Length, seed.edit.Text)
+	}
+	f.Fuzz(func(t *testing.T, source string, offset int, length int, text string) {
+		// Keep the edit inside the source, bad edits are tested elsewhere
+		if offset < 0 {
+			offset = -offset
+		}
+		if length < 0 {
+			length = -length
+		}
+		offset %= len(source) + 1
+		length %= len(source) - offset + 1
+		edit := Edit{offset, length, text}
+
+		tokens := RunScanner(source, func(string) {})
+		rescanned, change := RunRescanner(source, tokens, edit, func(string) {})
+		edited := edit.Apply(source)
+		if want := RunScanner(edited, func(string) {}); !reflect.DeepEqual(rescanned, want) {
+			t.Fatalf("%q with %+v rescanned to\n%v\nwant\n%v", source, edit, rescanned, want)
+		}
+
+		// Tokens outside the change are the old ones
+		if change.Start > change.OldEnd || change.Start > change.NewEnd || change.OldEnd > len(tokens) || change.NewEnd > len(rescanned) ||
+			len(tokens)-change.OldEnd != len(rescanned)-change.NewEnd {
+			t.Fatalf("Change %+v doesn't fit %d old and %d new tokens", change, len(tokens), len(rescanned))
+		}
+		for idx := 0; idx < change.Start; idx++ {
+			if rescanned[idx] != tokens[idx] {
+				t.Fatalf("Token %d before change %+v went from %+v to %+v", idx, change, tokens[idx], rescanned[idx])
+			}
+		}
+		for idx := change.OldEnd; idx < len(tokens); idx++ {
+			old, moved := tokens[idx], rescanned[idx-change.OldEnd+change.NewEnd]
+			if old.Lexeme != moved.Lexeme || old.Ttype != moved.Ttype {
+				t.Fatalf("Token %d after change %+v went from %+v to %+v", idx, change, old, moved)
+			}
+		}
+	})
+}
+
+func FuzzRunParser(f *testing.F) {
+	for _, source := range seedExprs {
+		f.Add(source)
+	}
+	for _, source := range seedPrograms {
+		f.Add(source)
+	}
+	f.Fuzz(func(t *testing.T, source string) {
+		var errors []string
+		expr := RunParser(source, func(message string) {
+			errors = append(errors, message)
+		})
+		if expr == nil {
+			t.Fatal("RunParser returned nil")
+		}
+		checkSpans(t, source, expr)
+		if len(errors) > 0 {
+			return
+		}
+
+		printed := ExprSource(expr)
+		reparsed := RunParser(printed, func(message string) {
+			errors = append(errors, message)
+		})
+		if len(errors) > 0 {
+			t.Fatalf("%q printed as %q, which doesn't parse: %v", source, printed, errors)
+		}
+		if !sameTree(expr, reparsed) {
+			t.Fatalf("%q printed as %q, which parses to %s instead of %s", source, printed, FormatExpr(reparsed), FormatExpr(expr))
+		}
+	})
+}
+
+// checkTokens checks that every token is the source between its start and
+// end
+func checkTokens(t *testing.T, source string, tokens []Token) {
+	starts := lineStarts(source)
+	for _, token := range tokens {
+		if token.StartLine < 1 || token.Line < token.StartLine || token.Line > len(starts) {
+			t.Fatalf("%+v is on lines outside the source", token)
+		}
+		start := starts[token.StartLine-1] + token.Start
+		end := starts[token.Line-1] + token.End
+		if token.Start < 0 || start > end || end > len(source) {
+			t.Fatalf("%+v is outside the source", token)
+		}
+		if source[start:end] != token.Lexeme {
+			t.Fatalf("%+v covers %q", token, source[start:end])
+		}
+	}
+}
+
+// checkSpans checks that the span of every expression parsed from source
+// is inside it
+func checkSpans(t *testing.T, source string, expr Expr) {
+	starts := lineStarts(source)
+	inspectExpr(expr, func(expr Expr) {
+		span := expr.Span()
+		if span == (Span{}) {
+			return
+		}
+		if span.StartLine < 1 || span.EndLine < span.StartLine || span.EndLine > len(starts) || span.Start < 0 {
+			t.Fatalf("%s has span %+v on lines outside the source", FormatExpr(expr), span)
+		}
+		start := starts[span.StartLine-1] + span.Start
+		end := starts[span.EndLine-1] + span.End
+		if start > end || end > len(source) {
+			t.Fatalf("%s has span %+v outside the source", FormatExpr(expr), span)
+		}
+	})
+}
+
+// sameTree reports whether a and b are the same nodes with the same labels
+func sameTree(a Expr, b Expr) bool {
+	if a.Kind() != b.Kind() || fmt.Sprint(a.Label()) != fmt.Sprint(b.Label()) {
+		return false
+	}
+	aChildren, bChildren := a.Children(), b.Children()
+	if len(aChildren) != len(bChildren) {
+		return false
+	}
+	for idx := range aChildren {
+		if !sameTree(aChildren[idx], bChildren[idx]) {
+			return false
+		}
+	}
+	return true
+}
@@ -6,12 +6,11 @@ import (
 
 var hadError bool = false
 
-func parseError(token Token, message string) {
+func parseError(token Token, message string) string {
 	if token.Ttype == Eof {
-		report(token.Line, "at end", message)
-	} else {
-		report(token.Line, fmt.Sprintf("at '%s'", token.Lexeme), message)
+		return fmt.Sprintf("Error on line %d at end: %s", token.Line, message)
 	}
+	return fmt.Sprintf("Error on line %d at '%s': %s", token.Line, token.Lexeme, message)
 }
 
 func reportError(line int, message string) {
@@ -33,22 +32,72 @@ func RunScanner(source string, displayError func(string)) []Token {
 }
 
 func RunScannerForSteps(source string, displayError func(string)) []ScannerStep {
+	return RunScannerForRecording(source, displayError).Steps()
+}
+
+// RunScannerForRecording is RunScannerForSteps with the steps kept as a
+// recording, which rebuilds them when they're asked for.
+func RunScannerForRecording(source string, displayError func(string)) *ScannerRecording {
 	s := scanner{source: source}
-	steps, err := s.scanTokensForSteps(displayError)
+	recording, err := s.scanTokensForRecording(displayError)
 	if err != nil {
 		// do nothing
 	}
-	return steps
+	return recording
+}
+
+// RunRescanner updates tokens, which were scanned from source, for an edit
+// to source without scanning all of it again. Use edit.Apply(source) for the
+// new source.
+func RunRescanner(source string, tokens []Token, edit Edit, displayError func(string)) ([]Token, TokenChange) {
+	s := scanner{source: source}
+	newTokens, change, err := s.rescanTokens(tokens, edit, displayError)
+	if err == errBadEdit {
+		displayError(err.Error())
+	}
+	return newTokens, change
 }
 
 func RunParser(source string, displayError func(string)) Expr {
 	tokens := RunScanner(source, displayError)
-	p := parser{tokens: tokens}
+	p := parser{tokens: tokens, displayError: displayError}
 	return p.parse()
 }
 
 func RunParserForSteps(source string, displayError func(string)) ([]ParserStep, []Token) {
+	recording, tokens := RunParserForRecording(source, displayError)
+	return recording.Steps(), tokens
+}
+
+// RunParserForRecording is RunParserForSteps with the steps kept as a
+// recording, which takes much less memory for big expressions.
+func RunParserForRecording(source string, displayError func(string)) (*ParserRecording, []Token) {
+	tokens := RunScanner(source, displayError)
+	p := parser{tokens: tokens, displayError: displayError}
+	return p.parseForRecording(), tokens
+}
+
+// RunPrattParserForSteps is RunParserForSteps using the table driven Pratt
+// parser for operators, with Lox's own operators when table is nil. With
+// explain set the logs also say how each operator's binding power decided
+// where it went.
+func RunPrattParserForSteps(source string, table *OperatorTable, explain bool, displayError func(string)) ([]ParserStep, []Token) {
+	recording, tokens := RunPrattParserForRecording(source, table, explain, displayError)
+	return recording.Steps(), tokens
+}
+
+// RunPrattParserForRecording is RunParserForRecording with the Pratt parser.
+func RunPrattParserForRecording(source string, table *OperatorTable, explain bool, displayError func(string)) (*ParserRecording, []Token) {
+	tokens := RunScanner(source, displayError)
+	p := parser{tokens: tokens, displayError: displayError, pratt: true, explain: explain, operators: table}
+	return p.parseForRecording(), tokens
+}
+
+// RunOptimizerForSteps parses the expression in source and rewrites it with
+// the DefaultPasses a node at a time. Each step holds the whole expression
+// after a rewrite, with logs saying which pass made it and what it did.
+func RunOptimizerForSteps(source string, displayError func(string)) ([]ParserStep, []Token) {
 	tokens := RunScanner(source, displayError)
-	p := parser{tokens: tokens}
-	return p.parseForSteps(), tokens
+	p := parser{tokens: tokens, displayError: displayError}
+	return NewPassManager().exprSteps(p.parse()), tokens
 }
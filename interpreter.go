@@ -0,0 +1,680 @@
+package golox
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"strings"
+)
+
+// RuntimeError is an error raised while running Lox code. Token is the token
+// the error is reported at.
+type RuntimeError struct {
+	Token   Token
+	Message string
+	// Err is the more specific error behind this one, like a
+	// *StackOverflowError, or nil
+	Err error
+}
+
+func (e *RuntimeError) Error() string {
+	return fmt.Sprintf("%s\n[line %d]", e.Message, e.Token.Line)
+}
+
+func (e *RuntimeError) Unwrap() error {
+	return e.Err
+}
+
+// SyntaxError is returned when source fails to scan or parse. Messages holds
+// every error that was found, in source order.
+type SyntaxError struct {
+	Messages []string
+}
+
+func (e *SyntaxError) Error() string {
+	return strings.Join(e.Messages, "\n")
+}
+
+// Interpreter runs Lox programs. Globals, including native functions, persist
+// between calls to Run so it can back a REPL or be driven from Go code.
+type Interpreter struct {
+	globals     *environment
+	environment *environment
+	// locals holds how many scopes out the variable each expression refers
+	// to is, expressions that aren't in it refer to globals
+	locals map[Expr]int
+	out    io.Writer
+	in     *bufio.Reader
+	random *rand.Rand
+	// capabilities holds the Capability flags scripts have been allowed
+	capabilities Capability
+
+	// options are the limits for the current RunWithOptions, limited is set
+	// when any of them are in use
+	options     Options
+	limited     bool
+	steps       int
+	depth       int
+	outputBytes int
+
+	debugHook DebugHook
+	frames    []*callFrame
+	// recording is set by RunForRecording
+	recording *EvaluatorRecording
+	// dynamicLookup lets Evaluate find variables that the resolver has
+	// never seen by searching the environment chain
+	dynamicLookup bool
+	// passes optimize programs once they've been checked, when it's set
+	passes       *PassManager
+	typeChecking bool
+}
+
+// NewInterpreter creates an interpreter that writes the output of print
+// statements to out. The standard library natives are defined as globals.
+func NewInterpreter(out io.Writer) *Interpreter {
+	globals := newEnvironment(nil)
+	i := &Interpreter{
+		globals:     globals,
+		environment: globals,
+		locals:      make(map[Expr]int),
+		out:         out,
+		in:          bufio.NewReader(os.Stdin),
+		random:      newRandom(),
+	}
+	i.defineStdlib()
+	return i
+}
+
+// Define makes fn callable from Lox as a global function called name.
+func (i *Interpreter) Define(name string, fn NativeFunc) {
+	i.globals.define(name, &nativeFunction{name, -1, fn})
+}
+
+// SetGlobal converts value with ToLox and stores it in the global variable
+// called name.
+func (i *Interpreter) SetGlobal(name string, value interface{}) error {
+	converted, err := ToLox(value)
+	if err != nil {
+		return err
+	}
+	i.globals.define(name, converted)
+	return nil
+}
+
+// GetGlobal returns the value of the global variable called name converted
+// with ToGo, and whether the variable is defined.
+func (i *Interpreter) GetGlobal(name string) (interface{}, bool) {
+	value, ok := i.globals.values[name]
+	if !ok {
+		return nil, false
+	}
+	return ToGo(value), true
+}
+
+// Call calls a Lox function, class or native function, such as one returned
+// by GetGlobal, with the given Go arguments.
+func (i *Interpreter) Call(fn interface{}, args ...interface{}) (interface{}, error) {
+	function, ok := fn.(callable)
+	if !ok {
+		return nil, fmt.Errorf("Can't call %s, it is not a function or class", stringify(fn))
+	}
+	arguments := make([]interface{}, len(args))
+	for idx, arg := range args {
+		converted, err := ToLox(arg)
+		if err != nil {
+			return nil, err
+		}
+		arguments[idx] = converted
+	}
+	if arity := function.arity(); arity >= 0 && arity != len(arguments) {
+		return nil, fmt.Errorf("Expected %d arguments but got %d", arity, len(arguments))
+	}
+	result, err := i.callFunction(function, arguments, Token{})
+	if err != nil {
+		return nil, err
+	}
+	return ToGo(result), nil
+}
+
+// Run scans, parses and executes source. Errors found before execution are
+// returned together as a *SyntaxError, errors during execution as a
+// *RuntimeError.
+func (i *Interpreter) Run(source string) error {
+	_, err := i.runSource(source)
+	return err
+}
+
+// RunForRecording is Run, recording each statement it runs and each value
+// it evaluates.
+func (i *Interpreter) RunForRecording(source string) (*EvaluatorRecording, error) {
+	recording := &EvaluatorRecording{}
+	i.recording = recording
+	defer func() {
+		i.recording = nil
+	}()
+	tokens, err := i.runSource(source)
+	recording.findTokens(tokens)
+	return recording, err
+}
+
+// runSource is Run, also returning the tokens source was scanned into
+func (i *Interpreter) runSource(source string) ([]Token, error) {
+	var messages []string
+	displayError := func(errorMsg string) {
+		messages = append(messages, errorMsg)
+	}
+
+	s := scanner{source: source}
+	tokens, _ := s.scanTokens(displayError)
+	p := parser{tokens: tokens, displayError: displayError}
+	statements, _ := p.parseProgram()
+	if len(messages) > 0 {
+		return tokens, &SyntaxError{messages}
+	}
+	r := newResolver(displayError)
+	r.resolve(statements)
+	if i.typeChecking && len(messages) == 0 {
+		i.reportTypes(statements, displayError)
+	}
+	if len(messages) > 0 {
+		return tokens, &SyntaxError{messages}
+	}
+	if i.passes != nil {
+		// The optimized program is made of new nodes, which need resolving
+		// again
+		statements, _ = i.passes.Run(statements)
+		r = newResolver(displayError)
+		r.resolve(statements)
+	}
+	for expr, depth := range r.locals {
+		i.locals[expr] = depth
+	}
+	return tokens, i.interpret(statements)
+}
+
+// RunReader runs a program as it's read from reader, a declaration at a
+// time. Unlike Run, the declarations before a syntax error have already run
+// by the time it's found.
+func (i *Interpreter) RunReader(reader io.Reader) error {
+	var messages []string
+	displayError := func(errorMsg string) {
+		messages = append(messages, errorMsg)
+	}
+
+	lexer := NewLexer(reader, displayError)
+	p := parser{lexer: lexer, displayError: displayError}
+	for !p.isAtEnd() {
+		stmt := p.declaration()
+		p.discardTokens()
+		if lexer.err != nil {
+			return lexer.err
+		}
+		if len(messages) > 0 {
+			return &SyntaxError{messages}
+		}
+		statements := []Stmt{stmt}
+		r := newResolver(displayError)
+		r.resolve(statements)
+		if i.typeChecking && len(messages) == 0 {
+			i.reportTypes(statements, displayError)
+		}
+		if len(messages) > 0 {
+			return &SyntaxError{messages}
+		}
+		if i.passes != nil {
+			statements, _ = i.passes.Run(statements)
+			r = newResolver(displayError)
+			r.resolve(statements)
+		}
+		for expr, depth := range r.locals {
+			i.locals[expr] = depth
+		}
+		if err := i.interpret(statements); err != nil {
+			return err
+		}
+	}
+	return lexer.err
+}
+
+// reportTypes shows what the type checker finds wrong with statements
+func (i *Interpreter) reportTypes(statements []Stmt, displayError func(string)) {
+	for _, diagnostic := range checkTypes(statements) {
+		displayError(parseError(diagnostic.Token, diagnostic.Message))
+	}
+}
+
+func (i *Interpreter) interpret(statements []Stmt) error {
+	for _, stmt := range statements {
+		err := i.execute(stmt)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (i *Interpreter) execute(stmt Stmt) error {
+	if i.limited {
+		if err := i.step(stmt.Token()); err != nil {
+			return err
+		}
+	}
+	if i.debugHook != nil {
+		if err := i.debugStatement(stmt); err != nil {
+			return err
+		}
+	}
+	if i.recording != nil {
+		i.recording.steps = append(i.recording.steps, EvalStep{Kind: StmtStep, Token: stmt.Token()})
+	}
+	switch stmt := stmt.(type) {
+	case *ExpressionStmt:
+		_, err := i.evaluate(stmt.Expression)
+		return err
+	case *PrintStmt:
+		value, err := i.evaluate(stmt.Expression)
+		if err != nil {
+			return err
+		}
+		return i.write(stmt.Keyword, stringify(value)+"\n")
+	case *VarStmt:
+		var value interface{}
+		if stmt.Initializer != nil {
+			var err error
+			value, err = i.evaluate(stmt.Initializer)
+			if err != nil {
+				return err
+			}
+		}
+		i.environment.define(stmt.Name.Lexeme, value)
+		return nil
+	case *BlockStmt:
+		return i.executeBlock(stmt.Statements, newEnvironment(i.environment))
+	case *IfStmt:
+		condition, err := i.evaluate(stmt.Condition)
+		if err != nil {
+			return err
+		}
+		if isTruthy(condition) {
+			return i.execute(stmt.ThenBranch)
+		} else if stmt.ElseBranch != nil {
+			return i.execute(stmt.ElseBranch)
+		}
+		return nil
+	case *WhileStmt:
+		for {
+			condition, err := i.evaluate(stmt.Condition)
+			if err != nil {
+				return err
+			}
+			if !isTruthy(condition) {
+				return nil
+			}
+			err = i.execute(stmt.Body)
+			if err != nil {
+				return err
+			}
+		}
+	case *FunctionStmt:
+		i.environment.define(stmt.Name.Lexeme, &loxFunction{stmt, i.environment, false})
+		return nil
+	case *ReturnStmt:
+		var value interface{}
+		if stmt.Value != nil {
+			var err error
+			value, err = i.evaluate(stmt.Value)
+			if err != nil {
+				return err
+			}
+		}
+		return &returnValue{value}
+	case *ClassStmt:
+		return i.executeClass(stmt)
+	}
+	return fmt.Errorf("Unknown statement %T", stmt)
+}
+
+func (i *Interpreter) executeBlock(statements []Stmt, env *environment) error {
+	previous := i.environment
+	i.environment = env
+	defer func() {
+		i.environment = previous
+	}()
+
+	for _, stmt := range statements {
+		err := i.execute(stmt)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (i *Interpreter) executeClass(stmt *ClassStmt) error {
+	var superclass *loxClass
+	if stmt.Superclass != nil {
+		value, err := i.evaluate(stmt.Superclass)
+		if err != nil {
+			return err
+		}
+		class, ok := value.(*loxClass)
+		if !ok {
+			return &RuntimeError{stmt.Superclass.Name, "Superclass must be a class", nil}
+		}
+		superclass = class
+	}
+
+	i.environment.define(stmt.Name.Lexeme, nil)
+	if superclass != nil {
+		i.environment = newEnvironment(i.environment)
+		i.environment.define("super", superclass)
+	}
+
+	methods := make(map[string]*loxFunction)
+	for _, method := range stmt.Methods {
+		methods[method.Name.Lexeme] = &loxFunction{method, i.environment, method.Name.Lexeme == "init"}
+	}
+	class := &loxClass{stmt.Name.Lexeme, superclass, methods}
+
+	if superclass != nil {
+		i.environment = i.environment.enclosing
+	}
+	return i.environment.assign(stmt.Name, class)
+}
+
+func (i *Interpreter) evaluate(expr Expr) (interface{}, error) {
+	if i.recording == nil {
+		return i.evaluateExpr(expr)
+	}
+	value, err := i.evaluateExpr(expr)
+	if err == nil {
+		i.recording.steps = append(i.recording.steps, EvalStep{ValueStep, expr.Token(), expr.Order(), stringify(value)})
+	}
+	return value, err
+}
+
+func (i *Interpreter) evaluateExpr(expr Expr) (interface{}, error) {
+	if i.limited {
+		if err := i.step(expr.Token()); err != nil {
+			return nil, err
+		}
+	}
+	switch expr := expr.(type) {
+	case *LiteralExpr:
+		return expr.Value, nil
+	case *GroupingExpr:
+		return i.evaluate(expr.Expression)
+	case *UnaryExpr:
+		return i.evaluateUnary(expr)
+	case *BinaryExpr:
+		return i.evaluateBinary(expr)
+	case *VariableExpr:
+		return i.lookUpVariable(expr.Name, expr)
+	case *AssignExpr:
+		value, err := i.evaluate(expr.Value)
+		if err != nil {
+			return nil, err
+		}
+		if distance, ok := i.locals[expr]; ok {
+			i.environment.assignAt(distance, expr.Name, value)
+			return value, nil
+		}
+		if i.dynamicLookup {
+			return value, i.environment.assign(expr.Name, value)
+		}
+		return value, i.globals.assign(expr.Name, value)
+	case *LogicalExpr:
+		left, err := i.evaluate(expr.Left)
+		if err != nil {
+			return nil, err
+		}
+		if expr.Operator.Ttype == OrKeyword {
+			if isTruthy(left) {
+				return left, nil
+			}
+		} else if !isTruthy(left) {
+			return left, nil
+		}
+		return i.evaluate(expr.Right)
+	case *ConditionalExpr:
+		condition, err := i.evaluate(expr.Condition)
+		if err != nil {
+			return nil, err
+		}
+		if isTruthy(condition) {
+			return i.evaluate(expr.Then)
+		}
+		return i.evaluate(expr.Else)
+	case *CommaExpr:
+		if _, err := i.evaluate(expr.Left); err != nil {
+			return nil, err
+		}
+		return i.evaluate(expr.Right)
+	case *CallExpr:
+		return i.evaluateCall(expr)
+	case *GetExpr:
+		object, err := i.evaluate(expr.Object)
+		if err != nil {
+			return nil, err
+		}
+		switch object := object.(type) {
+		case *loxInstance:
+			return object.get(expr.Name)
+		case *loxList:
+			return object.get(expr.Name)
+		case *loxMap:
+			return object.get(expr.Name)
+		}
+		return nil, &RuntimeError{expr.Name, "Only instances, lists and maps have properties", nil}
+	case *SetExpr:
+		object, err := i.evaluate(expr.Object)
+		if err != nil {
+			return nil, err
+		}
+		instance, ok := object.(*loxInstance)
+		if !ok {
+			return nil, &RuntimeError{expr.Name, "Only instances have fields", nil}
+		}
+		value, err := i.evaluate(expr.Value)
+		if err != nil {
+			return nil, err
+		}
+		instance.set(expr.Name, value)
+		return value, nil
+	case *ThisExpr:
+		return i.lookUpVariable(expr.Keyword, expr)
+	case *SuperExpr:
+		return i.evaluateSuper(expr)
+	case *ListExpr:
+		elements := make([]interface{}, len(expr.Elements))
+		for idx, element := range expr.Elements {
+			value, err := i.evaluate(element)
+			if err != nil {
+				return nil, err
+			}
+			elements[idx] = value
+		}
+		return &loxList{elements}, nil
+	case *MapExpr:
+		m := newLoxMap()
+		for idx, key := range expr.Keys {
+			k, err := i.evaluate(key)
+			if err != nil {
+				return nil, err
+			}
+			v, err := i.evaluate(expr.Values[idx])
+			if err != nil {
+				return nil, err
+			}
+			if err := m.set(expr.Brace, k, v); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	case *IndexExpr:
+		return i.evaluateIndex(expr)
+	case *IndexSetExpr:
+		return i.evaluateIndexSet(expr)
+	case *UnknownExpr:
+		return nil, &RuntimeError{expr.Token(), "Can't evaluate an incomplete expression", nil}
+	case *PostfixExpr:
+		return nil, &RuntimeError{expr.Operator, "Postfix operators only exist in custom operator tables", nil}
+	}
+	return nil, fmt.Errorf("Unknown expression %T", expr)
+}
+
+func (i *Interpreter) evaluateUnary(expr *UnaryExpr) (interface{}, error) {
+	right, err := i.evaluate(expr.Right)
+	if err != nil {
+		return nil, err
+	}
+	switch expr.Operator.Ttype {
+	case Bang:
+		return !isTruthy(right), nil
+	case Minus:
+		number, ok := right.(float64)
+		if !ok {
+			return nil, &RuntimeError{expr.Operator, "Operand must be a number", nil}
+		}
+		return -number, nil
+	}
+	return nil, &RuntimeError{expr.Operator, "Unknown unary operator", nil}
+}
+
+func (i *Interpreter) evaluateBinary(expr *BinaryExpr) (interface{}, error) {
+	left, err := i.evaluate(expr.Left)
+	if err != nil {
+		return nil, err
+	}
+	right, err := i.evaluate(expr.Right)
+	if err != nil {
+		return nil, err
+	}
+
+	switch expr.Operator.Ttype {
+	case EqualEqual:
+		return isEqual(left, right), nil
+	case BangEqual:
+		return !isEqual(left, right), nil
+	case Plus:
+		if l, ok := left.(float64); ok {
+			if r, ok := right.(float64); ok {
+				return l + r, nil
+			}
+		}
+		if l, ok := left.(string); ok {
+			if r, ok := right.(string); ok {
+				return l + r, nil
+			}
+		}
+		return nil, &RuntimeError{expr.Operator, "Operands must be two numbers or two strings", nil}
+	}
+
+	l, lok := left.(float64)
+	r, rok := right.(float64)
+	if !lok || !rok {
+		return nil, &RuntimeError{expr.Operator, "Operands must be numbers", nil}
+	}
+	switch expr.Operator.Ttype {
+	case Minus:
+		return l - r, nil
+	case Slash:
+		return l / r, nil
+	case Star:
+		return l * r, nil
+	case Greater:
+		return l > r, nil
+	case GreaterEqual:
+		return l >= r, nil
+	case Less:
+		return l < r, nil
+	case LessEqual:
+		return l <= r, nil
+	}
+	return nil, &RuntimeError{expr.Operator, "Unknown binary operator", nil}
+}
+
+func (i *Interpreter) evaluateCall(expr *CallExpr) (interface{}, error) {
+	callee, err := i.evaluate(expr.Callee)
+	if err != nil {
+		return nil, err
+	}
+	arguments := make([]interface{}, len(expr.Arguments))
+	for idx, argument := range expr.Arguments {
+		arguments[idx], err = i.evaluate(argument)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	function, ok := callee.(callable)
+	if !ok {
+		return nil, &RuntimeError{expr.Paren, "Can only call functions and classes", nil}
+	}
+	if arity := function.arity(); arity >= 0 && arity != len(arguments) {
+		return nil, &RuntimeError{expr.Paren, fmt.Sprintf("Expected %d arguments but got %d", arity, len(arguments)), nil}
+	}
+
+	result, err := i.callFunction(function, arguments, expr.Paren)
+	if _, ok := function.(*nativeFunction); ok && err != nil {
+		switch err.(type) {
+		case *RuntimeError, *StepLimitError, *OutputLimitError, *CanceledError:
+		default:
+			// Errors from native functions don't know where they were
+			// called from, so report them at the call
+			err = &RuntimeError{expr.Paren, err.Error(), nil}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// callFunction tracks how deeply calls are nested, paren is where a stack
+// overflow is reported
+func (i *Interpreter) callFunction(function callable, arguments []interface{}, paren Token) (interface{}, error) {
+	limit := i.options.MaxCallDepth
+	if limit <= 0 {
+		limit = DefaultMaxCallDepth
+	}
+	if i.depth >= limit {
+		overflow := &StackOverflowError{paren, limit}
+		return nil, &RuntimeError{paren, "Stack overflow.", overflow}
+	}
+	i.depth++
+	defer func() {
+		i.depth--
+	}()
+	if i.pushFrame(function) {
+		defer i.popFrame()
+	}
+	return function.call(i, arguments)
+}
+
+func (i *Interpreter) evaluateSuper(expr *SuperExpr) (interface{}, error) {
+	distance, ok := i.locals[expr]
+	if !ok {
+		return nil, &RuntimeError{expr.Keyword, "Can't use 'super' here", nil}
+	}
+	superclass := i.environment.getAt(distance, "super").(*loxClass)
+	// "this" is always bound in the scope just inside the one holding "super"
+	this := i.environment.getAt(distance-1, "this").(*loxInstance)
+	method := superclass.findMethod(expr.Method.Lexeme)
+	if method == nil {
+		return nil, &RuntimeError{expr.Method, fmt.Sprintf("Undefined property '%s'", expr.Method.Lexeme), nil}
+	}
+	return method.bind(this), nil
+}
+
+func (i *Interpreter) lookUpVariable(name Token, expr Expr) (interface{}, error) {
+	if distance, ok := i.locals[expr]; ok {
+		return i.environment.getAt(distance, name.Lexeme), nil
+	}
+	if i.dynamicLookup {
+		return i.environment.get(name)
+	}
+	return i.globals.get(name)
+}
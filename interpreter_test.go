@@ -0,0 +1,26 @@
+package golox
+
+import (
+	"strings"
+	"testing"
+)
+
+// runLox runs source in a new interpreter, returning what it printed
+func runLox(t *testing.T, source string) (string, error) {
+	t.Helper()
+	var out strings.Builder
+	err := NewInterpreter(&out).Run(source)
+	return out.String(), err
+}
+
+// wantRuntimeError checks that err is a *RuntimeError with message in it
+func wantRuntimeError(t *testing.T, source string, err error, message string) {
+	t.Helper()
+	runtimeErr, ok := err.(*RuntimeError)
+	if !ok {
+		t.Fatalf("%q returned %v, want a *RuntimeError", source, err)
+	}
+	if !strings.Contains(runtimeErr.Message, message) {
+		t.Fatalf("%q failed with %q, want %q", source, runtimeErr.Message, message)
+	}
+}
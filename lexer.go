@@ -0,0 +1,91 @@
+package golox
+
+import "io"
+
+// Lexer scans tokens from a reader as they're asked for, only reading as far
+// as it needs to be sure where the next token ends. Text that has been
+// scanned is let go of, so a long program doesn't have to fit in memory.
+type Lexer struct {
+	reader       io.Reader
+	scanner      scanner
+	displayError func(string)
+	buffer       []byte
+	eof          bool
+	err          error
+}
+
+// NewLexer makes a Lexer reading from reader. displayError is a callback to
+// show any errors found during scanning, like for RunScanner.
+func NewLexer(reader io.Reader, displayError func(string)) *Lexer {
+	return &Lexer{
+		reader:       reader,
+		scanner:      scanner{line: 1},
+		displayError: displayError,
+		buffer:       make([]byte, 4096),
+	}
+}
+
+// Next returns the next token. Once the reader runs out it returns an Eof
+// token every time it's called. The error is from reading, which also ends
+// the tokens, scanning errors go to displayError.
+func (l *Lexer) Next() (Token, error) {
+	s := &l.scanner
+	for len(s.tokens) == 0 {
+		if !s.isAtEnd() && l.scanToken() {
+			continue
+		}
+		if l.eof {
+			s.start = s.current
+			s.addTokenWithLiteral(Eof, "")
+			break
+		}
+		l.read()
+	}
+	token := s.tokens[0]
+	if token.Ttype != Eof {
+		s.tokens = s.tokens[:0]
+	}
+	return token, l.err
+}
+
+// scanToken scans the next token, or trivia, from what has been read. It
+// returns false without scanning anything when the token could carry on
+// past the end of the text read so far.
+func (l *Lexer) scanToken() bool {
+	s := &l.scanner
+	saved := *s
+	var messages []string
+	s.start = s.current
+	s.scanToken(func(message string) {
+		messages = append(messages, message)
+	})
+	// The scanner looks at most one character past the end of a token, and
+	// a newline ends anything but a string
+	end := len(s.source)
+	if !l.eof && (s.current >= end || (s.current+1 == end && s.source[s.current] != '\n')) {
+		*s = saved
+		return false
+	}
+	if l.displayError != nil {
+		for _, message := range messages {
+			l.displayError(message)
+		}
+	}
+	// Columns are counted from lineStart, which can go negative once the
+	// start of the line has been let go of
+	s.source = s.source[s.current:]
+	s.lineStart -= s.current
+	s.current = 0
+	return true
+}
+
+func (l *Lexer) read() {
+	n, err := l.reader.Read(l.buffer)
+	l.scanner.source += string(l.buffer[:n])
+	if err != nil {
+		l.eof = true
+		if err != io.EOF {
+			l.err = err
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package golox
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// lexAll reads every token from reader up to Eof
+func lexAll(t *testing.T, reader io.Reader) ([]Token, []string) {
+	t.Helper()
+	var messages []string
+	lexer := NewLexer(reader, func(message string) {
+		messages = append(messages, message)
+	})
+	var tokens []Token
+	for {
+		token, err := lexer.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens = append(tokens, token)
+		if token.Ttype == Eof {
+			return tokens, messages
+		}
+	}
+}
+
+func TestLexerMatchesScanner(t *testing.T) {
+	sources := append([]string{generateProgram(20)}, seedPrograms...)
+	for _, source := range sources {
+		var wantMessages []string
+		want := RunScanner(source, func(message string) {
+			wantMessages = append(wantMessages, message)
+		})
+		// Reading a byte at a time splits every token across reads
+		readers := map[string]io.Reader{
+			"whole":          strings.NewReader(source),
+			"byte at a time": iotest.OneByteReader(strings.NewReader(source)),
+			"half":           iotest.HalfReader(strings.NewReader(source)),
+		}
+		for name, reader := range readers {
+			got, messages := lexAll(t, reader)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%q read %s lexed as\n%v\nwant\n%v", source, name, got, want)
+			}
+			if !reflect.DeepEqual(messages, wantMessages) {
+				t.Errorf("%q read %s reported %q, want %q", source, name, messages, wantMessages)
+			}
+		}
+	}
+}
+
+func TestLexerEof(t *testing.T) {
+	lexer := NewLexer(strings.NewReader("a"), nil)
+	if token, _ := lexer.Next(); token.Lexeme != "a" {
+		t.Fatalf("Got %+v first, want a", token)
+	}
+	for n := 0; n < 3; n++ {
+		if token, err := lexer.Next(); token.Ttype != Eof || err != nil {
+			t.Errorf("Got %+v and %v after the end, want Eof", token, err)
+		}
+	}
+}
+
+func TestLexerReadError(t *testing.T) {
+	// The text read before the error is still scanned, like io.Reader
+	// returning data along with an error, then the tokens end
+	failure := errors.New("disk on fire")
+	lexer := NewLexer(io.MultiReader(strings.NewReader("print 1;"), iotest.ErrReader(failure)), nil)
+	var got []string
+	var err error
+	for {
+		var token Token
+		token, err = lexer.Next()
+		if token.Ttype == Eof {
+			break
+		}
+		got = append(got, token.Lexeme)
+	}
+	if err != failure {
+		t.Errorf("Failed with %v, want %v", err, failure)
+	}
+	if want := []string{"print", "1", ";"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Lexed %q, want %q", got, want)
+	}
+}
+
+func TestRunReader(t *testing.T) {
+	source := generateProgram(3)
+	want, err := runLox(t, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if err := NewInterpreter(&out).RunReader(iotest.OneByteReader(strings.NewReader(source))); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != want {
+		t.Errorf("Printed %q read a byte at a time, want %q", out.String(), want)
+	}
+
+	// Declarations before a syntax error have already run
+	out.Reset()
+	err = NewInterpreter(&out).RunReader(strings.NewReader("print 1;\nprint 2;\nprint ;\nprint 3;\n"))
+	if _, ok := err.(*SyntaxError); !ok {
+		t.Errorf("Returned %v, want a *SyntaxError", err)
+	}
+	if out.String() != "1\n2\n" {
+		t.Errorf("Printed %q before the error, want \"1\\n2\\n\"", out.String())
+	}
+}
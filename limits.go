@@ -0,0 +1,121 @@
+package golox
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// Options limits the resources a script run with RunWithOptions may use. A
+// zero field means that resource is not limited, except for MaxCallDepth.
+type Options struct {
+	// Context aborts the script with a *CanceledError when it is done
+	Context context.Context
+	// MaxSteps is the number of statements and expressions that may be
+	// executed before the script fails with a *StepLimitError
+	MaxSteps int
+	// MaxCallDepth is how deeply calls may nest before the script fails
+	// with a *RuntimeError wrapping a *StackOverflowError. Zero means
+	// DefaultMaxCallDepth.
+	MaxCallDepth int
+	// MaxOutputBytes is how much print may write before the script fails
+	// with an *OutputLimitError
+	MaxOutputBytes int
+}
+
+// StepLimitError is returned when a script executes more than
+// Options.MaxSteps steps.
+type StepLimitError struct {
+	Token Token
+	Limit int
+}
+
+func (e *StepLimitError) Error() string {
+	return fmt.Sprintf("Step limit of %d exceeded\n[line %d]", e.Limit, e.Token.Line)
+}
+
+// DefaultMaxCallDepth is the call depth limit when Options.MaxCallDepth is
+// zero, including for scripts run with Run. Calls nested much deeper than
+// this would overflow the Go stack instead.
+const DefaultMaxCallDepth = 10000
+
+// StackOverflowError is the Err of the *RuntimeError returned when calls
+// nest more than Options.MaxCallDepth deep.
+type StackOverflowError struct {
+	Token Token
+	Limit int
+}
+
+func (e *StackOverflowError) Error() string {
+	return fmt.Sprintf("Stack overflow.\n[line %d]", e.Token.Line)
+}
+
+// OutputLimitError is returned when a script prints more than
+// Options.MaxOutputBytes bytes. Output up to the limit has been written.
+type OutputLimitError struct {
+	Token Token
+	Limit int
+}
+
+func (e *OutputLimitError) Error() string {
+	return fmt.Sprintf("Output limit of %d bytes exceeded\n[line %d]", e.Limit, e.Token.Line)
+}
+
+// CanceledError is returned when Options.Context is done before the script
+// finishes. Err is the context's error.
+type CanceledError struct {
+	Token Token
+	Err   error
+}
+
+func (e *CanceledError) Error() string {
+	return fmt.Sprintf("Execution canceled: %v\n[line %d]", e.Err, e.Token.Line)
+}
+
+func (e *CanceledError) Unwrap() error {
+	return e.Err
+}
+
+// RunWithOptions is like Run, but stops the script with an error when it
+// goes over one of the limits in options.
+func (i *Interpreter) RunWithOptions(source string, options Options) error {
+	i.options = options
+	i.limited = options.Context != nil || options.MaxSteps > 0 || options.MaxCallDepth > 0 || options.MaxOutputBytes > 0
+	i.steps = 0
+	i.outputBytes = 0
+	defer func() {
+		i.options = Options{}
+		i.limited = false
+	}()
+	return i.Run(source)
+}
+
+// step counts one statement or expression against the limits, token is where
+// an error is reported
+func (i *Interpreter) step(token Token) error {
+	i.steps++
+	if i.options.MaxSteps > 0 && i.steps > i.options.MaxSteps {
+		return &StepLimitError{token, i.options.MaxSteps}
+	}
+	if i.options.Context != nil {
+		select {
+		case <-i.options.Context.Done():
+			return &CanceledError{token, i.options.Context.Err()}
+		default:
+		}
+	}
+	return nil
+}
+
+// write sends print output to the interpreter's writer, cutting it off at
+// Options.MaxOutputBytes
+func (i *Interpreter) write(token Token, s string) error {
+	if max := i.options.MaxOutputBytes; max > 0 && i.outputBytes+len(s) > max {
+		io.WriteString(i.out, s[:max-i.outputBytes])
+		i.outputBytes = max
+		return &OutputLimitError{token, max}
+	}
+	n, err := io.WriteString(i.out, s)
+	i.outputBytes += n
+	return err
+}
@@ -0,0 +1,114 @@
+package golox
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDefaultMaxCallDepth(t *testing.T) {
+	interpreter := NewInterpreter(io.Discard)
+	err := interpreter.Run("fun f(n) { return f(n + 1); } f(1);")
+
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) {
+		t.Fatalf("got %v, want a *RuntimeError", err)
+	}
+	var overflow *StackOverflowError
+	if !errors.As(err, &overflow) || overflow.Limit != DefaultMaxCallDepth {
+		t.Fatalf("got %v, want a *StackOverflowError at depth %d", err, DefaultMaxCallDepth)
+	}
+
+	// The interpreter is still usable once the stack has unwound
+	if err := interpreter.Run("fun g(n) { if (n > 0) return g(n - 1); return n; } g(100);"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// checkUsable checks that interpreter runs a script normally after hitting
+// a limit
+func checkUsable(t *testing.T, interpreter *Interpreter, out *strings.Builder) {
+	t.Helper()
+	out.Reset()
+	if err := interpreter.Run("var total = 0; for (var i = 0; i < 100; i = i + 1) total = total + i; print total;"); err != nil {
+		t.Fatalf("Run after the limit failed: %v", err)
+	}
+	if out.String() != "4950\n" {
+		t.Fatalf("Run after the limit printed %q", out.String())
+	}
+}
+
+func TestMaxCallDepth(t *testing.T) {
+	var out strings.Builder
+	interpreter := NewInterpreter(&out)
+	err := interpreter.RunWithOptions("fun f(n) { return f(n + 1); } f(1);", Options{MaxCallDepth: 50})
+	var overflow *StackOverflowError
+	if !errors.As(err, &overflow) || overflow.Limit != 50 {
+		t.Fatalf("got %v, want a *StackOverflowError at depth 50", err)
+	}
+	if err := interpreter.RunWithOptions("fun g(n) { if (n > 0) return g(n - 1); return n; } g(40);", Options{MaxCallDepth: 50}); err != nil {
+		t.Fatalf("Calls under the limit failed: %v", err)
+	}
+	checkUsable(t, interpreter, &out)
+}
+
+func TestMaxSteps(t *testing.T) {
+	var out strings.Builder
+	interpreter := NewInterpreter(&out)
+	err := interpreter.RunWithOptions("while (true) {}", Options{MaxSteps: 1000})
+	var stepErr *StepLimitError
+	if !errors.As(err, &stepErr) || stepErr.Limit != 1000 {
+		t.Fatalf("got %v, want a *StepLimitError at 1000 steps", err)
+	}
+	// Steps are counted from zero for each run
+	if err := interpreter.RunWithOptions("print 1;", Options{MaxSteps: 10}); err != nil {
+		t.Fatalf("A short script failed: %v", err)
+	}
+	checkUsable(t, interpreter, &out)
+}
+
+func TestMaxOutputBytes(t *testing.T) {
+	var out strings.Builder
+	interpreter := NewInterpreter(&out)
+	err := interpreter.RunWithOptions("while (true) print \"abc\";", Options{MaxOutputBytes: 10})
+	var outputErr *OutputLimitError
+	if !errors.As(err, &outputErr) || outputErr.Limit != 10 {
+		t.Fatalf("got %v, want an *OutputLimitError at 10 bytes", err)
+	}
+	if out.String() != "abc\nabc\nab" {
+		t.Errorf("Printed %q, want the first 10 bytes", out.String())
+	}
+	checkUsable(t, interpreter, &out)
+}
+
+func TestCanceledContext(t *testing.T) {
+	var out strings.Builder
+	interpreter := NewInterpreter(&out)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := interpreter.RunWithOptions("while (true) {}", Options{Context: ctx})
+	var canceled *CanceledError
+	if !errors.As(err, &canceled) {
+		t.Fatalf("got %v, want a *CanceledError", err)
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("%v doesn't wrap context.Canceled", err)
+	}
+	checkUsable(t, interpreter, &out)
+}
+
+func TestContextCanceledWhileRunning(t *testing.T) {
+	interpreter := NewInterpreter(io.Discard)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- interpreter.RunWithOptions("while (true) {}", Options{Context: ctx})
+	}()
+	cancel()
+	var canceled *CanceledError
+	if err := <-done; !errors.As(err, &canceled) {
+		t.Fatalf("got %v, want a *CanceledError", err)
+	}
+}
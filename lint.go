@@ -0,0 +1,778 @@
+package golox
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Severity says how much a lint Finding matters.
+type Severity int
+
+const (
+	SeverityHint Severity = iota
+	SeverityWarning
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityHint:
+		return "hint"
+	case SeverityWarning:
+		return "warning"
+	}
+	return "error"
+}
+
+// Rule is something the linter checks for. Check reports what it finds
+// through the LintContext, along with an edit fixing it when there's a
+// safe one.
+type Rule struct {
+	ID          string
+	Severity    Severity
+	Description string
+	Check       func(c *LintContext)
+}
+
+// Finding is a problem a Rule found at Token. Fix is nil when there isn't a
+// safe way to fix it.
+type Finding struct {
+	Rule     string
+	Severity Severity
+	Token    Token
+	Message  string
+	Fix      *Edit
+}
+
+// DefaultRules returns the built in rules.
+func DefaultRules() []*Rule {
+	return []*Rule{
+		{
+			ID:          "unused-var",
+			Severity:    SeverityWarning,
+			Description: "Local variables, functions and classes that are never read",
+			Check:       checkUnusedVars,
+		},
+		{
+			ID:          "unused-param",
+			Severity:    SeverityHint,
+			Description: "Parameters that are never read, unless their name starts with _",
+			Check:       checkUnusedParams,
+		},
+		{
+			ID:          "shadowed-var",
+			Severity:    SeverityHint,
+			Description: "Local declarations hiding a variable of the same name from outside",
+			Check:       checkShadowedVars,
+		},
+		{
+			ID:          "unreachable-code",
+			Severity:    SeverityWarning,
+			Description: "Statements after a return, which never run",
+			Check:       checkUnreachableCode,
+		},
+		{
+			ID:          "nil-comparison",
+			Severity:    SeverityWarning,
+			Description: "Comparing nil with a value whose type is known, which always gives the same answer",
+			Check:       checkNilComparisons,
+		},
+		{
+			ID:          "self-assign",
+			Severity:    SeverityWarning,
+			Description: "Assigning a variable or field to itself",
+			Check:       checkSelfAssignments,
+		},
+		{
+			ID:          "constant-condition",
+			Severity:    SeverityWarning,
+			Description: "Ifs whose condition is always true or always false",
+			Check:       checkConstantConditions,
+		},
+	}
+}
+
+// LintContext is the program a Rule checks, parsed and resolved.
+type LintContext struct {
+	Source string
+	Tokens []Token
+	// Statements are the statements that parsed
+	Statements []Stmt
+	References []Reference
+
+	rule     *Rule
+	findings []Finding
+	// lineStarts is the offset of the start of each line
+	lineStarts []int
+	// stmtTokens is the tokens each statement was parsed from. Statements
+	// the parser made up, like the loop a for becomes, don't have any.
+	stmtTokens map[Stmt]TokenRange
+	// listed holds the statements in the program, a block or a function
+	// body, which can be removed without leaving a hole
+	listed       map[Stmt]bool
+	declarations []lintDeclaration
+	// reads and writes count the references reading and assigning each
+	// declaration, by the token declaring it
+	reads  map[Token]int
+	writes map[Token]int
+	// types is only worked out for the rules that need it
+	types map[Expr]*Type
+}
+
+// Report records a finding of the rule being checked at token. fix can be
+// nil.
+func (c *LintContext) Report(token Token, message string, fix *Edit) {
+	c.findings = append(c.findings, Finding{c.rule.ID, c.rule.Severity, token, message, fix})
+}
+
+// Offset returns the offset into Source of a column on a line, like a
+// Token's Start on its StartLine or End on its Line.
+func (c *LintContext) Offset(line int, column int) int {
+	return c.lineStarts[line-1] + column
+}
+
+// Lint checks source against rules, returning what they found in source
+// order along with any errors in the program. Only the statements that
+// parsed are checked.
+//
+// A comment like "// lox-lint: disable=unused-var,self-assign" turns rules
+// off for its own line when it comes after code, or for the next line of
+// code when it's on a line of its own. Without a list of rules it turns all
+// of them off.
+func Lint(source string, rules []*Rule) ([]Finding, []Diagnostic) {
+	ignoreError := func(string) {}
+	s := scanner{source: source, keepTrivia: true}
+	tokens, _ := s.scanTokens(ignoreError)
+	p := parser{tokens: tokens, displayError: ignoreError, syntax: &syntaxBuilder{tokens: s.syntaxTokens}}
+	statements, _ := p.parseProgram()
+	r := newResolver(ignoreError)
+	r.resolve(statements)
+
+	var diagnostics []Diagnostic
+	diagnostics = append(diagnostics, s.diagnostics...)
+	diagnostics = append(diagnostics, p.diagnostics...)
+	diagnostics = append(diagnostics, r.diagnostics...)
+
+	c := &LintContext{
+		Source:     source,
+		Tokens:     tokens,
+		Statements: statements,
+		References: r.references,
+		lineStarts: []int{0},
+		stmtTokens: make(map[Stmt]TokenRange),
+		listed:     make(map[Stmt]bool),
+		reads:      make(map[Token]int),
+		writes:     make(map[Token]int),
+	}
+	for idx := 0; idx < len(source); idx++ {
+		if source[idx] == '\n' {
+			c.lineStarts = append(c.lineStarts, idx+1)
+		}
+	}
+	indexes := make(map[*SyntaxToken]int, len(s.syntaxTokens))
+	for idx, token := range s.syntaxTokens {
+		indexes[token] = idx
+	}
+	c.findStmtTokens(p.syntax.elements, indexes)
+	c.findDeclarations()
+
+	for _, rule := range rules {
+		c.rule = rule
+		rule.Check(c)
+	}
+
+	disabled := disabledRules(s.syntaxTokens)
+	var findings []Finding
+	for _, finding := range c.findings {
+		off := disabled[finding.Token.StartLine]
+		if !off[""] && !off[finding.Rule] {
+			findings = append(findings, finding)
+		}
+	}
+	sort.SliceStable(findings, func(a, b int) bool {
+		if findings[a].Token.StartLine != findings[b].Token.StartLine {
+			return findings[a].Token.StartLine < findings[b].Token.StartLine
+		}
+		return findings[a].Token.Start < findings[b].Token.Start
+	})
+	return findings, diagnostics
+}
+
+// ApplyFixes makes the fixes of findings to source, returning the fixed
+// source and how many fixes were made. A fix overlapping one already made
+// is left out, linting the fixed source finds it again.
+func ApplyFixes(source string, findings []Finding) (string, int) {
+	var edits []Edit
+	for _, finding := range findings {
+		if finding.Fix != nil {
+			edits = append(edits, *finding.Fix)
+		}
+	}
+	sort.SliceStable(edits, func(a, b int) bool {
+		return edits[a].Offset < edits[b].Offset
+	})
+
+	var b strings.Builder
+	made, end := 0, 0
+	for _, edit := range edits {
+		if edit.Offset < end {
+			continue
+		}
+		b.WriteString(source[end:edit.Offset])
+		b.WriteString(edit.Text)
+		end = edit.Offset + edit.Length
+		made++
+	}
+	b.WriteString(source[end:])
+	return b.String(), made
+}
+
+// lintDirective starts a comment turning rules off
+const lintDirective = "lox-lint:"
+
+// disabledRules finds the rules comments turn off, by line. The empty name
+// stands for every rule.
+func disabledRules(tokens []*SyntaxToken) map[int]map[string]bool {
+	disabled := make(map[int]map[string]bool)
+	disable := func(line int, comment string) {
+		text := strings.TrimSpace(strings.TrimPrefix(comment, "//"))
+		if !strings.HasPrefix(text, lintDirective) {
+			return
+		}
+		fields := strings.Fields(strings.TrimPrefix(text, lintDirective))
+		if len(fields) == 0 || (fields[0] != "disable" && !strings.HasPrefix(fields[0], "disable=")) {
+			return
+		}
+		if disabled[line] == nil {
+			disabled[line] = make(map[string]bool)
+		}
+		ids := strings.TrimPrefix(fields[0], "disable")
+		if ids == "" {
+			disabled[line][""] = true
+			return
+		}
+		for _, id := range strings.Split(strings.TrimPrefix(ids, "="), ",") {
+			disabled[line][id] = true
+		}
+	}
+	// Trivia after a token on its line trails it, a comment on a line of
+	// its own leads the next token
+	for _, token := range tokens {
+		for _, trivia := range token.Leading {
+			if trivia.Kind == CommentTrivia {
+				disable(token.Token.StartLine, trivia.Text)
+			}
+		}
+		for _, trivia := range token.Trailing {
+			if trivia.Kind == CommentTrivia {
+				disable(token.Token.Line, trivia.Text)
+			}
+		}
+	}
+	return disabled
+}
+
+func (c *LintContext) findStmtTokens(elements []SyntaxElement, indexes map[*SyntaxToken]int) {
+	for _, element := range elements {
+		node, ok := element.(*SyntaxNode)
+		if !ok {
+			continue
+		}
+		if tokens := node.Tokens(); node.Stmt != nil && len(tokens) > 0 {
+			c.stmtTokens[node.Stmt] = TokenRange{indexes[tokens[0]], indexes[tokens[len(tokens)-1]]}
+		}
+		c.findStmtTokens(node.Children, indexes)
+	}
+}
+
+// firstToken returns the token a statement starts at, or the one it's
+// about when it wasn't parsed from the source
+func (c *LintContext) firstToken(stmt Stmt) Token {
+	if tokens, ok := c.stmtTokens[stmt]; ok {
+		return c.Tokens[tokens.First]
+	}
+	return stmt.Token()
+}
+
+// stmtText returns the offsets of the source stmt was parsed from
+func (c *LintContext) stmtText(stmt Stmt) (int, int, bool) {
+	tokens, ok := c.stmtTokens[stmt]
+	if !ok {
+		return 0, 0, false
+	}
+	first, last := c.Tokens[tokens.First], c.Tokens[tokens.Last]
+	return c.Offset(first.StartLine, first.Start), c.Offset(last.Line, last.End), true
+}
+
+// removeStmts returns an edit removing the statements from first to last of
+// a list, along with the lines they're on when nothing else is. It's nil
+// when they weren't parsed from the source.
+func (c *LintContext) removeStmts(first Stmt, last Stmt) *Edit {
+	start, _, ok := c.stmtText(first)
+	_, end, lastOk := c.stmtText(last)
+	if !ok || !lastOk || !c.listed[first] {
+		return nil
+	}
+	lineStart := strings.LastIndexByte(c.Source[:start], '\n') + 1
+	lineEnd := len(c.Source)
+	if idx := strings.IndexByte(c.Source[end:], '\n'); idx >= 0 {
+		lineEnd = end + idx + 1
+	}
+	if strings.TrimSpace(c.Source[lineStart:start]) == "" && strings.TrimSpace(c.Source[end:lineEnd]) == "" {
+		start, end = lineStart, lineEnd
+	} else {
+		for end < len(c.Source) && (c.Source[end] == ' ' || c.Source[end] == '\t') {
+			end++
+		}
+	}
+	return &Edit{start, end - start, ""}
+}
+
+// replaceStmt returns an edit putting the source of with in place of stmt,
+// or removing stmt when with is nil
+func (c *LintContext) replaceStmt(stmt Stmt, with Stmt) *Edit {
+	if with == nil {
+		return c.removeStmts(stmt, stmt)
+	}
+	start, end, ok := c.stmtText(stmt)
+	withStart, withEnd, withOk := c.stmtText(with)
+	if !ok || !withOk {
+		return nil
+	}
+	return &Edit{start, end - start, c.Source[withStart:withEnd]}
+}
+
+// replaceExpr returns an edit putting text in place of expr
+func (c *LintContext) replaceExpr(expr Expr, text string) *Edit {
+	span := expr.Span()
+	if span.StartLine == 0 {
+		return nil
+	}
+	start, end := c.Offset(span.StartLine, span.Start), c.Offset(span.EndLine, span.End)
+	return &Edit{start, end - start, text}
+}
+
+// typeOf returns what the type checker knows of expr's type from the values
+// the program uses, leaving out type annotations
+func (c *LintContext) typeOf(expr Expr) *Type {
+	if c.types == nil {
+		c.types = make(map[Expr]*Type)
+		inferValueTypes(c.Statements, c.types)
+	}
+	return orAny(c.types[expr])
+}
+
+// usesName reports whether name is anywhere in the source of stmt. It's
+// true when stmt wasn't parsed from the source, to be safe.
+func (c *LintContext) usesName(stmt Stmt, name string) bool {
+	tokens, ok := c.stmtTokens[stmt]
+	if !ok {
+		return true
+	}
+	for _, token := range c.Tokens[tokens.First : tokens.Last+1] {
+		if token.Ttype == Identifier && token.Lexeme == name {
+			return true
+		}
+	}
+	return false
+}
+
+// lintDeclaration is a name the program declares
+type lintDeclaration struct {
+	name  Token
+	kind  SymbolKind
+	local bool
+	// stmt declares the name, or has it as a parameter
+	stmt Stmt
+	// shadowed is the declaration of the same name it hides, if any
+	shadowed *Token
+}
+
+// declarationFinder goes over a program finding its declarations and the
+// variables it assigns
+type declarationFinder struct {
+	c        *LintContext
+	globals  map[string]Token
+	scopes   []map[string]Token
+	assigned map[Token]bool
+}
+
+func (c *LintContext) findDeclarations() {
+	f := &declarationFinder{c: c, globals: make(map[string]Token), assigned: make(map[Token]bool)}
+	// Globals can be used before they're declared, so they're all in scope
+	// from the start
+	for _, stmt := range c.Statements {
+		var name Token
+		switch stmt := stmt.(type) {
+		case *VarStmt:
+			name = stmt.Name
+		case *FunctionStmt:
+			name = stmt.Name
+		case *ClassStmt:
+			name = stmt.Name
+		default:
+			continue
+		}
+		if _, ok := f.globals[name.Lexeme]; !ok {
+			f.globals[name.Lexeme] = name
+		}
+	}
+	f.stmts(c.Statements)
+
+	for _, reference := range c.References {
+		if reference.Symbol == nil {
+			continue
+		}
+		if f.assigned[reference.Token] {
+			c.writes[reference.Symbol.Name]++
+		} else {
+			c.reads[reference.Symbol.Name]++
+		}
+	}
+}
+
+func (f *declarationFinder) declare(name Token, kind SymbolKind, stmt Stmt) {
+	declaration := lintDeclaration{name: name, kind: kind, local: len(f.scopes) > 0, stmt: stmt}
+	if declaration.local {
+		for idx := len(f.scopes) - 2; idx >= 0 && declaration.shadowed == nil; idx-- {
+			if outer, ok := f.scopes[idx][name.Lexeme]; ok {
+				declaration.shadowed = &outer
+			}
+		}
+		if global, ok := f.globals[name.Lexeme]; ok && declaration.shadowed == nil {
+			declaration.shadowed = &global
+		}
+		f.scopes[len(f.scopes)-1][name.Lexeme] = name
+	}
+	f.c.declarations = append(f.c.declarations, declaration)
+}
+
+func (f *declarationFinder) beginScope() {
+	f.scopes = append(f.scopes, make(map[string]Token))
+}
+
+func (f *declarationFinder) endScope() {
+	f.scopes = f.scopes[:len(f.scopes)-1]
+}
+
+func (f *declarationFinder) stmts(statements []Stmt) {
+	for _, stmt := range statements {
+		f.c.listed[stmt] = true
+		f.stmt(stmt)
+	}
+}
+
+func (f *declarationFinder) stmt(stmt Stmt) {
+	switch stmt := stmt.(type) {
+	case *ExpressionStmt:
+		f.expr(stmt.Expression)
+	case *PrintStmt:
+		f.expr(stmt.Expression)
+	case *VarStmt:
+		f.expr(stmt.Initializer)
+		f.declare(stmt.Name, VariableSymbol, stmt)
+	case *BlockStmt:
+		f.beginScope()
+		f.stmts(stmt.Statements)
+		f.endScope()
+	case *IfStmt:
+		f.expr(stmt.Condition)
+		f.stmt(stmt.ThenBranch)
+		if stmt.ElseBranch != nil {
+			f.stmt(stmt.ElseBranch)
+		}
+	case *WhileStmt:
+		f.expr(stmt.Condition)
+		f.stmt(stmt.Body)
+	case *FunctionStmt:
+		f.declare(stmt.Name, FunctionSymbol, stmt)
+		f.function(stmt)
+	case *ReturnStmt:
+		f.expr(stmt.Value)
+	case *ClassStmt:
+		f.declare(stmt.Name, ClassSymbol, stmt)
+		for _, method := range stmt.Methods {
+			f.function(method)
+		}
+	}
+}
+
+func (f *declarationFinder) function(stmt *FunctionStmt) {
+	f.beginScope()
+	for _, param := range stmt.Params {
+		f.declare(param, ParameterSymbol, stmt)
+	}
+	f.stmts(stmt.Body)
+	f.endScope()
+}
+
+func (f *declarationFinder) expr(expr Expr) {
+	if expr == nil {
+		return
+	}
+	if assign, ok := expr.(*AssignExpr); ok {
+		f.assigned[assign.Name] = true
+	}
+	for _, child := range expr.Children() {
+		f.expr(child)
+	}
+}
+
+// inspect calls visitStmt on every statement under statements and
+// visitExpr on every expression, parents before their children. Either can
+// be nil.
+func inspect(statements []Stmt, visitStmt func(Stmt), visitExpr func(Expr)) {
+	for _, stmt := range statements {
+		if stmt == nil {
+			continue
+		}
+		if visitStmt != nil {
+			visitStmt(stmt)
+		}
+		var exprs []Expr
+		var children []Stmt
+		switch stmt := stmt.(type) {
+		case *ExpressionStmt:
+			exprs = []Expr{stmt.Expression}
+		case *PrintStmt:
+			exprs = []Expr{stmt.Expression}
+		case *VarStmt:
+			exprs = []Expr{stmt.Initializer}
+		case *BlockStmt:
+			children = stmt.Statements
+		case *IfStmt:
+			exprs = []Expr{stmt.Condition}
+			children = []Stmt{stmt.ThenBranch, stmt.ElseBranch}
+		case *WhileStmt:
+			exprs = []Expr{stmt.Condition}
+			children = []Stmt{stmt.Body}
+		case *FunctionStmt:
+			children = stmt.Body
+		case *ReturnStmt:
+			exprs = []Expr{stmt.Value}
+		case *ClassStmt:
+			if stmt.Superclass != nil {
+				exprs = []Expr{stmt.Superclass}
+			}
+			for _, method := range stmt.Methods {
+				children = append(children, method)
+			}
+		}
+		if visitExpr != nil {
+			for _, expr := range exprs {
+				inspectExpr(expr, visitExpr)
+			}
+		}
+		inspect(children, visitStmt, visitExpr)
+	}
+}
+
+func inspectExpr(expr Expr, visit func(Expr)) {
+	if expr == nil {
+		return
+	}
+	visit(expr)
+	for _, child := range expr.Children() {
+		inspectExpr(child, visit)
+	}
+}
+
+// isPure reports whether evaluating expr can't do anything but give its
+// value, so leaving it out changes nothing
+func isPure(expr Expr) bool {
+	switch expr := expr.(type) {
+	case *LiteralExpr, *VariableExpr, *ThisExpr:
+		return true
+	case *GroupingExpr:
+		return isPure(expr.Expression)
+	}
+	return false
+}
+
+// constantValue works expr out the way the optimizer would, returning its
+// value when that leaves a literal
+func constantValue(expr Expr) (interface{}, bool) {
+	for _, pass := range DefaultPasses() {
+		r := &rewriter{pass: pass}
+		expr = r.expr(expr)
+	}
+	literal, ok := expr.(*LiteralExpr)
+	if !ok {
+		return nil, false
+	}
+	return literal.Value, true
+}
+
+func checkUnusedVars(c *LintContext) {
+	for _, declaration := range c.declarations {
+		name := declaration.name
+		if !declaration.local || declaration.kind == ParameterSymbol || c.reads[name] > 0 || strings.HasPrefix(name.Lexeme, "_") {
+			continue
+		}
+		what := "variable"
+		switch declaration.kind {
+		case FunctionSymbol:
+			what = "function"
+		case ClassSymbol:
+			what = "class"
+		}
+		if c.writes[name] > 0 {
+			c.Report(name, fmt.Sprintf("Local %s '%s' is assigned but never read", what, name.Lexeme), nil)
+			continue
+		}
+		var fix *Edit
+		// Removing the declaration mustn't lose anything its initializer does
+		if stmt, ok := declaration.stmt.(*VarStmt); !ok || stmt.Initializer == nil || isPure(stmt.Initializer) {
+			fix = c.removeStmts(declaration.stmt, declaration.stmt)
+		}
+		c.Report(name, fmt.Sprintf("Local %s '%s' is never used", what, name.Lexeme), fix)
+	}
+}
+
+func checkUnusedParams(c *LintContext) {
+	for _, declaration := range c.declarations {
+		name := declaration.name
+		if declaration.kind != ParameterSymbol || c.reads[name] > 0 || strings.HasPrefix(name.Lexeme, "_") {
+			continue
+		}
+		var fix *Edit
+		// Renaming a parameter that's assigned would leave the assignments
+		// without a variable, and the new name mustn't be taken
+		if c.writes[name] == 0 && !c.usesName(declaration.stmt, "_"+name.Lexeme) {
+			fix = &Edit{c.Offset(name.StartLine, name.Start), 0, "_"}
+		}
+		c.Report(name, fmt.Sprintf("Parameter '%s' is never used", name.Lexeme), fix)
+	}
+}
+
+func checkShadowedVars(c *LintContext) {
+	for _, declaration := range c.declarations {
+		if declaration.shadowed != nil {
+			c.Report(declaration.name, fmt.Sprintf("'%s' shadows the declaration on line %d", declaration.name.Lexeme, declaration.shadowed.Line), nil)
+		}
+	}
+}
+
+func checkUnreachableCode(c *LintContext) {
+	check := func(statements []Stmt) {
+		for idx := 0; idx < len(statements)-1; idx++ {
+			if alwaysReturns(statements[idx : idx+1]) {
+				first, last := statements[idx+1], statements[len(statements)-1]
+				c.Report(c.firstToken(first), "Unreachable code after return", c.removeStmts(first, last))
+				return
+			}
+		}
+	}
+	inspect(c.Statements, func(stmt Stmt) {
+		switch stmt := stmt.(type) {
+		case *FunctionStmt:
+			check(stmt.Body)
+		case *BlockStmt:
+			// The blocks a for loop becomes hold its increment, which a
+			// return in the body doesn't make unreachable
+			if _, ok := c.stmtTokens[stmt]; ok {
+				check(stmt.Statements)
+			}
+		}
+	}, nil)
+}
+
+func checkNilComparisons(c *LintContext) {
+	inspect(c.Statements, nil, func(expr Expr) {
+		binary, ok := expr.(*BinaryExpr)
+		if !ok || (binary.Operator.Ttype != EqualEqual && binary.Operator.Ttype != BangEqual) {
+			return
+		}
+		var other Expr
+		if isNilLiteral(binary.Right) {
+			other = binary.Left
+		} else if isNilLiteral(binary.Left) {
+			other = binary.Right
+		} else {
+			return
+		}
+		otherType := c.typeOf(other)
+		if otherType.Kind == AnyType || otherType.Kind == NilType {
+			return
+		}
+		result := binary.Operator.Ttype == BangEqual
+		var fix *Edit
+		if isPure(other) {
+			fix = c.replaceExpr(binary, fmt.Sprint(result))
+		}
+		c.Report(binary.Operator, fmt.Sprintf("Comparing %s with nil is always %t", otherType, result), fix)
+	})
+}
+
+func isNilLiteral(expr Expr) bool {
+	literal, ok := expr.(*LiteralExpr)
+	return ok && literal.Value == nil
+}
+
+func checkSelfAssignments(c *LintContext) {
+	// Assignments that are a statement of their own can be removed along
+	// with it
+	statements := make(map[Expr]Stmt)
+	inspect(c.Statements, func(stmt Stmt) {
+		if stmt, ok := stmt.(*ExpressionStmt); ok {
+			statements[stmt.Expression] = stmt
+		}
+	}, func(expr Expr) {
+		var token Token
+		var target string
+		switch expr := expr.(type) {
+		case *AssignExpr:
+			value, ok := expr.Value.(*VariableExpr)
+			if !ok || value.Name.Lexeme != expr.Name.Lexeme {
+				return
+			}
+			token, target = expr.Name, expr.Name.Lexeme
+		case *SetExpr:
+			value, ok := expr.Value.(*GetExpr)
+			if !ok || value.Name.Lexeme != expr.Name.Lexeme || !sameObject(expr.Object, value.Object) {
+				return
+			}
+			token, target = expr.Name, expr.Object.Token().Lexeme+"."+expr.Name.Lexeme
+		default:
+			return
+		}
+		var fix *Edit
+		if stmt, ok := statements[expr]; ok {
+			fix = c.removeStmts(stmt, stmt)
+		}
+		c.Report(token, fmt.Sprintf("'%s' is assigned to itself", target), fix)
+	})
+}
+
+// sameObject reports whether a and b are sure to be the same object, being
+// the same variable or both this
+func sameObject(a Expr, b Expr) bool {
+	switch a := a.(type) {
+	case *VariableExpr:
+		b, ok := b.(*VariableExpr)
+		return ok && a.Name.Lexeme == b.Name.Lexeme
+	case *ThisExpr:
+		_, ok := b.(*ThisExpr)
+		return ok
+	}
+	return false
+}
+
+func checkConstantConditions(c *LintContext) {
+	inspect(c.Statements, func(stmt Stmt) {
+		ifStmt, ok := stmt.(*IfStmt)
+		if !ok {
+			return
+		}
+		value, ok := constantValue(ifStmt.Condition)
+		if !ok {
+			return
+		}
+		taken := ifStmt.ElseBranch
+		if isTruthy(value) {
+			taken = ifStmt.ThenBranch
+		}
+		c.Report(ifStmt.Keyword, fmt.Sprintf("Condition is always %t", isTruthy(value)), c.replaceStmt(ifStmt, taken))
+	}, nil)
+}
@@ -0,0 +1,179 @@
+package golox
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		// findings are "line:column rule" for each finding
+		findings []string
+		// fixed is source after ApplyFixes
+		fixed string
+	}{
+		{
+			name:     "unused variable",
+			source:   "fun f() {\n  var x = 1;\n  return 2;\n}\nprint f();\n",
+			findings: []string{"2:7 unused-var"},
+			fixed:    "fun f() {\n  return 2;\n}\nprint f();\n",
+		},
+		{
+			name:     "unused variable with side effects",
+			source:   "fun f() {\n  var x = clock();\n  return 2;\n}\nprint f();\n",
+			findings: []string{"2:7 unused-var"},
+			fixed:    "fun f() {\n  var x = clock();\n  return 2;\n}\nprint f();\n",
+		},
+		{
+			name:     "variable assigned but never read",
+			source:   "fun f() {\n  var x = 1;\n  x = 2;\n}\nf();\n",
+			findings: []string{"2:7 unused-var"},
+			fixed:    "fun f() {\n  var x = 1;\n  x = 2;\n}\nf();\n",
+		},
+		{
+			name:     "unused parameter",
+			source:   "fun f(a, b) { return b; }\nprint f(1, 2);\n",
+			findings: []string{"1:7 unused-param"},
+			fixed:    "fun f(_a, b) { return b; }\nprint f(1, 2);\n",
+		},
+		{
+			name:     "unused parameter whose new name is taken",
+			source:   "fun f(a, _a) { return _a; }\nprint f(1, 2);\n",
+			findings: []string{"1:7 unused-param"},
+			fixed:    "fun f(a, _a) { return _a; }\nprint f(1, 2);\n",
+		},
+		{
+			name:     "unused parameter whose new name is a global it reads",
+			source:   "var _a = 1;\nfun f(a) { return _a; }\nprint f(2);\n",
+			findings: []string{"2:7 unused-param"},
+			fixed:    "var _a = 1;\nfun f(a) { return _a; }\nprint f(2);\n",
+		},
+		{
+			name:     "shadowed variable",
+			source:   "var a = 1;\nfun f() {\n  var a = 2;\n  return a;\n}\nprint f() + a;\n",
+			findings: []string{"3:7 shadowed-var"},
+			fixed:    "var a = 1;\nfun f() {\n  var a = 2;\n  return a;\n}\nprint f() + a;\n",
+		},
+		{
+			name:     "unreachable code",
+			source:   "fun f() {\n  return 1;\n  print 2;\n  print 3;\n}\nprint f();\n",
+			findings: []string{"3:3 unreachable-code"},
+			fixed:    "fun f() {\n  return 1;\n}\nprint f();\n",
+		},
+		{
+			name:     "nil comparison",
+			source:   "var y = 2;\nif (y == nil) print 1;\n",
+			findings: []string{"2:7 nil-comparison"},
+			fixed:    "var y = 2;\nif (false) print 1;\n",
+		},
+		{
+			name:   "nil comparison with an annotated variable without a value",
+			source: "var x: number;\nif (x == nil) x = 1;\nprint x;\n",
+			fixed:  "var x: number;\nif (x == nil) x = 1;\nprint x;\n",
+		},
+		{
+			name:   "nil comparison with an annotated parameter",
+			source: "fun f(a: number) { return a == nil; }\nprint f(nil);\n",
+			fixed:  "fun f(a: number) { return a == nil; }\nprint f(nil);\n",
+		},
+		{
+			name:     "self assignment",
+			source:   "var a = 1;\na = a;\nprint a;\n",
+			findings: []string{"2:1 self-assign"},
+			fixed:    "var a = 1;\nprint a;\n",
+		},
+		{
+			name:     "field assigned to itself",
+			source:   "class C {\n  init() {\n    this.x = 1;\n    this.x = this.x;\n  }\n}\nC();\n",
+			findings: []string{"4:10 self-assign"},
+			fixed:    "class C {\n  init() {\n    this.x = 1;\n  }\n}\nC();\n",
+		},
+		{
+			name:     "constant condition",
+			source:   "if (1 < 2) print 1; else print 2;\n",
+			findings: []string{"1:1 constant-condition"},
+			fixed:    "print 1;\n",
+		},
+		{
+			name:     "constant condition without an else",
+			source:   "if (false) print 1;\nprint 2;\n",
+			findings: []string{"1:1 constant-condition"},
+			fixed:    "print 2;\n",
+		},
+		{
+			name:   "disabled on the same line",
+			source: "fun f(a) { return 1; } // lox-lint: disable=unused-param\nprint f(1);\n",
+			fixed:  "fun f(a) { return 1; } // lox-lint: disable=unused-param\nprint f(1);\n",
+		},
+		{
+			name:   "disabled on the next line",
+			source: "// lox-lint: disable\nfun f(a) { return 1; }\nprint f(1);\n",
+			fixed:  "// lox-lint: disable\nfun f(a) { return 1; }\nprint f(1);\n",
+		},
+		{
+			name:     "disabling another rule",
+			source:   "// lox-lint: disable=self-assign,unused-var\nfun f(a) { return 1; }\nprint f(1);\n",
+			findings: []string{"2:7 unused-param"},
+			fixed:    "// lox-lint: disable=self-assign,unused-var\nfun f(_a) { return 1; }\nprint f(1);\n",
+		},
+		{
+			name:     "disabled on another line",
+			source:   "fun f(a) { return 1; }\nprint f(1); // lox-lint: disable\n",
+			findings: []string{"1:7 unused-param"},
+			fixed:    "fun f(_a) { return 1; }\nprint f(1); // lox-lint: disable\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			findings, diagnostics := Lint(test.source, DefaultRules())
+			if len(diagnostics) > 0 {
+				t.Fatalf("Got errors %v", diagnostics)
+			}
+			var got []string
+			for _, finding := range findings {
+				got = append(got, fmt.Sprintf("%d:%d %s", finding.Token.StartLine, finding.Token.Start+1, finding.Rule))
+			}
+			if !reflect.DeepEqual(got, test.findings) {
+				t.Errorf("Found %q, want %q", got, test.findings)
+			}
+			fixed, _ := ApplyFixes(test.source, findings)
+			if fixed != test.fixed {
+				t.Errorf("Fixed to %q, want %q", fixed, test.fixed)
+			}
+			if _, err := runLox(t, fixed); err != nil {
+				t.Errorf("Fixed source failed: %v", err)
+			}
+		})
+	}
+}
+
+func TestLintErrors(t *testing.T) {
+	findings, diagnostics := Lint("var a = ;\nfun f(a, a) { return 1; }\n", DefaultRules())
+	var got []string
+	for _, diagnostic := range diagnostics {
+		got = append(got, fmt.Sprintf("%d: %s", diagnostic.Token.Line, diagnostic.Message))
+	}
+	want := []string{"1: Expected expression", "2: Already a variable with this name in this scope"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Got errors %q, want %q", got, want)
+	}
+	if len(findings) == 0 {
+		t.Error("Nothing found in the statements that parsed")
+	}
+}
+
+func TestApplyFixesOverlapping(t *testing.T) {
+	findings := []Finding{
+		{Fix: &Edit{6, 1, "z"}},
+		{Fix: &Edit{0, 5, "x"}},
+		{},
+		{Fix: &Edit{3, 2, "y"}},
+	}
+	fixed, made := ApplyFixes("abcdefgh", findings)
+	if fixed != "xfzh" || made != 2 {
+		t.Errorf("Got %q with %d fixes, want \"xfzh\" with 2", fixed, made)
+	}
+}
@@ -0,0 +1,420 @@
+package golox
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Pass is an optimization that rewrites a program into one that does the
+// same with less work. Expr and Stmt, either of which can be nil, are called
+// on each node after its children have been rewritten. They return the node
+// unchanged when there's nothing to do, and Stmt returns nil to remove a
+// statement.
+type Pass struct {
+	Name        string
+	Description string
+	Expr        func(expr Expr) Expr
+	Stmt        func(stmt Stmt) Stmt
+}
+
+// DefaultPasses returns the built in passes in the order they work best in.
+// Groupings go first so folding sees through them, and dead branches last
+// so folded conditions count.
+func DefaultPasses() []*Pass {
+	return []*Pass{
+		{
+			Name:        "remove-groupings",
+			Description: "Remove parentheses, which the shape of the tree already accounts for",
+			Expr:        removeGrouping,
+		},
+		{
+			Name:        "fold-constants",
+			Description: "Work out arithmetic and comparisons on literals before running",
+			Expr:        foldConstant,
+		},
+		{
+			Name:        "dead-branches",
+			Description: "Remove the branches of ifs, loops, ?: and logical operators whose literal conditions rule them out",
+			Expr:        pruneDeadExpr,
+			Stmt:        pruneDeadStmt,
+		},
+	}
+}
+
+func removeGrouping(expr Expr) Expr {
+	if grouping, ok := expr.(*GroupingExpr); ok {
+		return grouping.Expression
+	}
+	return expr
+}
+
+// constantFolder evaluates operators on literals, which don't need any of
+// an interpreter's state
+var constantFolder = &Interpreter{}
+
+func foldConstant(expr Expr) Expr {
+	switch expr := expr.(type) {
+	case *UnaryExpr:
+		if _, ok := expr.Right.(*LiteralExpr); !ok {
+			return expr
+		}
+	case *BinaryExpr:
+		_, left := expr.Left.(*LiteralExpr)
+		_, right := expr.Right.(*LiteralExpr)
+		if !left || !right {
+			return expr
+		}
+	default:
+		return expr
+	}
+	value, err := constantFolder.evaluateExpr(expr)
+	if err != nil {
+		// Leave it for the error to be reported when it runs
+		return expr
+	}
+	return &LiteralExpr{value, literalToken(value, expr.Token()), expr.Order(), expr.Span()}
+}
+
+// literalToken makes a token for a literal worked out from the expression
+// at token, as if value had been written there
+func literalToken(value interface{}, token Token) Token {
+	token.Literal = value
+	token.Lexeme = stringifyElement(value)
+	switch value := value.(type) {
+	case float64:
+		token.Ttype = Number
+	case string:
+		token.Ttype = StringLiteral
+	case bool:
+		token.Ttype = FalseKeyword
+		if value {
+			token.Ttype = TrueKeyword
+		}
+	default:
+		token.Ttype = NilKeyword
+	}
+	return token
+}
+
+func pruneDeadExpr(expr Expr) Expr {
+	switch expr := expr.(type) {
+	case *ConditionalExpr:
+		if condition, ok := expr.Condition.(*LiteralExpr); ok {
+			if isTruthy(condition.Value) {
+				return expr.Then
+			}
+			return expr.Else
+		}
+	case *LogicalExpr:
+		if left, ok := expr.Left.(*LiteralExpr); ok {
+			// A left operand that decides the result is the result
+			if isTruthy(left.Value) == (expr.Operator.Ttype == OrKeyword) {
+				return left
+			}
+			return expr.Right
+		}
+	}
+	return expr
+}
+
+func pruneDeadStmt(stmt Stmt) Stmt {
+	switch stmt := stmt.(type) {
+	case *IfStmt:
+		if condition, ok := stmt.Condition.(*LiteralExpr); ok {
+			if isTruthy(condition.Value) {
+				return stmt.ThenBranch
+			}
+			return stmt.ElseBranch
+		}
+	case *WhileStmt:
+		if condition, ok := stmt.Condition.(*LiteralExpr); ok && !isTruthy(condition.Value) {
+			return nil
+		}
+	}
+	return stmt
+}
+
+// Change is a rewrite a pass made, printed by FormatExpr or FormatStmt.
+// After is empty when a statement was removed. Token is the rewritten
+// node's.
+type Change struct {
+	Before string
+	After  string
+	Token  Token
+}
+
+// PassReport is what a pass did to a program.
+type PassReport struct {
+	Pass    string
+	Changes []Change
+	Before  []Stmt
+	After   []Stmt
+}
+
+// Diff returns the lines of the program printed by FormatStmts that the
+// pass changed, the old ones starting with "-" and the new with "+".
+func (r PassReport) Diff() string {
+	return diffLines(strings.Split(FormatStmts(r.Before), "\n"), strings.Split(FormatStmts(r.After), "\n"))
+}
+
+// diffLines lists the lines to remove from before and add to get after,
+// keeping the longest run of lines they have in common
+func diffLines(before []string, after []string) string {
+	// Lines the same at both ends don't need comparing
+	for len(before) > 0 && len(after) > 0 && before[0] == after[0] {
+		before, after = before[1:], after[1:]
+	}
+	for len(before) > 0 && len(after) > 0 && before[len(before)-1] == after[len(after)-1] {
+		before, after = before[:len(before)-1], after[:len(after)-1]
+	}
+
+	// common[i][j] is how many lines before[i:] and after[j:] have in common
+	common := make([][]int, len(before)+1)
+	for i := range common {
+		common[i] = make([]int, len(after)+1)
+	}
+	for i := len(before) - 1; i >= 0; i-- {
+		for j := len(after) - 1; j >= 0; j-- {
+			if before[i] == after[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else if common[i+1][j] >= common[i][j+1] {
+				common[i][j] = common[i+1][j]
+			} else {
+				common[i][j] = common[i][j+1]
+			}
+		}
+	}
+
+	var b strings.Builder
+	i, j := 0, 0
+	for i < len(before) || j < len(after) {
+		switch {
+		case i < len(before) && j < len(after) && before[i] == after[j]:
+			i++
+			j++
+		case j == len(after) || (i < len(before) && common[i+1][j] >= common[i][j+1]):
+			fmt.Fprintf(&b, "-%s\n", before[i])
+			i++
+		default:
+			fmt.Fprintf(&b, "+%s\n", after[j])
+			j++
+		}
+	}
+	return b.String()
+}
+
+// PassManager runs passes over programs before they're interpreted, see
+// Interpreter.SetPassManager. Passes can be turned off and on by name.
+type PassManager struct {
+	passes   []*Pass
+	disabled map[string]bool
+	// Report, when it's set, is called with what each pass did as soon as
+	// it's done
+	Report func(PassReport)
+}
+
+// NewPassManager makes a manager running passes in order, or the
+// DefaultPasses when there are none. They all start off enabled.
+func NewPassManager(passes ...*Pass) *PassManager {
+	if len(passes) == 0 {
+		passes = DefaultPasses()
+	}
+	return &PassManager{passes: passes, disabled: make(map[string]bool)}
+}
+
+// SetPassManager has the interpreter optimize programs with m after they've
+// been checked and before they run, or stops it when m is nil.
+func (i *Interpreter) SetPassManager(m *PassManager) {
+	i.passes = m
+}
+
+// Passes returns every pass, enabled or not, in the order they run.
+func (m *PassManager) Passes() []*Pass {
+	return m.passes
+}
+
+// Enable turns the pass called name on or off. It returns false when there
+// isn't a pass called that.
+func (m *PassManager) Enable(name string, on bool) bool {
+	for _, pass := range m.passes {
+		if pass.Name == name {
+			m.disabled[name] = !on
+			return true
+		}
+	}
+	return false
+}
+
+// Enabled reports whether the pass called name will run.
+func (m *PassManager) Enabled(name string) bool {
+	return !m.disabled[name]
+}
+
+// Run runs the enabled passes over statements, returning the optimized
+// program and a report of what each pass did. The statements themselves
+// aren't changed.
+func (m *PassManager) Run(statements []Stmt) ([]Stmt, []PassReport) {
+	var reports []PassReport
+	for _, pass := range m.passes {
+		if m.disabled[pass.Name] {
+			continue
+		}
+		r := &rewriter{pass: pass}
+		rewritten := r.stmts(statements)
+		report := PassReport{pass.Name, r.changes, statements, rewritten}
+		if m.Report != nil {
+			m.Report(report)
+		}
+		reports = append(reports, report)
+		statements = rewritten
+	}
+	return statements, reports
+}
+
+// rewriter runs a pass over a program, copying what it rewrites
+type rewriter struct {
+	pass    *Pass
+	changes []Change
+}
+
+func (r *rewriter) expr(expr Expr) Expr {
+	if expr == nil || r.pass.Expr == nil {
+		return expr
+	}
+	expr = copyExprWith(expr, r.expr)
+	rewritten := r.pass.Expr(expr)
+	if rewritten != expr {
+		r.changes = append(r.changes, Change{FormatExpr(expr), FormatExpr(rewritten), expr.Token()})
+	}
+	return rewritten
+}
+
+func (r *rewriter) stmts(statements []Stmt) []Stmt {
+	rewritten := make([]Stmt, 0, len(statements))
+	for _, stmt := range statements {
+		if stmt = r.stmt(stmt); stmt != nil {
+			rewritten = append(rewritten, stmt)
+		}
+	}
+	return rewritten
+}
+
+func (r *rewriter) stmt(stmt Stmt) Stmt {
+	switch s := stmt.(type) {
+	case *ExpressionStmt:
+		rewritten := *s
+		rewritten.Expression = r.expr(s.Expression)
+		stmt = &rewritten
+	case *PrintStmt:
+		rewritten := *s
+		rewritten.Expression = r.expr(s.Expression)
+		stmt = &rewritten
+	case *VarStmt:
+		rewritten := *s
+		rewritten.Initializer = r.expr(s.Initializer)
+		stmt = &rewritten
+	case *BlockStmt:
+		rewritten := *s
+		rewritten.Statements = r.stmts(s.Statements)
+		stmt = &rewritten
+	case *IfStmt:
+		rewritten := *s
+		rewritten.Condition = r.expr(s.Condition)
+		rewritten.ThenBranch = r.branch(s.ThenBranch)
+		if s.ElseBranch != nil {
+			rewritten.ElseBranch = r.stmt(s.ElseBranch)
+		}
+		stmt = &rewritten
+	case *WhileStmt:
+		rewritten := *s
+		rewritten.Condition = r.expr(s.Condition)
+		rewritten.Body = r.branch(s.Body)
+		stmt = &rewritten
+	case *FunctionStmt:
+		stmt = r.function(s)
+	case *ReturnStmt:
+		rewritten := *s
+		rewritten.Value = r.expr(s.Value)
+		stmt = &rewritten
+	case *ClassStmt:
+		rewritten := *s
+		rewritten.Methods = make([]*FunctionStmt, len(s.Methods))
+		for idx, method := range s.Methods {
+			rewritten.Methods[idx] = r.function(method)
+		}
+		stmt = &rewritten
+	}
+
+	if r.pass.Stmt == nil {
+		return stmt
+	}
+	rewritten := r.pass.Stmt(stmt)
+	if rewritten != stmt {
+		change := Change{Before: FormatStmt(stmt), Token: stmt.Token()}
+		if rewritten != nil {
+			change.After = FormatStmt(rewritten)
+		}
+		r.changes = append(r.changes, change)
+	}
+	return rewritten
+}
+
+// function rewrites a function's body, a function itself is never removed
+func (r *rewriter) function(stmt *FunctionStmt) *FunctionStmt {
+	rewritten := *stmt
+	rewritten.Body = r.stmts(stmt.Body)
+	return &rewritten
+}
+
+// branch rewrites a statement that can't be left out, like the body of a
+// loop, which becomes an empty block if the pass removes it
+func (r *rewriter) branch(stmt Stmt) Stmt {
+	rewritten := r.stmt(stmt)
+	if rewritten == nil {
+		return &BlockStmt{Brace: stmt.Token()}
+	}
+	return rewritten
+}
+
+// exprSteps rewrites expr with the enabled passes a node at a time, for
+// RunOptimizerForSteps. Each step holds the whole expression after a
+// rewrite, with logs saying which pass made it and what it did.
+func (m *PassManager) exprSteps(expr Expr) []ParserStep {
+	steps := []ParserStep{{Exprs: []Expr{expr.Copy()}, Logs: []string{"Parsed"}}}
+	for _, pass := range m.passes {
+		if m.disabled[pass.Name] || pass.Expr == nil {
+			continue
+		}
+		for {
+			rewritten, change := rewriteOnce(expr, pass.Expr)
+			if change == nil {
+				break
+			}
+			expr = rewritten
+			log := fmt.Sprintf("Replaced %s with %s", change.Before, change.After)
+			steps = append(steps, ParserStep{Exprs: []Expr{expr.Copy()}, Logs: []string{pass.Name, log}})
+		}
+	}
+	return steps
+}
+
+// rewriteOnce makes the first rewrite it can find under expr, children
+// before their parents, returning nil for the change when there wasn't one
+func rewriteOnce(expr Expr, rewrite func(Expr) Expr) (Expr, *Change) {
+	for _, child := range expr.Children() {
+		rewritten, change := rewriteOnce(child, rewrite)
+		if change == nil {
+			continue
+		}
+		return copyExprWith(expr, func(other Expr) Expr {
+			if other == child {
+				return rewritten
+			}
+			return other
+		}), change
+	}
+	if rewritten := rewrite(expr); rewritten != expr {
+		return rewritten, &Change{FormatExpr(expr), FormatExpr(rewritten), expr.Token()}
+	}
+	return expr, nil
+}
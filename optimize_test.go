@@ -0,0 +1,99 @@
+package golox
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// optimizerPrograms give each pass something to rewrite, including
+// rewrites that have to keep an error or a side effect where it was
+var optimizerPrograms = []string{
+	"print (1 + 2) * 3; print ((\"a\")) + \"b\";",
+	"print 2 * 3 - 4 / 8; print -(5); print !true; print 1 < 2 == true; print \"a\" + \"b\";",
+	"var x = 2; print (x + 1) * (2 * 3);",
+	"print true ? 1 : 2; print nil ? 1 : 2; print (1 > 2) ? \"no\" : \"yes\";",
+	"print false or \"right\"; print 1 and 2; print nil and clock(); print true or clock();",
+	"if (1 < 2) print \"then\"; else print \"else\";\nif (false) print 1;\nwhile (false) print 2;\nvar i = 0; while (i < 2) i = i + 1; print i;",
+	"fun f() { if (true) return 1; return 2; } print f() + (2 * 2);",
+	"print 1 / 0; print -0; print 0.1 + 0.2;",
+	"print \"a\" - 1;",
+	"print 1 + (2 * nil);",
+	"var a = [1 + 1, (2)]; print a[0 * 1] + a[1];",
+}
+
+// runPasses runs source with the passes in names enabled, returning what it
+// printed and the error it stopped with
+func runPasses(source string, names map[string]bool) (string, string) {
+	m := NewPassManager()
+	for _, pass := range m.Passes() {
+		m.Enable(pass.Name, names[pass.Name])
+	}
+	var out strings.Builder
+	interpreter := NewInterpreter(&out)
+	interpreter.SetPassManager(m)
+	err := interpreter.Run(source)
+	if err != nil {
+		return out.String(), err.Error()
+	}
+	return out.String(), ""
+}
+
+func TestPassesKeepOutput(t *testing.T) {
+	passes := DefaultPasses()
+	for _, source := range append(optimizerPrograms, seedPrograms...) {
+		var out strings.Builder
+		wantErr := ""
+		if err := NewInterpreter(&out).Run(source); err != nil {
+			wantErr = err.Error()
+		}
+		want := out.String()
+
+		// Every combination of passes, each on and off
+		for set := 0; set < 1<<len(passes); set++ {
+			names := make(map[string]bool)
+			var on []string
+			for idx, pass := range passes {
+				if set&(1<<idx) != 0 {
+					names[pass.Name] = true
+					on = append(on, pass.Name)
+				}
+			}
+			got, gotErr := runPasses(source, names)
+			if got != want || gotErr != wantErr {
+				t.Errorf("%q with %v printed %q and failed with %q, want %q and %q", source, on, got, gotErr, want, wantErr)
+			}
+		}
+	}
+}
+
+func TestPassesRewrite(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+		// changes are how many rewrites each pass made
+		changes []int
+	}{
+		{"print (1 + 2) * 3;", "(print 9)", []int{1, 2, 0}},
+		{"print x * (2 * 3);", "(print (* x 6))", []int{1, 1, 0}},
+		{"print \"a\" - 1;", "(print (- \"a\" 1))", []int{0, 0, 0}},
+		{"if (1 < 2) print 1; else print 2;", "(print 1)", []int{0, 1, 1}},
+		{"while (false) print 1;\nprint nil or 2;", "(print 2)", []int{0, 0, 2}},
+		{"print true ? a : b;", "(print a)", []int{0, 0, 1}},
+	}
+	for _, test := range tests {
+		p := parser{tokens: RunScanner(test.source, func(string) {}), displayError: func(string) {}}
+		statements, _ := p.parseProgram()
+		optimized, reports := NewPassManager().Run(statements)
+		if got := FormatStmts(optimized); got != test.want {
+			t.Errorf("%q optimized to\n%s\nwant\n%s", test.source, got, test.want)
+		}
+		var changes []int
+		for _, report := range reports {
+			changes = append(changes, len(report.Changes))
+		}
+		if fmt.Sprint(changes) != fmt.Sprint(test.changes) {
+			t.Errorf("%q had %v changes, want %v", test.source, changes, test.changes)
+		}
+	}
+}
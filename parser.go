@@ -2,11 +2,14 @@ package golox
 
 import (
 	"errors"
+	"fmt"
 )
 
 type ParserStep struct {
 	Exprs []Expr
 	Logs  []string
+	// Current is the index of the next token to be consumed
+	Current int
 }
 
 type parser struct {
@@ -14,23 +17,75 @@ type parser struct {
 	current         int
 	expressionCount int
 	exprs           []Expr
-	steps           []ParserStep
+	calculateSteps  bool
+	recording       *ParserRecording
+	exprCells       []*exprCell
+	logCell         *logCell
+	frozen          map[Expr]Expr
 	logs            []string
+	displayError    func(string)
+	errorCount      int
+	diagnostics     []Diagnostic
+	// syntax is set when a concrete syntax tree is wanted as well
+	syntax *syntaxBuilder
+	// nodeTokens is set when the tokens each expression was parsed from
+	// are wanted, it's keyed by Order()
+	nodeTokens map[int]TokenRange
+	// pratt parses operators with the table driven parser in pratt.go,
+	// explain adds its binding power decisions to the logs
+	pratt     bool
+	explain   bool
+	operators *OperatorTable
+	// lexer is where tokens come from when they aren't all scanned up
+	// front, they're read as the parser gets to them
+	lexer *Lexer
 }
 
 func (p *parser) parse() Expr {
 	p.current = 0
 	p.expressionCount = 0
 	p.expression()
+	if len(p.exprs) == 0 {
+		return &UnknownExpr{}
+	}
 	return p.exprs[0]
 }
 
-func (p *parser) parseForSteps() []ParserStep {
+func (p *parser) parseForRecording() *ParserRecording {
 	p.current = 0
 	p.expressionCount = 0
-	p.steps = append(p.steps, ParserStep{Exprs: copyExprs(p.exprs), Logs: copyLogs(p.logs)})
+	p.calculateSteps = true
+	p.recording = &ParserRecording{}
+	p.addStep(MoveStep)
 	p.expression()
-	return p.steps
+	return p.recording
+}
+
+// parseProgram parses a list of declarations up to the end of the tokens,
+// skipping ahead to the next statement whenever one fails to parse
+func (p *parser) parseProgram() ([]Stmt, error) {
+	p.current = 0
+	p.expressionCount = 0
+	var statements []Stmt
+	for !p.isAtEnd() {
+		stmt := p.declaration()
+		if stmt != nil {
+			statements = append(statements, stmt)
+		}
+	}
+	if p.errorCount > 0 {
+		return statements, errors.New("Error during parsing")
+	}
+	return statements, nil
+}
+
+// discardTokens lets go of the tokens parsed so far, apart from the last one
+// which previous() may still be asked for
+func (p *parser) discardTokens() {
+	if p.current > 1 {
+		p.tokens = append(p.tokens[:0], p.tokens[p.current-1:]...)
+		p.current = 1
+	}
 }
 
 func copyExprs(exprs []Expr) []Expr {
@@ -40,21 +95,29 @@ func copyExprs(exprs []Expr) []Expr {
 	}
 	return es
 }
-func copyLogs(logs []string) []string {
-	ls := make([]string, len(logs))
-	copy(ls, logs)
-	return ls
+func copyExprsWith(exprs []Expr, copyChild func(Expr) Expr) []Expr {
+	es := make([]Expr, len(exprs))
+	for i, e := range exprs {
+		es[i] = copyChild(e)
+	}
+	return es
+}
+
+func (p *parser) addStep(kind StepKind) {
+	if p.calculateSteps {
+		p.recordStep(kind)
+	}
 }
 
 func (p *parser) addLog(log string) {
 	p.logs = append(p.logs, log)
-	p.steps = append(p.steps, ParserStep{Exprs: copyExprs(p.exprs), Logs: copyLogs(p.logs)})
+	p.addStep(LogPushStep)
 }
 
 func (p *parser) popLog() {
 	newSize := len(p.logs) - 1
 	p.logs = p.logs[:newSize]
-	p.steps = append(p.steps, ParserStep{Exprs: copyExprs(p.exprs), Logs: copyLogs(p.logs)})
+	p.addStep(LogPopStep)
 }
 
 func (p *parser) addExpr(expr Expr) {
@@ -63,7 +126,7 @@ func (p *parser) addExpr(expr Expr) {
 		exprToUpdate.UpdateChildExpr(expr)
 	}
 	p.exprs = append(p.exprs, expr)
-	p.steps = append(p.steps, ParserStep{Exprs: copyExprs(p.exprs), Logs: copyLogs(p.logs)})
+	p.addStep(ExprPushStep)
 }
 
 func (p *parser) getExpr() Expr {
@@ -74,43 +137,548 @@ func (p *parser) getExpr() Expr {
 func (p *parser) popExpr() Expr {
 	newSize := len(p.exprs) - 1
 	expr := p.exprs[len(p.exprs)-1]
+	if p.calculateSteps {
+		// It can have been finished off since the last step, and whatever
+		// it becomes a child of shares its frozen copy
+		p.freeze(expr)
+	}
 	p.exprs = p.exprs[:newSize]
 	if len(p.exprs) > 0 {
-		p.steps = append(p.steps, ParserStep{Exprs: copyExprs(p.exprs), Logs: copyLogs(p.logs)})
+		p.addStep(ExprPopStep)
 	}
 	return expr
 }
 
+func (p *parser) declaration() Stmt {
+	mark := p.mark()
+	var stmt Stmt
+	var err error
+	var kind string
+	if p.match([]TokenType{ClassKeyword}) {
+		kind = "Class"
+		stmt, err = p.classDeclaration()
+	} else if p.match([]TokenType{FunKeyword}) {
+		kind = "Function"
+		stmt, err = p.function("function")
+	} else if p.match([]TokenType{VarKeyword}) {
+		kind = "Var"
+		stmt, err = p.varDeclaration()
+	} else {
+		stmt, err = p.statement()
+	}
+	if err != nil {
+		p.synchronize()
+		p.finishNode(mark, "Error", nil, nil)
+		return nil
+	}
+	if kind != "" {
+		p.finishNode(mark, kind, nil, stmt)
+	}
+	return stmt
+}
+
+func (p *parser) classDeclaration() (Stmt, error) {
+	name, err := p.consume(Identifier, "Expected class name")
+	if err != nil {
+		return nil, err
+	}
+
+	var superclass *VariableExpr
+	if p.match([]TokenType{Less}) {
+		mark := p.mark()
+		superName, err := p.consume(Identifier, "Expected superclass name")
+		if err != nil {
+			return nil, err
+		}
+		superclass = &VariableExpr{superName, p.exprCount(), spanOf(superName, superName)}
+		p.finishNode(mark, "Variable", superclass, nil)
+	}
+
+	_, err = p.consume(LeftBrace, "Expected '{' before class body")
+	if err != nil {
+		return nil, err
+	}
+	var methods []*FunctionStmt
+	for !p.check(RightBrace) && !p.isAtEnd() {
+		mark := p.mark()
+		method, err := p.function("method")
+		if err != nil {
+			return nil, err
+		}
+		p.finishNode(mark, "Method", nil, method)
+		methods = append(methods, method)
+	}
+	_, err = p.consume(RightBrace, "Expected '}' after class body")
+	if err != nil {
+		return nil, err
+	}
+	return &ClassStmt{name, superclass, methods}, nil
+}
+
+// kind is used in error messages to distinguish functions from methods
+func (p *parser) function(kind string) (*FunctionStmt, error) {
+	name, err := p.consume(Identifier, "Expected "+kind+" name")
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.consume(LeftParen, "Expected '(' after "+kind+" name")
+	if err != nil {
+		return nil, err
+	}
+	var params []Token
+	var paramTypes []Token
+	if !p.check(RightParen) {
+		for {
+			if len(params) >= 255 {
+				p.error(p.peek(), "Can't have more than 255 parameters")
+			}
+			param, err := p.consume(Identifier, "Expected parameter name")
+			if err != nil {
+				return nil, err
+			}
+			paramType, err := p.typeAnnotation()
+			if err != nil {
+				return nil, err
+			}
+			params = append(params, param)
+			paramTypes = append(paramTypes, paramType)
+			if !p.match([]TokenType{Comma}) {
+				break
+			}
+		}
+	}
+	_, err = p.consume(RightParen, "Expected ')' after parameters")
+	if err != nil {
+		return nil, err
+	}
+	returnType, err := p.typeAnnotation()
+	if err != nil {
+		return nil, err
+	}
+	mark := p.mark()
+	_, err = p.consume(LeftBrace, "Expected '{' before "+kind+" body")
+	if err != nil {
+		return nil, err
+	}
+	body, err := p.block()
+	if err != nil {
+		return nil, err
+	}
+	p.finishNode(mark, "Block", nil, nil)
+	return &FunctionStmt{name, params, body, paramTypes, returnType}, nil
+}
+
+func (p *parser) varDeclaration() (Stmt, error) {
+	keyword := p.previous()
+	name, err := p.consume(Identifier, "Expected variable name")
+	if err != nil {
+		return nil, err
+	}
+	varType, err := p.typeAnnotation()
+	if err != nil {
+		return nil, err
+	}
+
+	var initializer Expr
+	if p.match([]TokenType{Equal}) {
+		initializer, err = p.expressionTree()
+		if err != nil {
+			return nil, err
+		}
+	}
+	_, err = p.consume(Semicolon, "Expected ';' after variable declaration")
+	if err != nil {
+		return nil, err
+	}
+	return &VarStmt{keyword, name, initializer, varType}, nil
+}
+
+// typeAnnotation parses the ": type" that can follow a variable or
+// parameter name or a function's parameters, returning the zero Token when
+// there isn't one. Types are names, checked by the type checker, or nil.
+func (p *parser) typeAnnotation() (Token, error) {
+	if !p.match([]TokenType{Colon}) {
+		return Token{}, nil
+	}
+	if p.match([]TokenType{NilKeyword}) {
+		return p.previous(), nil
+	}
+	return p.consume(Identifier, "Expected type after ':'")
+}
+
+func (p *parser) statement() (Stmt, error) {
+	mark := p.mark()
+	var stmt Stmt
+	var err error
+	var kind string
+	if p.match([]TokenType{ForKeyword}) {
+		kind = "For"
+		stmt, err = p.forStatement()
+	} else if p.match([]TokenType{IfKeyword}) {
+		kind = "If"
+		stmt, err = p.ifStatement()
+	} else if p.match([]TokenType{PrintKeyword}) {
+		kind = "Print"
+		stmt, err = p.printStatement()
+	} else if p.match([]TokenType{ReturnKeyword}) {
+		kind = "Return"
+		stmt, err = p.returnStatement()
+	} else if p.match([]TokenType{WhileKeyword}) {
+		kind = "While"
+		stmt, err = p.whileStatement()
+	} else if p.match([]TokenType{LeftBrace}) {
+		kind = "Block"
+		brace := p.previous()
+		var statements []Stmt
+		statements, err = p.block()
+		if err == nil {
+			stmt = &BlockStmt{brace, statements}
+		}
+	} else {
+		kind = "Expression"
+		stmt, err = p.expressionStatement()
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.finishNode(mark, kind, nil, stmt)
+	return stmt, nil
+}
+
+// forStatement desugars a for loop into a while loop wrapped in blocks
+func (p *parser) forStatement() (Stmt, error) {
+	keyword := p.previous()
+	_, err := p.consume(LeftParen, "Expected '(' after 'for'")
+	if err != nil {
+		return nil, err
+	}
+
+	var initializer Stmt
+	if p.match([]TokenType{Semicolon}) {
+		initializer = nil
+	} else if p.match([]TokenType{VarKeyword}) {
+		initializer, err = p.varDeclaration()
+	} else {
+		initializer, err = p.expressionStatement()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var condition Expr
+	if !p.check(Semicolon) {
+		condition, err = p.expressionTree()
+		if err != nil {
+			return nil, err
+		}
+	}
+	_, err = p.consume(Semicolon, "Expected ';' after loop condition")
+	if err != nil {
+		return nil, err
+	}
+
+	var increment Expr
+	if !p.check(RightParen) {
+		increment, err = p.expressionTree()
+		if err != nil {
+			return nil, err
+		}
+	}
+	_, err = p.consume(RightParen, "Expected ')' after for clauses")
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := p.statement()
+	if err != nil {
+		return nil, err
+	}
+
+	if increment != nil {
+		body = &BlockStmt{keyword, []Stmt{body, &ExpressionStmt{increment, increment.Token()}}}
+	}
+	if condition == nil {
+		condition = &LiteralExpr{true, keyword, p.exprCount(), Span{}}
+	}
+	body = &WhileStmt{keyword, condition, body}
+	if initializer != nil {
+		body = &BlockStmt{keyword, []Stmt{initializer, body}}
+	}
+	return body, nil
+}
+
+func (p *parser) ifStatement() (Stmt, error) {
+	keyword := p.previous()
+	_, err := p.consume(LeftParen, "Expected '(' after 'if'")
+	if err != nil {
+		return nil, err
+	}
+	condition, err := p.expressionTree()
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.consume(RightParen, "Expected ')' after if condition")
+	if err != nil {
+		return nil, err
+	}
+
+	thenBranch, err := p.statement()
+	if err != nil {
+		return nil, err
+	}
+	var elseBranch Stmt
+	if p.match([]TokenType{ElseKeyword}) {
+		elseBranch, err = p.statement()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &IfStmt{keyword, condition, thenBranch, elseBranch}, nil
+}
+
+func (p *parser) printStatement() (Stmt, error) {
+	keyword := p.previous()
+	value, err := p.expressionTree()
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.consume(Semicolon, "Expected ';' after value")
+	if err != nil {
+		return nil, err
+	}
+	return &PrintStmt{keyword, value}, nil
+}
+
+func (p *parser) returnStatement() (Stmt, error) {
+	keyword := p.previous()
+	var value Expr
+	var err error
+	if !p.check(Semicolon) {
+		value, err = p.expressionTree()
+		if err != nil {
+			return nil, err
+		}
+	}
+	_, err = p.consume(Semicolon, "Expected ';' after return value")
+	if err != nil {
+		return nil, err
+	}
+	return &ReturnStmt{keyword, value}, nil
+}
+
+func (p *parser) whileStatement() (Stmt, error) {
+	keyword := p.previous()
+	_, err := p.consume(LeftParen, "Expected '(' after 'while'")
+	if err != nil {
+		return nil, err
+	}
+	condition, err := p.expressionTree()
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.consume(RightParen, "Expected ')' after condition")
+	if err != nil {
+		return nil, err
+	}
+	body, err := p.statement()
+	if err != nil {
+		return nil, err
+	}
+	return &WhileStmt{keyword, condition, body}, nil
+}
+
+func (p *parser) block() ([]Stmt, error) {
+	var statements []Stmt
+	for !p.check(RightBrace) && !p.isAtEnd() {
+		stmt := p.declaration()
+		if stmt != nil {
+			statements = append(statements, stmt)
+		}
+	}
+	_, err := p.consume(RightBrace, "Expected '}' after block")
+	if err != nil {
+		return nil, err
+	}
+	return statements, nil
+}
+
+func (p *parser) expressionStatement() (Stmt, error) {
+	token := p.peek()
+	expr, err := p.expressionTree()
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.consume(Semicolon, "Expected ';' after expression")
+	if err != nil {
+		return nil, err
+	}
+	return &ExpressionStmt{expr, token}, nil
+}
+
+// expressionTree parses a whole expression and takes it off the expression
+// stack. Errors inside the expression have already been reported, the
+// returned error only tells the caller to synchronize.
+func (p *parser) expressionTree() (Expr, error) {
+	errorCount := p.errorCount
+	p.expression()
+	expr := p.popExpr()
+	if p.errorCount > errorCount {
+		return expr, errors.New("Invalid expression")
+	}
+	return expr, nil
+}
+
 func (p *parser) expression() {
 	p.addLog("Searching for expresssion")
+	p.comma()
+	p.popLog()
+}
+
+// comma parses a C-style comma operator, which binds looser than everything
+// else. Arguments and collection elements start at assignment() instead so
+// their commas still separate them.
+func (p *parser) comma() {
+	p.addLog("Searching for comma or higher")
+	mark := p.mark()
+	p.assignment()
+
+	for p.match([]TokenType{Comma}) {
+		operator := p.previous()
+		right := UnknownExpr{p.exprCount(), Span{}}
+		p.addExpr(&CommaExpr{p.popExpr(), operator, &right, p.exprCount(), Span{}})
+		p.assignment()
+		p.popExpr()
+		p.finishExpr(mark)
+	}
+	p.popLog()
+}
+
+func (p *parser) assignment() {
+	p.addLog("Searching for assignment or higher")
+	mark := p.mark()
+	p.conditional()
+
+	if p.match([]TokenType{Equal}) {
+		equals := p.previous()
+		switch target := p.popExpr().(type) {
+		case *VariableExpr:
+			value := UnknownExpr{p.exprCount(), Span{}}
+			p.addExpr(&AssignExpr{target.Name, &value, p.exprCount(), Span{}})
+			p.assignment()
+			p.popExpr()
+			p.finishExpr(mark)
+		case *GetExpr:
+			value := UnknownExpr{p.exprCount(), Span{}}
+			p.addExpr(&SetExpr{target.Object, target.Name, &value, p.exprCount(), Span{}})
+			p.assignment()
+			p.popExpr()
+			p.finishExpr(mark)
+		case *IndexExpr:
+			value := UnknownExpr{p.exprCount(), Span{}}
+			p.addExpr(&IndexSetExpr{target.Object, target.Bracket, target.Index, &value, p.exprCount(), Span{}})
+			p.assignment()
+			p.popExpr()
+			p.finishExpr(mark)
+		default:
+			// Keep parsing so the value's tokens are consumed, the value
+			// takes the invalid target's place on the stack
+			p.error(equals, "Invalid assignment target")
+			p.assignment()
+			p.finishNode(mark, "Error", nil, nil)
+		}
+	}
+	p.popLog()
+}
+
+// conditional parses cond ? a : b. The else branch is parsed at this level,
+// so a ? b : c ? d : e groups as a ? b : (c ? d : e).
+func (p *parser) conditional() {
+	p.addLog("Searching for conditional or higher")
+	mark := p.mark()
+	if p.pratt {
+		p.infix(0)
+	} else {
+		p.or()
+	}
+
+	if p.match([]TokenType{Question}) {
+		then := UnknownExpr{p.exprCount(), Span{}}
+		conditional := &ConditionalExpr{Condition: p.popExpr(), Question: p.previous(), Then: &then, Else: &UnknownExpr{p.exprCount(), Span{}}, order: p.exprCount()}
+		p.addExpr(conditional)
+		p.expression()
+		p.popExpr()
+		// Without the ':' the else branch stays unknown
+		if colon, err := p.consume(Colon, "Expected ':' after then branch of conditional expression"); err == nil {
+			conditional.Colon = colon
+			conditional.Else = &UnknownExpr{p.exprCount(), Span{}}
+			p.conditional()
+			p.popExpr()
+		}
+		p.finishExpr(mark)
+	}
+	p.popLog()
+}
+
+func (p *parser) or() {
+	p.addLog("Searching for or or higher")
+	mark := p.mark()
+	p.and()
+
+	for p.match([]TokenType{OrKeyword}) {
+		operator := p.previous()
+		right := UnknownExpr{p.exprCount(), Span{}}
+		p.addExpr(&LogicalExpr{p.popExpr(), operator, &right, p.exprCount(), Span{}})
+		p.and()
+		p.popExpr()
+		p.finishExpr(mark)
+	}
+	p.popLog()
+}
+
+func (p *parser) and() {
+	p.addLog("Searching for and or higher")
+	mark := p.mark()
 	p.equality()
+
+	for p.match([]TokenType{AndKeyword}) {
+		operator := p.previous()
+		right := UnknownExpr{p.exprCount(), Span{}}
+		p.addExpr(&LogicalExpr{p.popExpr(), operator, &right, p.exprCount(), Span{}})
+		p.equality()
+		p.popExpr()
+		p.finishExpr(mark)
+	}
 	p.popLog()
 }
 
 func (p *parser) equality() {
 	p.addLog("Searching for equality or higher")
+	mark := p.mark()
 	p.comparison()
 
 	for p.match([]TokenType{BangEqual, EqualEqual}) {
 		operator := p.previous()
-		right := unknownExpr{p.exprCount()}
-		p.addExpr(&binaryExpr{p.popExpr(), operator, &right, p.exprCount()})
+		right := UnknownExpr{p.exprCount(), Span{}}
+		p.addExpr(&BinaryExpr{p.popExpr(), operator, &right, p.exprCount(), Span{}})
 		p.comparison()
 		p.popExpr()
+		p.finishExpr(mark)
 	}
 	p.popLog()
 }
 
 func (p *parser) comparison() {
 	p.addLog("Searching for comparison or higher")
+	mark := p.mark()
 	p.addition()
 
 	for p.match([]TokenType{Greater, GreaterEqual, Less, LessEqual}) {
 		operator := p.previous()
-		right := unknownExpr{p.exprCount()}
-		p.addExpr(&binaryExpr{p.popExpr(), operator, &right, p.exprCount()})
+		right := UnknownExpr{p.exprCount(), Span{}}
+		p.addExpr(&BinaryExpr{p.popExpr(), operator, &right, p.exprCount(), Span{}})
 		p.addition()
 		p.popExpr()
+		p.finishExpr(mark)
 	}
 	p.popLog()
 
@@ -119,16 +687,18 @@ func (p *parser) comparison() {
 func (p *parser) addition() {
 	p.addLog("Searching for addition or higher")
 
+	mark := p.mark()
 	p.multiplication()
 
 	for p.match([]TokenType{Minus, Plus}) {
 		operator := p.previous()
 		// For the visualization I want the parent to appear before the unknown value,
 		// so tweak the orders to make it look that way
-		right := unknownExpr{p.exprCount()}
-		p.addExpr(&binaryExpr{p.popExpr(), operator, &right, p.exprCount()})
+		right := UnknownExpr{p.exprCount(), Span{}}
+		p.addExpr(&BinaryExpr{p.popExpr(), operator, &right, p.exprCount(), Span{}})
 		p.multiplication()
 		p.popExpr()
+		p.finishExpr(mark)
 	}
 	p.popLog()
 
@@ -137,13 +707,15 @@ func (p *parser) addition() {
 func (p *parser) multiplication() {
 	p.addLog("Searching for multiplication or higher")
 
+	mark := p.mark()
 	p.unary()
 	for p.match([]TokenType{Slash, Star}) {
 		operator := p.previous()
-		right := unknownExpr{p.exprCount()}
-		p.addExpr(&binaryExpr{p.popExpr(), operator, &right, p.exprCount()})
+		right := UnknownExpr{p.exprCount(), Span{}}
+		p.addExpr(&BinaryExpr{p.popExpr(), operator, &right, p.exprCount(), Span{}})
 		p.unary()
 		p.popExpr()
+		p.finishExpr(mark)
 	}
 	p.popLog()
 
@@ -159,66 +731,232 @@ func (p *parser) multiplication() {
 func (p *parser) unary() {
 	p.addLog("Searching for unary or higher")
 
-	if p.match([]TokenType{Bang, Minus}) {
+	mark := p.mark()
+	if p.match(p.operatorTable().prefix) {
 		operator := p.previous()
-		right := unknownExpr{p.exprCount()}
-		p.addExpr(&unaryExpr{operator, &right, p.exprCount()})
+		right := UnknownExpr{p.exprCount(), Span{}}
+		p.addExpr(&UnaryExpr{operator, &right, p.exprCount(), Span{}})
 		p.unary()
 		p.popExpr()
+		p.finishExpr(mark)
 		return
 	}
+	p.call()
+	p.popLog()
+
+}
+
+func (p *parser) call() {
+	p.addLog("Searching for call or higher")
+
+	mark := p.mark()
 	err := p.primary()
 	if err != nil {
-		// Do something
+		// primary left a placeholder on the stack, keep going
+	}
+	for {
+		if p.match([]TokenType{LeftParen}) {
+			call := &CallExpr{Callee: p.popExpr(), order: p.exprCount()}
+			p.addExpr(call)
+			if !p.check(RightParen) {
+				for {
+					if len(call.Arguments) >= 255 {
+						p.error(p.peek(), "Can't have more than 255 arguments")
+					}
+					call.Arguments = append(call.Arguments, &UnknownExpr{p.exprCount(), Span{}})
+					p.assignment()
+					p.popExpr()
+					if !p.match([]TokenType{Comma}) {
+						break
+					}
+				}
+			}
+			call.Paren, _ = p.consume(RightParen, "Expected ')' after arguments")
+			p.finishExpr(mark)
+		} else if p.match([]TokenType{Dot}) {
+			name, _ := p.consume(Identifier, "Expected property name after '.'")
+			p.addExpr(&GetExpr{p.popExpr(), name, p.exprCount(), Span{}})
+			p.finishExpr(mark)
+		} else if p.match([]TokenType{LeftBracket}) {
+			index := UnknownExpr{p.exprCount(), Span{}}
+			get := &IndexExpr{Object: p.popExpr(), Index: &index, order: p.exprCount()}
+			p.addExpr(get)
+			p.expression()
+			p.popExpr()
+			get.Bracket, _ = p.consume(RightBracket, "Expected ']' after index")
+			p.finishExpr(mark)
+		} else {
+			break
+		}
 	}
 	p.popLog()
-
 }
 
 func (p *parser) primary() error {
 	p.addLog("Searching for primary")
+	mark := p.mark()
 
 	if p.match([]TokenType{FalseKeyword}) {
-		p.addExpr(&literalExpr{false, p.exprCount(), p.previous()})
+		p.addExpr(&LiteralExpr{false, p.previous(), p.exprCount(), Span{}})
+		p.finishExpr(mark)
 		p.popLog()
 
 		return nil
 	}
 	if p.match([]TokenType{TrueKeyword}) {
-		p.addExpr(&literalExpr{true, p.exprCount(), p.previous()})
+		p.addExpr(&LiteralExpr{true, p.previous(), p.exprCount(), Span{}})
+		p.finishExpr(mark)
 		p.popLog()
 
 		return nil
 	}
 	if p.match([]TokenType{NilKeyword}) {
-		p.addExpr(&literalExpr{nil, p.exprCount(), p.previous()})
+		p.addExpr(&LiteralExpr{nil, p.previous(), p.exprCount(), Span{}})
+		p.finishExpr(mark)
 		p.popLog()
 
 		return nil
 	}
 	if p.match([]TokenType{Number, StringLiteral}) {
-		p.addExpr(&literalExpr{p.previous().Literal, p.exprCount(), p.previous()})
+		p.addExpr(&LiteralExpr{p.previous().Literal, p.previous(), p.exprCount(), Span{}})
+		p.finishExpr(mark)
+		p.popLog()
+
+		return nil
+	}
+	if p.match([]TokenType{Identifier}) {
+		p.addExpr(&VariableExpr{p.previous(), p.exprCount(), Span{}})
+		p.finishExpr(mark)
+		p.popLog()
+
+		return nil
+	}
+	if p.match([]TokenType{ThisKeyword}) {
+		p.addExpr(&ThisExpr{p.previous(), p.exprCount(), Span{}})
+		p.finishExpr(mark)
+		p.popLog()
+
+		return nil
+	}
+	if p.match([]TokenType{SuperKeyword}) {
+		keyword := p.previous()
+		p.consume(Dot, "Expected '.' after 'super'")
+		method, _ := p.consume(Identifier, "Expected superclass method name")
+		p.addExpr(&SuperExpr{keyword, method, p.exprCount(), Span{}})
+		p.finishExpr(mark)
+		p.popLog()
+
+		return nil
+	}
+	if p.match([]TokenType{LeftBracket}) {
+		list := &ListExpr{Bracket: p.previous(), order: p.exprCount()}
+		p.addExpr(list)
+		if !p.check(RightBracket) {
+			for {
+				list.Elements = append(list.Elements, &UnknownExpr{p.exprCount(), Span{}})
+				p.assignment()
+				p.popExpr()
+				if !p.match([]TokenType{Comma}) {
+					break
+				}
+			}
+		}
+		p.consume(RightBracket, "Expected ']' after list elements")
+		p.finishExpr(mark)
+		p.popLog()
+
+		return nil
+	}
+	// A '{' only reaches here in expression position, statement() takes it
+	// as a block first
+	if p.match([]TokenType{LeftBrace}) {
+		m := &MapExpr{Brace: p.previous(), order: p.exprCount()}
+		p.addExpr(m)
+		if !p.check(RightBrace) {
+			for {
+				m.Keys = append(m.Keys, &UnknownExpr{p.exprCount(), Span{}})
+				p.assignment()
+				p.popExpr()
+				p.consume(Colon, "Expected ':' after map key")
+				m.Values = append(m.Values, &UnknownExpr{p.exprCount(), Span{}})
+				p.assignment()
+				p.popExpr()
+				if !p.match([]TokenType{Comma}) {
+					break
+				}
+			}
+		}
+		p.consume(RightBrace, "Expected '}' after map entries")
+		p.finishExpr(mark)
 		p.popLog()
 
 		return nil
 	}
 	if p.match([]TokenType{LeftParen}) {
-		expr := unknownExpr{p.exprCount()}
-		p.addExpr(&groupingExpr{&expr, p.exprCount(), p.previous()})
+		expr := UnknownExpr{p.exprCount(), Span{}}
+		p.addExpr(&GroupingExpr{&expr, p.previous(), p.exprCount(), Span{}})
 		p.expression()
 		p.popExpr()
 		_, err := p.consume(RightParen, "Expected matching ')'")
 		if err != nil {
 			// Do nothing for now
 		}
+		p.finishExpr(mark)
 		p.popLog()
 
 		return nil
 	}
-	parseError(p.peek(), "Expected expression")
+	if operand, ok := p.missingLeftOperand(p.peek().Ttype); ok {
+		// An error production for a binary operator with nothing on its
+		// left. The right operand is parsed so the error doesn't cascade, then
+		// thrown away, leaving a placeholder in the expression's place.
+		operator := p.advance()
+		err := p.error(operator, fmt.Sprintf("Missing left-hand operand for '%s'", operator.Lexeme))
+		p.addExpr(&UnknownExpr{p.exprCount(), Span{}})
+		p.addLog(fmt.Sprintf("Discarding the right operand of '%s'", operator.Lexeme))
+		operand()
+		p.popExpr()
+		p.popLog()
+		p.finishNode(mark, "Error", nil, nil)
+		p.popLog()
+
+		return err
+	}
+	err := p.error(p.peek(), "Expected expression")
+	// Leave a placeholder so the expression stack has the same shape it
+	// would have had if parsing succeeded
+	p.addExpr(&UnknownExpr{p.exprCount(), Span{}})
 	p.popLog()
 
-	return errors.New("Expected expression!")
+	return err
+}
+
+// missingLeftOperand returns what parses the right operand of a binary
+// operator, at the precedence the operator would have parsed it at
+func (p *parser) missingLeftOperand(ttype TokenType) (func(), bool) {
+	if p.pratt {
+		bp, ok := p.operatorTable().rightOperandLevel(ttype)
+		if !ok {
+			return nil, false
+		}
+		return func() { p.infix(bp) }, true
+	}
+	// Minus isn't here, it's a prefix operator too
+	switch ttype {
+	case OrKeyword:
+		return p.and, true
+	case AndKeyword:
+		return p.equality, true
+	case BangEqual, EqualEqual:
+		return p.comparison, true
+	case Greater, GreaterEqual, Less, LessEqual:
+		return p.addition, true
+	case Plus:
+		return p.multiplication, true
+	case Slash, Star:
+		return p.unary, true
+	}
+	return nil, false
 }
 
 func (p *parser) exprCount() int {
@@ -230,9 +968,17 @@ func (p *parser) consume(ttype TokenType, message string) (Token, error) {
 	if p.check(ttype) {
 		return p.advance(), nil
 	}
-	tok := p.peek()
-	parseError(tok, message)
-	return p.peek(), errors.New(message)
+	return p.peek(), p.error(p.peek(), message)
+}
+
+func (p *parser) error(token Token, message string) error {
+	errorMsg := parseError(token, message)
+	p.errorCount++
+	p.diagnostics = append(p.diagnostics, Diagnostic{token, message})
+	if p.displayError != nil {
+		p.displayError(errorMsg)
+	}
+	return errors.New(errorMsg)
 }
 
 func (p *parser) synchronize() {
@@ -284,15 +1030,29 @@ func (p *parser) check(ttype TokenType) bool {
 func (p *parser) advance() Token {
 	if !p.isAtEnd() {
 		p.current++
+		p.addSyntaxToken(p.current - 1)
 	}
 	return p.previous()
 }
 
+// previous and peek stay inside the tokens, past either end they return the
+// first or last one
 func (p *parser) previous() Token {
+	if p.current == 0 {
+		return p.tokens[0]
+	}
 	return p.tokens[p.current-1]
 }
 
 func (p *parser) peek() Token {
+	if p.current >= len(p.tokens) && p.lexer != nil {
+		// A read error ends the tokens, the caller asks the lexer for it
+		token, _ := p.lexer.Next()
+		p.tokens = append(p.tokens, token)
+	}
+	if p.current >= len(p.tokens) {
+		return p.tokens[len(p.tokens)-1]
+	}
 	return p.tokens[p.current]
 }
 func (p *parser) isAtEnd() bool {
@@ -0,0 +1,126 @@
+package golox
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseExpressions(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"conditional", "a ? b : c", "(?: a b c)"},
+		{"conditional in the else branch", "a ? b : c ? d : e", "(?: a b (?: c d e))"},
+		{"conditional in the then branch", "a ? b ? c : d : e", "(?: a (?: b c d) e)"},
+		{"conditional condition", "a or b ? c : d", "(?: (or a b) c d)"},
+		{"comma in the then branch", "a ? b, c : d", "(?: a (, b c) d)"},
+		{"assignment of a conditional", "x = a ? b : c", "(x = (?: a b c))"},
+		{"comma", "a, b, c", "(, (, a b) c)"},
+		{"comma and assignment", "a = 1, b = 2", "(, (a = 1) (b = 2))"},
+		{"commas in call arguments", "f(a, b)", "(call f a b)"},
+		{"comma in a grouped argument", "f((a, b), c)", "(call f (() (, a b)) c)"},
+		{"comma in an index", "a[b, c]", "([i] a (, b c))"},
+		{"commas in a list", "[a, b]", "([] a b)"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var errors []string
+			expr := RunParser(test.source, func(message string) {
+				errors = append(errors, message)
+			})
+			if len(errors) > 0 {
+				t.Fatal(errors)
+			}
+			if got := FormatExpr(expr); got != test.want {
+				t.Errorf("Parsed as %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		errors []string
+		// want is the statements that parsed
+		want string
+	}{
+		{
+			"missing left operand",
+			"+ 1;\nprint 2;",
+			[]string{"Error on line 1 at '+': Missing left-hand operand for '+'"},
+			"(print 2)",
+		},
+		{
+			"missing left operand takes its right operand",
+			"* 2 + 3;\nprint 4;",
+			[]string{"Error on line 1 at '*': Missing left-hand operand for '*'"},
+			"(print 4)",
+		},
+		{
+			"missing left operand of each kind",
+			"or a; and a; == a; < a; / a;",
+			[]string{
+				"Error on line 1 at 'or': Missing left-hand operand for 'or'",
+				"Error on line 1 at 'and': Missing left-hand operand for 'and'",
+				"Error on line 1 at '==': Missing left-hand operand for '=='",
+				"Error on line 1 at '<': Missing left-hand operand for '<'",
+				"Error on line 1 at '/': Missing left-hand operand for '/'",
+			},
+			"",
+		},
+		{
+			"minus is a prefix operator",
+			"- 1;",
+			nil,
+			"(expr (- 1))",
+		},
+		{
+			"conditional without an else branch",
+			"print a ? b;\nprint 2;",
+			[]string{"Error on line 1 at ';': Expected ':' after then branch of conditional expression"},
+			"(print 2)",
+		},
+		{
+			"comma without a right operand",
+			"print a, ;\nprint 2;",
+			[]string{"Error on line 1 at ';': Expected expression"},
+			"(print 2)",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, errors := parseProgramWith(test.source, false, nil)
+			if !reflect.DeepEqual(errors, test.errors) {
+				t.Errorf("Reported %q, want %q", errors, test.errors)
+			}
+			if got != test.want {
+				t.Errorf("Parsed as\n%s\nwant\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestMissingLeftOperandSteps(t *testing.T) {
+	// The right operand is parsed and thrown away, leaving a placeholder
+	// where the binary expression would have been
+	steps, _ := RunParserForSteps("+ 1 * 2", func(string) {})
+	last := steps[len(steps)-1]
+	if len(last.Exprs) != 1 || FormatExpr(last.Exprs[0]) != "??" {
+		t.Errorf("Ended with %q, want a single placeholder", formatSteps(last))
+	}
+	discarded := false
+	for _, step := range steps {
+		for _, log := range step.Logs {
+			if log == "Discarding the right operand of '+'" {
+				discarded = true
+			}
+		}
+	}
+	if !discarded {
+		t.Error("No step says the right operand is discarded")
+	}
+}
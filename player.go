@@ -0,0 +1,140 @@
+package golox
+
+// StepPlayer moves back and forth through a recording. Next and Prev only
+// stop at the kinds of step the filter lets through, the Continues at
+// breakpoints whatever their kind, and the seeks go wherever they're told.
+type StepPlayer struct {
+	recording Recording
+	current   int
+	// filter holds the kinds of step to stop at, all of them when it's nil
+	filter      map[StepKind]bool
+	breakpoints map[int]bool
+}
+
+// NewStepPlayer makes a player starting at the first step of recording.
+func NewStepPlayer(recording Recording) *StepPlayer {
+	return &StepPlayer{recording: recording, breakpoints: make(map[int]bool)}
+}
+
+func (p *StepPlayer) Len() int {
+	return p.recording.Len()
+}
+
+// Current is the number of the step the player is at.
+func (p *StepPlayer) Current() int {
+	return p.current
+}
+
+// Next moves to the next step the filter lets through, returning false
+// without moving when there isn't one.
+func (p *StepPlayer) Next() bool {
+	return p.move(1, false)
+}
+
+// Prev moves to the previous step the filter lets through, returning false
+// without moving when there isn't one.
+func (p *StepPlayer) Prev() bool {
+	return p.move(-1, false)
+}
+
+// Continue moves forward to the next breakpoint, or the last step the filter
+// lets through when there are no more breakpoints. It returns whether it
+// stopped at a breakpoint.
+func (p *StepPlayer) Continue() bool {
+	return p.move(1, true)
+}
+
+// ReverseContinue is Continue going backwards.
+func (p *StepPlayer) ReverseContinue() bool {
+	return p.move(-1, true)
+}
+
+// move steps in direction until a step the filter lets through, or with
+// toBreakpoint set a breakpoint, going as far as it can when there isn't one
+func (p *StepPlayer) move(direction int, toBreakpoint bool) bool {
+	last := -1
+	for n := p.current + direction; n >= 0 && n < p.recording.Len(); n += direction {
+		if toBreakpoint && p.breakpoints[n] {
+			p.current = n
+			return true
+		}
+		if !p.shows(n) {
+			continue
+		}
+		if !toBreakpoint {
+			p.current = n
+			return true
+		}
+		last = n
+	}
+	if last >= 0 {
+		p.current = last
+	}
+	return false
+}
+
+func (p *StepPlayer) shows(n int) bool {
+	return p.filter == nil || p.filter[p.recording.Kind(n)]
+}
+
+// Seek moves to step n, returning false without moving when there isn't
+// one.
+func (p *StepPlayer) Seek(n int) bool {
+	if n < 0 || n >= p.recording.Len() {
+		return false
+	}
+	p.current = n
+	return true
+}
+
+// SeekToToken moves to the first step at token index, like where the
+// scanner starts on it or the parser is about to consume it.
+func (p *StepPlayer) SeekToToken(index int) bool {
+	if index < 0 {
+		return false
+	}
+	for n := 0; n < p.recording.Len(); n++ {
+		if p.recording.TokenIndex(n) == index {
+			p.current = n
+			return true
+		}
+	}
+	return false
+}
+
+// SeekToNode moves to the first step about the expression with the given
+// Order(), like where the parser pushes it or the interpreter evaluates it.
+func (p *StepPlayer) SeekToNode(order int) bool {
+	if order <= 0 {
+		return false
+	}
+	for n := 0; n < p.recording.Len(); n++ {
+		if p.recording.NodeOrder(n) == order {
+			p.current = n
+			return true
+		}
+	}
+	return false
+}
+
+// SetFilter makes Next and Prev only stop at the given kinds of step, or at
+// any step when there are none.
+func (p *StepPlayer) SetFilter(kinds ...StepKind) {
+	if len(kinds) == 0 {
+		p.filter = nil
+		return
+	}
+	p.filter = make(map[StepKind]bool)
+	for _, kind := range kinds {
+		p.filter[kind] = true
+	}
+}
+
+// SetBreakpoint adds or removes a breakpoint on step n for the Continues.
+func (p *StepPlayer) SetBreakpoint(n int, on bool) {
+	if on {
+		p.breakpoints[n] = true
+	} else {
+		delete(p.breakpoints, n)
+	}
+}
@@ -0,0 +1,134 @@
+package golox
+
+import (
+	"reflect"
+	"testing"
+)
+
+// fakeRecording is a recording made up of just the kinds, tokens and nodes
+// of its steps
+type fakeRecording struct {
+	kinds  []StepKind
+	tokens []int
+	nodes  []int
+}
+
+func (r *fakeRecording) Len() int             { return len(r.kinds) }
+func (r *fakeRecording) Kind(n int) StepKind  { return r.kinds[n] }
+func (r *fakeRecording) TokenIndex(n int) int { return r.tokens[n] }
+func (r *fakeRecording) NodeOrder(n int) int  { return r.nodes[n] }
+
+func newFakePlayer() *StepPlayer {
+	return NewStepPlayer(&fakeRecording{
+		kinds:  []StepKind{MoveStep, LogPushStep, ExprPushStep, MoveStep, ExprPushStep, ExprPopStep, LogPopStep, MoveStep},
+		tokens: []int{-1, 0, 0, 1, 1, 2, 2, 3},
+		nodes:  []int{0, 0, 1, 1, 2, 1, 1, 0},
+	})
+}
+
+func TestStepPlayerMoves(t *testing.T) {
+	p := newFakePlayer()
+	var visited []int
+	for p.Next() {
+		visited = append(visited, p.Current())
+	}
+	if len(visited) != 7 || p.Current() != 7 {
+		t.Errorf("Visited %v, want every step after the first", visited)
+	}
+	if p.Next() || p.Current() != 7 {
+		t.Errorf("Moved past the end to %d", p.Current())
+	}
+
+	p.SetFilter(ExprPushStep, ExprPopStep)
+	visited = nil
+	for p.Prev() {
+		visited = append(visited, p.Current())
+	}
+	if want := []int{5, 4, 2}; !reflect.DeepEqual(visited, want) {
+		t.Errorf("Visited %v going back, want %v", visited, want)
+	}
+	if p.Current() != 2 {
+		t.Errorf("Went back to %d with nothing else to stop at, want to stay at 2", p.Current())
+	}
+
+	p.SetFilter()
+	if !p.Prev() || p.Current() != 1 {
+		t.Errorf("Moved to %d with the filter cleared, want 1", p.Current())
+	}
+}
+
+func TestStepPlayerBreakpoints(t *testing.T) {
+	p := newFakePlayer()
+	p.SetBreakpoint(3, true)
+	p.SetBreakpoint(5, true)
+	p.SetBreakpoint(6, true)
+	p.SetBreakpoint(6, false)
+	var stops []int
+	for p.Continue() {
+		stops = append(stops, p.Current())
+	}
+	if want := []int{3, 5}; !reflect.DeepEqual(stops, want) {
+		t.Errorf("Stopped at %v, want %v", stops, want)
+	}
+	if p.Current() != 7 {
+		t.Errorf("Continued to %d past the last breakpoint, want the last step", p.Current())
+	}
+
+	// Breakpoints stop the Continues whatever the filter says
+	p.SetFilter(LogPushStep)
+	if !p.ReverseContinue() || p.Current() != 5 {
+		t.Errorf("Reverse continued to %d, want 5", p.Current())
+	}
+	if !p.ReverseContinue() || p.Current() != 3 {
+		t.Errorf("Reverse continued to %d, want 3", p.Current())
+	}
+	if p.ReverseContinue() || p.Current() != 1 {
+		t.Errorf("Reverse continued to %d past the first breakpoint, want the first log push", p.Current())
+	}
+}
+
+func TestStepPlayerSeeks(t *testing.T) {
+	p := newFakePlayer()
+	tests := []struct {
+		name string
+		seek func() bool
+		ok   bool
+		want int
+	}{
+		{"step", func() bool { return p.Seek(6) }, true, 6},
+		{"step before the first", func() bool { return p.Seek(-1) }, false, 6},
+		{"step past the last", func() bool { return p.Seek(8) }, false, 6},
+		{"token", func() bool { return p.SeekToToken(1) }, true, 3},
+		{"first token", func() bool { return p.SeekToToken(0) }, true, 1},
+		{"missing token", func() bool { return p.SeekToToken(9) }, false, 1},
+		{"no token", func() bool { return p.SeekToToken(-1) }, false, 1},
+		{"node", func() bool { return p.SeekToNode(2) }, true, 4},
+		{"first node", func() bool { return p.SeekToNode(1) }, true, 2},
+		{"no node", func() bool { return p.SeekToNode(0) }, false, 2},
+	}
+	for _, test := range tests {
+		if ok := test.seek(); ok != test.ok || p.Current() != test.want {
+			t.Errorf("Seeking %s returned %v at %d, want %v at %d", test.name, ok, p.Current(), test.ok, test.want)
+		}
+	}
+}
+
+func TestStepPlayerParserRecording(t *testing.T) {
+	recording, tokens := RunParserForRecording("1 + 2 * 3", func(string) {})
+	p := NewStepPlayer(recording)
+	if p.Len() != recording.Len() {
+		t.Fatalf("Has %d steps, want %d", p.Len(), recording.Len())
+	}
+	// The parser is about to consume the '*' right after it pushes the 2
+	star := 3
+	if tokens[star].Lexeme != "*" {
+		t.Fatalf("Token %d is %+v", star, tokens[star])
+	}
+	if !p.SeekToToken(star) {
+		t.Fatal("Never reached the '*'")
+	}
+	step := recording.Step(p.Current())
+	if top := step.Exprs[len(step.Exprs)-1]; FormatExpr(top) != "2" {
+		t.Errorf("Reached the '*' with %s on top, want 2", FormatExpr(top))
+	}
+}
@@ -0,0 +1,333 @@
+package golox
+
+import (
+	"bufio"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Fixity says where an operator goes relative to its operands.
+type Fixity int
+
+const (
+	Prefix Fixity = iota
+	Infix
+	Postfix
+)
+
+// Associativity says which way a chain of infix operators with the same
+// precedence groups.
+type Associativity int
+
+const (
+	LeftAssociative Associativity = iota
+	RightAssociative
+)
+
+// Operator is an entry in an operator table. Symbol is one of Lox's operators:
+// or, and, ==, !=, <, <=, >, >=, +, -, *, / or !. Operators with a higher
+// Precedence bind tighter.
+type Operator struct {
+	Symbol        string
+	Precedence    int
+	Associativity Associativity
+	Fixity        Fixity
+}
+
+// operatorSymbols are the tokens an operator table can give meaning to
+var operatorSymbols = map[string]TokenType{
+	"or":  OrKeyword,
+	"and": AndKeyword,
+	"==":  EqualEqual,
+	"!=":  BangEqual,
+	"<":   Less,
+	"<=":  LessEqual,
+	">":   Greater,
+	">=":  GreaterEqual,
+	"+":   Plus,
+	"-":   Minus,
+	"*":   Star,
+	"/":   Slash,
+	"!":   Bang,
+}
+
+// DefaultOperators returns Lox's own operator table.
+func DefaultOperators() []Operator {
+	return []Operator{
+		{"or", 1, LeftAssociative, Infix},
+		{"and", 2, LeftAssociative, Infix},
+		{"==", 3, LeftAssociative, Infix},
+		{"!=", 3, LeftAssociative, Infix},
+		{">", 4, LeftAssociative, Infix},
+		{">=", 4, LeftAssociative, Infix},
+		{"<", 4, LeftAssociative, Infix},
+		{"<=", 4, LeftAssociative, Infix},
+		{"-", 5, LeftAssociative, Infix},
+		{"+", 5, LeftAssociative, Infix},
+		{"/", 6, LeftAssociative, Infix},
+		{"*", 6, LeftAssociative, Infix},
+		{"!", 7, RightAssociative, Prefix},
+		{"-", 7, RightAssociative, Prefix},
+	}
+}
+
+// operatorLevel is a row of the operator table, holding the operators with
+// one precedence. Rows are ordered from loosest to tightest.
+type operatorLevel struct {
+	// name is what the level is called in the logs, like "equality"
+	name       string
+	precedence int
+	prefix     []TokenType
+	infix      []TokenType
+	postfix    []TokenType
+	// rightAssociative infix operators take a right operand at their own
+	// level instead of the next one
+	rightAssociative map[TokenType]bool
+}
+
+// OperatorTable drives the Pratt parser. Prefix operators binding tighter
+// than every infix and postfix operator are parsed by unary(), the rest get a
+// row of the table.
+type OperatorTable struct {
+	levels []operatorLevel
+	prefix []TokenType
+}
+
+// defaultOperators is Lox's grammar, the same one the recursive descent
+// functions from or() down to unary() spell out
+var defaultOperators = func() *OperatorTable {
+	table, err := NewOperatorTable(DefaultOperators())
+	if err != nil {
+		panic(err)
+	}
+	for idx, name := range []string{"or", "and", "equality", "comparison", "addition", "multiplication"} {
+		table.levels[idx].name = name
+	}
+	return table
+}()
+
+// NewOperatorTable builds a table for the Pratt parser out of operators.
+func NewOperatorTable(operators []Operator) (*OperatorTable, error) {
+	seen := make(map[string]Fixity)
+	highest := 0
+	hasLevels := false
+	for _, operator := range operators {
+		if _, ok := operatorSymbols[operator.Symbol]; !ok {
+			return nil, fmt.Errorf("'%s' isn't a Lox operator", operator.Symbol)
+		}
+		if fixity, ok := seen[operator.Symbol]; ok {
+			if fixity == operator.Fixity {
+				return nil, fmt.Errorf("'%s' is in the table twice", operator.Symbol)
+			}
+			if fixity != Prefix && operator.Fixity != Prefix {
+				return nil, fmt.Errorf("'%s' can't be both infix and postfix", operator.Symbol)
+			}
+		}
+		seen[operator.Symbol] = operator.Fixity
+		if operator.Fixity != Prefix && (!hasLevels || operator.Precedence > highest) {
+			highest = operator.Precedence
+			hasLevels = true
+		}
+	}
+
+	table := &OperatorTable{}
+	byPrecedence := make(map[int]*operatorLevel)
+	for _, operator := range operators {
+		ttype := operatorSymbols[operator.Symbol]
+		if operator.Fixity == Prefix && (!hasLevels || operator.Precedence > highest) {
+			table.prefix = append(table.prefix, ttype)
+			continue
+		}
+		level, ok := byPrecedence[operator.Precedence]
+		if !ok {
+			level = &operatorLevel{
+				name:             fmt.Sprintf("precedence %d", operator.Precedence),
+				precedence:       operator.Precedence,
+				rightAssociative: make(map[TokenType]bool),
+			}
+			byPrecedence[operator.Precedence] = level
+		}
+		switch operator.Fixity {
+		case Prefix:
+			level.prefix = append(level.prefix, ttype)
+		case Infix:
+			level.infix = append(level.infix, ttype)
+			if operator.Associativity == RightAssociative {
+				level.rightAssociative[ttype] = true
+			}
+		case Postfix:
+			level.postfix = append(level.postfix, ttype)
+		}
+	}
+	for _, level := range byPrecedence {
+		table.levels = append(table.levels, *level)
+	}
+	sort.Slice(table.levels, func(i, j int) bool {
+		return table.levels[i].precedence < table.levels[j].precedence
+	})
+	return table, nil
+}
+
+// ParseOperatorTable reads an operator table written one operator to a line,
+// as its symbol, precedence, associativity (left or right) and fixity
+// (prefix, infix or postfix):
+//
+//	# * binds looser than +
+//	*  1 left  infix
+//	+  2 left  infix
+//	-  3 right prefix
+//
+// Blank lines and lines starting with # are skipped.
+func ParseOperatorTable(config string) (*OperatorTable, error) {
+	var operators []Operator
+	scanner := bufio.NewScanner(strings.NewReader(config))
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("Line %d: expected a symbol, precedence, associativity and fixity", line)
+		}
+		precedence, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("Line %d: precedence '%s' isn't a whole number", line, fields[1])
+		}
+		operator := Operator{Symbol: fields[0], Precedence: precedence}
+		switch fields[2] {
+		case "left":
+			operator.Associativity = LeftAssociative
+		case "right":
+			operator.Associativity = RightAssociative
+		default:
+			return nil, fmt.Errorf("Line %d: associativity must be left or right, not '%s'", line, fields[2])
+		}
+		switch fields[3] {
+		case "prefix":
+			operator.Fixity = Prefix
+		case "infix":
+			operator.Fixity = Infix
+		case "postfix":
+			operator.Fixity = Postfix
+		default:
+			return nil, fmt.Errorf("Line %d: fixity must be prefix, infix or postfix, not '%s'", line, fields[3])
+		}
+		operators = append(operators, operator)
+	}
+	table, err := NewOperatorTable(operators)
+	if err != nil {
+		return nil, fmt.Errorf("Operator table: %v", err)
+	}
+	return table, nil
+}
+
+// infix parses the operators in row bp of the table or tighter ones. With the
+// default table it makes the same trees and steps as the recursive descent
+// functions, one level of recursion per row.
+func (p *parser) infix(bp int) {
+	table := p.operatorTable()
+	if bp == len(table.levels) {
+		p.unary()
+		return
+	}
+	level := table.levels[bp]
+	p.addLog("Searching for " + level.name + " or higher")
+
+	mark := p.mark()
+	if p.match(level.prefix) {
+		operator := p.previous()
+		right := UnknownExpr{p.exprCount(), Span{}}
+		p.addExpr(&UnaryExpr{operator, &right, p.exprCount(), Span{}})
+		p.explainLog(fmt.Sprintf("'%s' is a prefix operator with precedence %d, its operand takes operators from %d up", operator.Lexeme, level.precedence, level.precedence))
+		p.infix(bp)
+		p.explainPop()
+		p.popExpr()
+		p.finishExpr(mark)
+	} else {
+		p.infix(bp + 1)
+	}
+
+	for {
+		if p.match(level.infix) {
+			operator := p.previous()
+			right := UnknownExpr{p.exprCount(), Span{}}
+			if operator.Ttype == OrKeyword || operator.Ttype == AndKeyword {
+				p.addExpr(&LogicalExpr{p.popExpr(), operator, &right, p.exprCount(), Span{}})
+			} else {
+				p.addExpr(&BinaryExpr{p.popExpr(), operator, &right, p.exprCount(), Span{}})
+			}
+			if level.rightAssociative[operator.Ttype] {
+				p.explainLog(fmt.Sprintf("'%s' has precedence %d and is right associative, so its right operand takes operators from %d up", operator.Lexeme, level.precedence, level.precedence))
+				p.infix(bp)
+			} else {
+				p.explainLog(fmt.Sprintf("'%s' has precedence %d and is left associative, so its right operand only takes operators above %d", operator.Lexeme, level.precedence, level.precedence))
+				p.infix(bp + 1)
+			}
+			p.explainPop()
+			p.popExpr()
+			p.finishExpr(mark)
+		} else if p.match(level.postfix) {
+			p.addExpr(&PostfixExpr{p.popExpr(), p.previous(), p.exprCount(), Span{}})
+			p.finishExpr(mark)
+		} else {
+			break
+		}
+	}
+	if precedence, ok := table.precedence(p.peek().Ttype); ok && precedence < level.precedence {
+		p.explainLog(fmt.Sprintf("'%s' has precedence %d, below %d, so it's left for a looser level", p.peek().Lexeme, precedence, level.precedence))
+		p.explainPop()
+	}
+	p.popLog()
+}
+
+// explainLog adds a log about a binding power decision when they're wanted
+func (p *parser) explainLog(log string) {
+	if p.explain {
+		p.addLog(log)
+	}
+}
+
+func (p *parser) explainPop() {
+	if p.explain {
+		p.popLog()
+	}
+}
+
+// rightOperandLevel looks up the row of the table an infix operator's right
+// operand is parsed from
+func (t *OperatorTable) rightOperandLevel(ttype TokenType) (int, bool) {
+	for bp, level := range t.levels {
+		for _, operator := range level.infix {
+			if operator == ttype {
+				if level.rightAssociative[ttype] {
+					return bp, true
+				}
+				return bp + 1, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// precedence looks up an infix or postfix operator
+func (t *OperatorTable) precedence(ttype TokenType) (int, bool) {
+	for _, level := range t.levels {
+		for _, operators := range [][]TokenType{level.infix, level.postfix} {
+			for _, operator := range operators {
+				if operator == ttype {
+					return level.precedence, true
+				}
+			}
+		}
+	}
+	return 0, false
+}
+
+func (p *parser) operatorTable() *OperatorTable {
+	if p.operators == nil {
+		return defaultOperators
+	}
+	return p.operators
+}
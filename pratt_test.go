@@ -0,0 +1,110 @@
+package golox
+
+import (
+	"reflect"
+	"testing"
+)
+
+// prattCorpus has every precedence level of Lox's grammar, chains of each
+// to show how they group, and mixes of levels in both orders
+var prattCorpus = []string{
+	"a or b or c; a and b and c; a or b and c; a and b or c;",
+	"a == b == c; a != b == c; a == b and c != d; a and b == c;",
+	"a < b < c; a <= b > c >= d; a == b < c; a < b == c;",
+	"a + b + c; a - b - c; a - b + c; a < b + c; a + b < c;",
+	"a * b * c; a / b / c; a / b * c; a + b * c; a * b + c; a - b / c - d;",
+	"!a; !!a; -a; - -a; -a * b; !a == b; a * -b; -a.b; !a(b);",
+	"a = b = c; a = b or c; a.b = c + d; a[b] = c = d;",
+	"a ? b : c; a ? b : c ? d : e; a ? b ? c : d : e; a or b ? c + d : e * f; a = b ? c : d;",
+	"a, b, c; (a, b), c; a = b, c; a ? b : c, d;",
+	"a(b, c) + d; a.b.c * d[e]; -a(b)[c].d; (a + b) * c; -(a - b) - c;",
+	"a + b * c - d / e == f < g and h or !i;",
+	"!a or b and -c * d + e < f != g;",
+	"var x = a + b * c; if (a < b and c) print a - b - c; while (!a) a = a - 1;",
+	"fun f(a, b) { return a * b + -a; } print f(1, 2) * f(3, 4);",
+	"* 3; a + ; (a or; a ? b; a = ;",
+}
+
+// parseProgramWith parses source with the recursive descent parser, or the
+// Pratt parser and table, returning the statements as FormatStmts writes
+// them and the errors found
+func parseProgramWith(source string, pratt bool, table *OperatorTable) (string, []string) {
+	var errors []string
+	displayError := func(message string) {
+		errors = append(errors, message)
+	}
+	p := parser{tokens: RunScanner(source, displayError), displayError: displayError, pratt: pratt, operators: table}
+	statements, _ := p.parseProgram()
+	return FormatStmts(statements), errors
+}
+
+func TestPrattMatchesRecursiveDescent(t *testing.T) {
+	for _, source := range append(prattCorpus, seedPrograms...) {
+		want, wantErrors := parseProgramWith(source, false, nil)
+		got, gotErrors := parseProgramWith(source, true, nil)
+		if got != want {
+			t.Errorf("%q parsed by the Pratt parser as\n%s\nwant\n%s", source, got, want)
+		}
+		if !reflect.DeepEqual(gotErrors, wantErrors) {
+			t.Errorf("%q reported %q by the Pratt parser, want %q", source, gotErrors, wantErrors)
+		}
+	}
+}
+
+func TestPrattStepsMatchRecursiveDescent(t *testing.T) {
+	for _, source := range seedExprs {
+		want, _ := RunParserForSteps(source, func(string) {})
+		got, _ := RunPrattParserForSteps(source, nil, false, func(string) {})
+		if len(got) != len(want) {
+			t.Errorf("%q took %d steps with the Pratt parser, want %d", source, len(got), len(want))
+			continue
+		}
+		for idx := range want {
+			if got[idx].Current != want[idx].Current || !reflect.DeepEqual(got[idx].Logs, want[idx].Logs) || formatSteps(got[idx]) != formatSteps(want[idx]) {
+				t.Errorf("%q step %d is %+v with the Pratt parser, want %+v", source, idx, got[idx], want[idx])
+				break
+			}
+		}
+	}
+}
+
+// formatSteps writes out the expressions in a step
+func formatSteps(step ParserStep) string {
+	var s string
+	for _, expr := range step.Exprs {
+		s += FormatExpr(expr) + "\n"
+	}
+	return s
+}
+
+func TestPrattTable(t *testing.T) {
+	table, err := ParseOperatorTable(`
+# + binds tighter than *, - groups to the right
+*  1 left  infix
+-  2 right infix
++  3 left  infix
+!  4 right prefix
+!  5 left  postfix
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"a * b + c;", "(expr (* a (+ b c)))"},
+		{"a + b * c;", "(expr (* (+ a b) c))"},
+		{"a - b - c;", "(expr (- a (- b c)))"},
+		{"a + b + c;", "(expr (+ (+ a b) c))"},
+		{"!a + b!;", "(expr (+ (! a) (! b)))"},
+	}
+	for _, test := range tests {
+		got, errors := parseProgramWith(test.source, true, table)
+		if len(errors) > 0 {
+			t.Errorf("%q reported %q", test.source, errors)
+		} else if got != test.want {
+			t.Errorf("%q parsed as %s, want %s", test.source, got, test.want)
+		}
+	}
+}
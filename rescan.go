@@ -0,0 +1,130 @@
+package golox
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// Edit replaces Length bytes of source starting at Offset with Text.
+type Edit struct {
+	Offset int
+	Length int
+	Text   string
+}
+
+// Apply returns source with the edit made to it.
+func (e Edit) Apply(source string) string {
+	return source[:e.Offset] + e.Text + source[e.Offset+e.Length:]
+}
+
+// TokenChange says which tokens a rescan replaced. The old tokens from Start
+// up to OldEnd became the new tokens from Start up to NewEnd, every token
+// outside that range is the same apart from where it is.
+type TokenChange struct {
+	Start  int
+	OldEnd int
+	NewEnd int
+}
+
+var errBadEdit = errors.New("Edit is outside the source")
+
+// lookahead is how far past the end of a token the scanner may have looked
+// to decide where the token ends, like the "5" in "1.5"
+const lookahead = 2
+
+// rescanTokens updates tokens, which were scanned from source, for edit. It
+// rescans from the last token the edit can't have changed until the tokens
+// line up with the old ones again.
+//
+// Only errors in the rescanned text are shown.
+func (s *scanner) rescanTokens(tokens []Token, edit Edit, displayError func(string)) ([]Token, TokenChange, error) {
+	old := s.source
+	if edit.Offset < 0 || edit.Length < 0 || edit.Offset+edit.Length > len(old) {
+		return tokens, TokenChange{}, errBadEdit
+	}
+	oldLineStarts := lineStarts(old)
+	tokenStart := func(t Token) int {
+		return oldLineStarts[t.StartLine-1] + t.Start
+	}
+	tokenEnd := func(t Token) int {
+		return oldLineStarts[t.Line-1] + t.End
+	}
+
+	// Every token ending far enough before the edit scans the same way
+	restart := 0
+	for restart < len(tokens) && tokenEnd(tokens[restart])+lookahead <= edit.Offset {
+		restart++
+	}
+
+	s.source = edit.Apply(old)
+	s.tokens = append([]Token(nil), tokens[:restart]...)
+	s.current = 0
+	s.line = 1
+	s.lineStart = 0
+	if restart > 0 {
+		previous := tokens[restart-1]
+		s.current = tokenEnd(previous)
+		s.line = previous.Line
+		s.lineStart = oldLineStarts[previous.Line-1]
+	}
+	// Old tokens are back in step once a new token starts where one of
+	// them did, on a line after the edit so their columns still hold
+	oldEditEnd := edit.Offset + edit.Length
+	shift := len(edit.Text) - edit.Length
+	lineShift := strings.Count(edit.Text, "\n") - strings.Count(old[edit.Offset:oldEditEnd], "\n")
+	resync := func(start int) int {
+		if start < edit.Offset+len(edit.Text) {
+			return -1
+		}
+		oldStart := start - shift
+		idx := sort.Search(len(tokens), func(idx int) bool {
+			return tokenStart(tokens[idx]) >= oldStart
+		})
+		if idx == len(tokens) || tokens[idx].Ttype == Eof || tokenStart(tokens[idx]) != oldStart {
+			return -1
+		}
+		if oldLineStarts[tokens[idx].StartLine-1] <= oldEditEnd {
+			return -1
+		}
+		return idx
+	}
+
+	var err error
+	for !s.isAtEnd() {
+		s.start = s.current
+		scanned := len(s.tokens)
+		if s.scanToken(displayError) != nil {
+			err = errors.New("Error during scanning")
+		}
+		if len(s.tokens) == scanned {
+			continue
+		}
+		if idx := resync(s.start); idx >= 0 {
+			// The token just scanned is the old one again
+			s.tokens = s.tokens[:scanned]
+			change := TokenChange{Start: restart, OldEnd: idx, NewEnd: len(s.tokens)}
+			for _, token := range tokens[idx:] {
+				token.Line += lineShift
+				token.StartLine += lineShift
+				s.tokens = append(s.tokens, token)
+			}
+			return s.tokens, change, err
+		}
+	}
+
+	s.start = s.current
+	s.addTokenWithLiteral(Eof, "")
+	return s.tokens, TokenChange{Start: restart, OldEnd: len(tokens), NewEnd: len(s.tokens)}, err
+}
+
+// lineStarts returns the offset each line of source starts at
+func lineStarts(source string) []int {
+	starts := []int{0}
+	for idx := 0; idx < len(source); idx++ {
+		if source[idx] == '\n' {
+			starts = append(starts, idx+1)
+		}
+	}
+	return starts
+}
@@ -0,0 +1,43 @@
+package golox
+
+import "testing"
+
+func TestRescanChange(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		edit   Edit
+		// change starts a little before the edit and ends on a line after
+		// it, where the old tokens are sure to line up again
+		change TokenChange
+	}{
+		{"number made longer", "var a = 1;\nprint a;\n", Edit{8, 1, "10"}, TokenChange{2, 5, 5}},
+		{"token split in two", "var ab = 1;\nprint ab;\n", Edit{5, 0, " "}, TokenChange{1, 5, 6}},
+		{"line added", "var a = 1;\nprint a;\n", Edit{10, 0, "\nprint 2;"}, TokenChange{3, 5, 8}},
+		{"string opened", "print 1;\nprint 2;\n", Edit{6, 0, "\""}, TokenChange{0, 7, 2}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tokens := RunScanner(test.source, func(string) {})
+			_, change := RunRescanner(test.source, tokens, test.edit, func(string) {})
+			if change != test.change {
+				t.Errorf("Changed %+v, want %+v", change, test.change)
+			}
+		})
+	}
+}
+
+func TestRescanBadEdit(t *testing.T) {
+	source := "print 1;"
+	tokens := RunScanner(source, func(string) {})
+	var errors []string
+	rescanned, change := RunRescanner(source, tokens, Edit{5, 10, ""}, func(message string) {
+		errors = append(errors, message)
+	})
+	if len(errors) != 1 || errors[0] != "Edit is outside the source" {
+		t.Errorf("Reported %q, want the edit to be outside the source", errors)
+	}
+	if len(rescanned) != len(tokens) || change != (TokenChange{}) {
+		t.Errorf("Returned %d tokens and %+v, want the old tokens unchanged", len(rescanned), change)
+	}
+}
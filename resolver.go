@@ -0,0 +1,327 @@
+package golox
+
+import (
+	"fmt"
+	"strings"
+)
+
+type functionType int
+
+const (
+	noFunction functionType = iota
+	plainFunction
+	initializerFunction
+	methodFunction
+)
+
+type classType int
+
+const (
+	noClass classType = iota
+	plainClass
+	subclass
+)
+
+type scopeEntry struct {
+	defined bool
+	symbol  *Symbol
+}
+
+// resolver walks a parsed program before it runs. It works out which
+// declaration each variable refers to, recording how many scopes out local
+// declarations are for the interpreter, and reports errors that can be
+// found without running the program.
+type resolver struct {
+	scopes          []map[string]*scopeEntry
+	locals          map[Expr]int
+	currentFunction functionType
+	currentClass    classType
+	displayError    func(string)
+	diagnostics     []Diagnostic
+
+	// globals are declared at the top level, where names can be used
+	// before they are declared, so references to them are linked up once
+	// the whole program has been seen
+	globals          map[string]*Symbol
+	symbols          []*Symbol
+	containers       []*Symbol
+	references       []Reference
+	globalReferences []int
+}
+
+func newResolver(displayError func(string)) *resolver {
+	return &resolver{
+		locals:       make(map[Expr]int),
+		displayError: displayError,
+		globals:      make(map[string]*Symbol),
+	}
+}
+
+func (r *resolver) resolve(statements []Stmt) {
+	r.resolveStmts(statements)
+	for _, idx := range r.globalReferences {
+		r.references[idx].Symbol = r.globals[r.references[idx].Token.Lexeme]
+	}
+}
+
+func (r *resolver) error(token Token, message string) {
+	r.diagnostics = append(r.diagnostics, Diagnostic{token, message})
+	if r.displayError != nil {
+		r.displayError(parseError(token, message))
+	}
+}
+
+func (r *resolver) resolveStmts(statements []Stmt) {
+	for _, stmt := range statements {
+		r.resolveStmt(stmt)
+	}
+}
+
+func (r *resolver) resolveStmt(stmt Stmt) {
+	switch stmt := stmt.(type) {
+	case *BlockStmt:
+		r.beginScope()
+		r.resolveStmts(stmt.Statements)
+		r.endScope()
+	case *VarStmt:
+		symbol := r.declare(stmt.Name, VariableSymbol, "var "+stmt.Name.Lexeme+annotation(stmt.Type))
+		if stmt.Initializer != nil {
+			r.resolveExpr(stmt.Initializer)
+		}
+		r.define(stmt.Name)
+		r.addSymbol(symbol)
+	case *FunctionStmt:
+		symbol := r.declare(stmt.Name, FunctionSymbol, "fun "+functionSignature(stmt))
+		r.define(stmt.Name)
+		r.addSymbol(symbol)
+		r.resolveFunction(stmt, plainFunction, symbol)
+	case *ClassStmt:
+		r.resolveClass(stmt)
+	case *ExpressionStmt:
+		r.resolveExpr(stmt.Expression)
+	case *IfStmt:
+		r.resolveExpr(stmt.Condition)
+		r.resolveStmt(stmt.ThenBranch)
+		if stmt.ElseBranch != nil {
+			r.resolveStmt(stmt.ElseBranch)
+		}
+	case *PrintStmt:
+		r.resolveExpr(stmt.Expression)
+	case *ReturnStmt:
+		if r.currentFunction == noFunction {
+			r.error(stmt.Keyword, "Can't return from top-level code")
+		}
+		if stmt.Value != nil {
+			if r.currentFunction == initializerFunction {
+				r.error(stmt.Keyword, "Can't return a value from an initializer")
+			}
+			r.resolveExpr(stmt.Value)
+		}
+	case *WhileStmt:
+		r.resolveExpr(stmt.Condition)
+		r.resolveStmt(stmt.Body)
+	}
+}
+
+func (r *resolver) resolveClass(stmt *ClassStmt) {
+	enclosingClass := r.currentClass
+	r.currentClass = plainClass
+
+	detail := "class " + stmt.Name.Lexeme
+	if stmt.Superclass != nil {
+		detail += " < " + stmt.Superclass.Name.Lexeme
+	}
+	symbol := r.declare(stmt.Name, ClassSymbol, detail)
+	r.define(stmt.Name)
+	r.addSymbol(symbol)
+
+	if stmt.Superclass != nil {
+		if stmt.Superclass.Name.Lexeme == stmt.Name.Lexeme {
+			r.error(stmt.Superclass.Name, "A class can't inherit from itself")
+		}
+		r.currentClass = subclass
+		r.resolveExpr(stmt.Superclass)
+		r.beginScope()
+		r.scopes[len(r.scopes)-1]["super"] = &scopeEntry{defined: true}
+	}
+
+	r.beginScope()
+	r.scopes[len(r.scopes)-1]["this"] = &scopeEntry{defined: true}
+	r.containers = append(r.containers, symbol)
+	for _, method := range stmt.Methods {
+		declaration := methodFunction
+		if method.Name.Lexeme == "init" {
+			declaration = initializerFunction
+		}
+		methodSymbol := &Symbol{Name: method.Name, Kind: MethodSymbol, Detail: stmt.Name.Lexeme + "." + functionSignature(method)}
+		r.addSymbol(methodSymbol)
+		r.resolveFunction(method, declaration, methodSymbol)
+	}
+	r.containers = r.containers[:len(r.containers)-1]
+	r.endScope()
+
+	if stmt.Superclass != nil {
+		r.endScope()
+	}
+	r.currentClass = enclosingClass
+}
+
+func (r *resolver) resolveFunction(function *FunctionStmt, ftype functionType, symbol *Symbol) {
+	enclosingFunction := r.currentFunction
+	r.currentFunction = ftype
+	r.containers = append(r.containers, symbol)
+
+	r.beginScope()
+	for _, param := range function.Params {
+		r.declare(param, ParameterSymbol, "parameter "+param.Lexeme)
+		r.define(param)
+	}
+	r.resolveStmts(function.Body)
+	r.endScope()
+
+	r.containers = r.containers[:len(r.containers)-1]
+	r.currentFunction = enclosingFunction
+}
+
+func (r *resolver) resolveExpr(expr Expr) {
+	switch expr := expr.(type) {
+	case *VariableExpr:
+		if len(r.scopes) > 0 {
+			if entry, ok := r.scopes[len(r.scopes)-1][expr.Name.Lexeme]; ok && !entry.defined {
+				r.error(expr.Name, "Can't read local variable in its own initializer")
+			}
+		}
+		r.resolveLocal(expr, expr.Name)
+	case *AssignExpr:
+		r.resolveExpr(expr.Value)
+		r.resolveLocal(expr, expr.Name)
+	case *BinaryExpr:
+		r.resolveExpr(expr.Left)
+		r.resolveExpr(expr.Right)
+	case *LogicalExpr:
+		r.resolveExpr(expr.Left)
+		r.resolveExpr(expr.Right)
+	case *ConditionalExpr:
+		r.resolveExpr(expr.Condition)
+		r.resolveExpr(expr.Then)
+		r.resolveExpr(expr.Else)
+	case *CommaExpr:
+		r.resolveExpr(expr.Left)
+		r.resolveExpr(expr.Right)
+	case *UnaryExpr:
+		r.resolveExpr(expr.Right)
+	case *GroupingExpr:
+		r.resolveExpr(expr.Expression)
+	case *CallExpr:
+		r.resolveExpr(expr.Callee)
+		for _, argument := range expr.Arguments {
+			r.resolveExpr(argument)
+		}
+	case *GetExpr:
+		r.resolveExpr(expr.Object)
+	case *SetExpr:
+		r.resolveExpr(expr.Value)
+		r.resolveExpr(expr.Object)
+	case *ThisExpr:
+		if r.currentClass == noClass {
+			r.error(expr.Keyword, "Can't use 'this' outside of a class")
+			return
+		}
+		r.resolveLocal(expr, expr.Keyword)
+	case *SuperExpr:
+		if r.currentClass == noClass {
+			r.error(expr.Keyword, "Can't use 'super' outside of a class")
+		} else if r.currentClass != subclass {
+			r.error(expr.Keyword, "Can't use 'super' in a class with no superclass")
+		}
+		r.resolveLocal(expr, expr.Keyword)
+	case *ListExpr:
+		for _, element := range expr.Elements {
+			r.resolveExpr(element)
+		}
+	case *MapExpr:
+		for idx, key := range expr.Keys {
+			r.resolveExpr(key)
+			r.resolveExpr(expr.Values[idx])
+		}
+	case *IndexExpr:
+		r.resolveExpr(expr.Object)
+		r.resolveExpr(expr.Index)
+	case *IndexSetExpr:
+		r.resolveExpr(expr.Object)
+		r.resolveExpr(expr.Index)
+		r.resolveExpr(expr.Value)
+	}
+}
+
+func (r *resolver) beginScope() {
+	r.scopes = append(r.scopes, make(map[string]*scopeEntry))
+}
+
+func (r *resolver) endScope() {
+	r.scopes = r.scopes[:len(r.scopes)-1]
+}
+
+func (r *resolver) declare(name Token, kind SymbolKind, detail string) *Symbol {
+	symbol := &Symbol{Name: name, Kind: kind, Detail: detail}
+	if len(r.scopes) == 0 {
+		if _, ok := r.globals[name.Lexeme]; !ok {
+			r.globals[name.Lexeme] = symbol
+		}
+		return symbol
+	}
+	scope := r.scopes[len(r.scopes)-1]
+	if _, ok := scope[name.Lexeme]; ok {
+		r.error(name, "Already a variable with this name in this scope")
+	}
+	scope[name.Lexeme] = &scopeEntry{symbol: symbol}
+	return symbol
+}
+
+func (r *resolver) define(name Token) {
+	if len(r.scopes) == 0 {
+		return
+	}
+	r.scopes[len(r.scopes)-1][name.Lexeme].defined = true
+}
+
+// addSymbol files a declaration under the function or class it is in
+func (r *resolver) addSymbol(symbol *Symbol) {
+	if len(r.containers) == 0 {
+		r.symbols = append(r.symbols, symbol)
+		return
+	}
+	container := r.containers[len(r.containers)-1]
+	container.Children = append(container.Children, symbol)
+}
+
+func (r *resolver) resolveLocal(expr Expr, name Token) {
+	for i := len(r.scopes) - 1; i >= 0; i-- {
+		if entry, ok := r.scopes[i][name.Lexeme]; ok {
+			r.locals[expr] = len(r.scopes) - 1 - i
+			// this and super have no declaration of their own
+			if entry.symbol != nil {
+				r.references = append(r.references, Reference{name, entry.symbol})
+			}
+			return
+		}
+	}
+	r.globalReferences = append(r.globalReferences, len(r.references))
+	r.references = append(r.references, Reference{Token: name})
+}
+
+func functionSignature(function *FunctionStmt) string {
+	params := make([]string, len(function.Params))
+	for i, param := range function.Params {
+		params[i] = param.Lexeme + annotation(function.ParamTypes[i])
+	}
+	return fmt.Sprintf("%s(%s)%s", function.Name.Lexeme, strings.Join(params, ", "), annotation(function.ReturnType))
+}
+
+// annotation writes out a type annotation, or nothing for the zero Token
+func annotation(typeName Token) string {
+	if typeName.Lexeme == "" {
+		return ""
+	}
+	return ": " + typeName.Lexeme
+}
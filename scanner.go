@@ -4,6 +4,8 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
+	"unicode/utf8"
 )
 
 type ScannerStep struct {
@@ -21,7 +23,17 @@ type scanner struct {
 	line           int
 	lineStart      int
 	calculateSteps bool
-	steps          []ScannerStep
+	recording      *ScannerRecording
+	diagnostics    []Diagnostic
+	// keepTrivia records the text between tokens in syntaxTokens, which
+	// then lines up with tokens
+	keepTrivia   bool
+	syntaxTokens []*SyntaxToken
+	trivia       []Trivia
+	// startLine and startColumn are where the token being scanned starts,
+	// for one that goes on to span lines
+	startLine   int
+	startColumn int
 }
 
 // displayError is a callback to show any errors found during scanning
@@ -30,13 +42,22 @@ func (s *scanner) scanTokens(displayError func(string)) ([]Token, error) {
 	s.current = 0
 	s.lineStart = 0
 	s.line = 1
+	if s.tokens == nil {
+		// Most Lox has a token every few bytes, starting near there saves
+		// copying the tokens as they grow
+		s.tokens = make([]Token, 0, len(s.source)/4+1)
+	}
 	hadError := false
 	for !s.isAtEnd() {
 		s.start = s.current
+		scanned := len(s.tokens)
 		err := s.scanToken(displayError)
 		if err != nil {
 			hadError = true
 		}
+		if s.keepTrivia && len(s.tokens) == scanned {
+			s.addTrivia()
+		}
 	}
 
 	s.start = s.current
@@ -48,16 +69,17 @@ func (s *scanner) scanTokens(displayError func(string)) ([]Token, error) {
 }
 
 // displayError is a callback to show any errors found during scanning
-func (s *scanner) scanTokensForSteps(displayError func(string)) ([]ScannerStep, error) {
+func (s *scanner) scanTokensForRecording(displayError func(string)) (*ScannerRecording, error) {
 	s.start = 0
 	s.current = 0
 	s.lineStart = 0
 	s.line = 1
 	s.calculateSteps = true
+	s.recording = &ScannerRecording{}
 	hadError := false
 	for !s.isAtEnd() {
 		s.start = s.current
-		s.addStep()
+		s.addStep(MoveStep)
 		err := s.scanToken(displayError)
 		if err != nil {
 			hadError = true
@@ -66,10 +88,11 @@ func (s *scanner) scanTokensForSteps(displayError func(string)) ([]ScannerStep,
 
 	s.start = s.current
 	s.addTokenWithLiteral(Eof, "")
+	s.recording.Tokens = s.tokens
 	if hadError {
-		return s.steps, errors.New("Error during scanning")
+		return s.recording, errors.New("Error during scanning")
 	}
-	return s.steps, nil
+	return s.recording, nil
 }
 
 func (s *scanner) isAtEnd() bool {
@@ -77,75 +100,86 @@ func (s *scanner) isAtEnd() bool {
 }
 
 func (s *scanner) scanToken(displayError func(string)) error {
+	s.startLine, s.startColumn = s.line, s.start-s.lineStart
 	c := s.advance()
 	switch c {
-	case "(":
+	case '(':
 		s.addToken(LeftParen)
-	case ")":
+	case ')':
 		s.addToken(RightParen)
-	case "{":
+	case '{':
 		s.addToken(LeftBrace)
-	case ",":
+	case '}':
+		s.addToken(RightBrace)
+	case '[':
+		s.addToken(LeftBracket)
+	case ']':
+		s.addToken(RightBracket)
+	case ':':
+		s.addToken(Colon)
+	case '?':
+		s.addToken(Question)
+	case ',':
 		s.addToken(Comma)
-	case "-":
+	case '-':
 		s.addToken(Minus)
-	case ".":
+	case '.':
 		s.addToken(Dot)
-	case "+":
+	case '+':
 		s.addToken(Plus)
-	case ";":
+	case ';':
 		s.addToken(Semicolon)
-	case "*":
+	case '*':
 		s.addToken(Star)
-	case "!":
+	case '!':
 		var t TokenType
-		if s.match("=") {
+		if s.match('=') {
 			t = BangEqual
 		} else {
 			t = Bang
 		}
 		s.addToken(t)
-	case "=":
+	case '=':
 		var t TokenType
-		if s.match("=") {
+		if s.match('=') {
 			t = EqualEqual
 		} else {
 			t = Equal
 		}
 		s.addToken(t)
-	case "<":
+	case '<':
 		var t TokenType
-		if s.match("=") {
+		if s.match('=') {
 			t = LessEqual
 		} else {
 			t = Less
 		}
 		s.addToken(t)
-	case ">":
+	case '>':
 		var t TokenType
-		if s.match("=") {
+		if s.match('=') {
 			t = GreaterEqual
 		} else {
 			t = Greater
 		}
 		s.addToken(t)
-	case "\"":
+	case '"':
 		err := s.handleString(displayError)
 		if err != nil {
 			return err
 		}
-	case "/":
-		if s.match("/") {
-			for s.peek() != "\n" && !s.isAtEnd() {
+	case '/':
+		if s.match('/') {
+			for s.peek() != '\n' && !s.isAtEnd() {
 				s.advance()
 			}
 		} else {
 			s.addToken(Slash)
 		}
-	case " ":
-	case "\r":
-	case "\t":
-	case "\n":
+	case ' ':
+	case '\r':
+	case '\t':
+	case '\n':
 		s.incrementLine()
 
 	default:
@@ -157,51 +191,52 @@ func (s *scanner) scanToken(displayError func(string)) error {
 		} else if isAlpha(c) {
 			s.handleIdentifier()
 		} else {
-			errorMsg := fmt.Sprintf("Unexpected character '%s' on line %d", c, s.line)
-			displayError(errorMsg)
-			return errors.New(errorMsg)
+			// Report the whole character rather than each of its bytes
+			r, size := utf8.DecodeRuneInString(s.source[s.current-1:])
+			s.current += size - 1
+			return s.error(displayError, fmt.Sprintf("Unexpected character '%c'", r))
 		}
 	}
 	return nil
 }
 
-func (s *scanner) match(expected string) bool {
+func (s *scanner) match(expected byte) bool {
 	if s.isAtEnd() {
 		return false
 	}
-	if string(s.source[s.current]) != expected {
+	if s.source[s.current] != expected {
 		return false
 	}
 	s.current++
-	s.addStep()
+	s.addStep(MoveStep)
 	return true
 }
 
-func (s *scanner) peek() string {
+func (s *scanner) peek() byte {
 	if s.isAtEnd() {
-		return "\000"
+		return 0
 	}
-	return string(s.source[s.current])
+	return s.source[s.current]
 }
 
-func (s *scanner) peekNext() string {
+func (s *scanner) peekNext() byte {
 	if s.current+1 >= len(s.source) {
-		return "\000"
+		return 0
 	}
-	return string(s.source[s.current+1])
+	return s.source[s.current+1]
 }
 
-func isAlpha(c string) bool {
-	return (c >= "a" && c <= "z") ||
-		(c >= "A" && c <= "Z") ||
-		c == "_"
+func isAlpha(c byte) bool {
+	return (c >= 'a' && c <= 'z') ||
+		(c >= 'A' && c <= 'Z') ||
+		c == '_'
 }
 
-func isDigit(c string) bool {
-	return c >= "0" && c <= "9"
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
 }
 
-func isAlphanumeric(c string) bool {
+func isAlphanumeric(c byte) bool {
 	return isAlpha(c) || isDigit(c)
 }
 
@@ -223,7 +258,7 @@ func (s *scanner) handleNumber(displayError func(string)) error {
 	for isDigit(s.peek()) {
 		s.advance()
 	}
-	if s.peek() == "." && isDigit(s.peekNext()) {
+	if s.peek() == '.' && isDigit(s.peekNext()) {
 		s.advance() // consume the "."
 
 		for isDigit(s.peek()) {
@@ -232,40 +267,56 @@ func (s *scanner) handleNumber(displayError func(string)) error {
 	}
 	num, err := strconv.ParseFloat(s.source[s.start:s.current], 64)
 	if err != nil {
-		errorMsg := fmt.Sprintf("Couldn't parse number on line %d", s.line)
-		displayError(errorMsg)
-		return errors.New(errorMsg)
+		return s.error(displayError, "Couldn't parse number")
 	}
 	s.addTokenWithLiteral(Number, num)
 	return nil
 }
 
 func (s *scanner) handleString(displayError func(string)) error {
-	for s.peek() != "\"" && !s.isAtEnd() {
-		if s.peek() == "\n" {
+	for s.peek() != '"' && !s.isAtEnd() {
+		if s.advance() == '\n' {
 			s.incrementLine()
 		}
-		s.advance()
 	}
 	if s.isAtEnd() {
-		errorMsg := fmt.Sprintf("Unterminated string on line %d", s.line)
-		displayError(errorMsg)
-		return errors.New(errorMsg)
+		return s.error(displayError, "Unterminated string")
 	}
 	s.advance()
 	s.addTokenWithLiteral(StringLiteral, s.source[s.start+1:s.current-1])
 	return nil
 }
 
+// error reports message for the text scanned since the start of the current
+// token
+func (s *scanner) error(displayError func(string), message string) error {
+	startLine, start := s.tokenStart()
+	token := Token{Lexeme: s.source[s.start:s.current], Line: s.line, StartLine: startLine, Start: start, End: s.current - s.lineStart}
+	s.diagnostics = append(s.diagnostics, Diagnostic{token, message})
+
+	errorMsg := fmt.Sprintf("%s on line %d", message, s.line)
+	displayError(errorMsg)
+	return errors.New(errorMsg)
+}
+
+// tokenStart returns the line and column the current token starts at
+func (s *scanner) tokenStart() (int, int) {
+	if s.start < s.lineStart {
+		// The token started on an earlier line
+		return s.startLine, s.startColumn
+	}
+	return s.line, s.start - s.lineStart
+}
+
 func (s *scanner) incrementLine() {
 	s.line++
 	s.lineStart = s.current
 }
 
-func (s *scanner) advance() string {
+func (s *scanner) advance() byte {
 	s.current++
-	s.addStep()
-	return string(s.source[s.current-1])
+	s.addStep(MoveStep)
+	return s.source[s.current-1]
 }
 
 func (s *scanner) addToken(ttype TokenType) {
@@ -274,14 +325,55 @@ func (s *scanner) addToken(ttype TokenType) {
 
 func (s *scanner) addTokenWithLiteral(ttype TokenType, literal interface{}) {
 	text := s.source[s.start:s.current]
-	token := Token{ttype, text, literal, s.line, s.start - s.lineStart, s.current - s.lineStart}
+	startLine, start := s.tokenStart()
+	token := Token{ttype, text, literal, s.line, startLine, start, s.current - s.lineStart}
 	s.tokens = append(s.tokens, token)
-	s.addStep()
+	if s.keepTrivia {
+		s.syntaxTokens = append(s.syntaxTokens, &SyntaxToken{Token: token, Leading: s.trivia})
+		s.trivia = nil
+	}
+	s.addStep(TokenStep)
 }
 
-func (s *scanner) addStep() {
+// addStep is small enough to be inlined, so scanning without steps only
+// pays for the check
+func (s *scanner) addStep(kind StepKind) {
 	if s.calculateSteps {
-		step := ScannerStep{Tokens: s.tokens, Current: s.current - s.lineStart, Start: s.start - s.lineStart, Line: s.line}
-		s.steps = append(s.steps, step)
+		s.recordStep(kind)
+	}
+}
+
+func (s *scanner) recordStep(kind StepKind) {
+	step := scannerDelta{kind, len(s.tokens), s.current - s.lineStart, s.start - s.lineStart, s.line}
+	s.recording.steps = append(s.recording.steps, step)
+}
+
+// addTrivia records the text scanned since the start of the current token,
+// which didn't make a token. Trivia up to the end of a token's line trails
+// it, anything after leads the next token.
+func (s *scanner) addTrivia() {
+	text := s.source[s.start:s.current]
+	var kind TriviaKind
+	switch {
+	case text == "\n":
+		kind = NewlineTrivia
+	case text == " " || text == "\t" || text == "\r":
+		kind = WhitespaceTrivia
+	case strings.HasPrefix(text, "//"):
+		kind = CommentTrivia
+	default:
+		// An unexpected character or an unterminated string
+		kind = SkippedTrivia
+	}
+
+	trivia := &s.trivia
+	if len(s.trivia) == 0 && len(s.syntaxTokens) > 0 && kind != NewlineTrivia {
+		trivia = &s.syntaxTokens[len(s.syntaxTokens)-1].Trailing
+	}
+	if n := len(*trivia); n > 0 && kind == WhitespaceTrivia && (*trivia)[n-1].Kind == WhitespaceTrivia {
+		// Runs of whitespace are one piece of trivia
+		(*trivia)[n-1].Text += text
+		return
 	}
+	*trivia = append(*trivia, Trivia{kind, text})
 }
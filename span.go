@@ -0,0 +1,38 @@
+package golox
+
+// Span is where an expression is in the source, from the start of its first
+// token to the end of its last, with columns counted like a Token's Start
+// and End. The zero Span is an expression that wasn't parsed from any
+// source.
+type Span struct {
+	StartLine int
+	Start     int
+	EndLine   int
+	End       int
+}
+
+func spanOf(first Token, last Token) Span {
+	return Span{first.StartLine, first.Start, last.Line, last.End}
+}
+
+// Contains reports whether the character at line and column is in the span.
+func (s Span) Contains(line int, column int) bool {
+	if line < s.StartLine || (line == s.StartLine && column < s.Start) {
+		return false
+	}
+	return line < s.EndLine || (line == s.EndLine && column < s.End)
+}
+
+// ExprAt returns the innermost expression under root whose span contains
+// the character at line and column, or nil when root's doesn't.
+func ExprAt(root Expr, line int, column int) Expr {
+	if !root.Span().Contains(line, column) {
+		return nil
+	}
+	for _, child := range root.Children() {
+		if expr := ExprAt(child, line, column); expr != nil {
+			return expr
+		}
+	}
+	return root
+}
@@ -0,0 +1,81 @@
+package golox
+
+import (
+	"reflect"
+	"testing"
+)
+
+// spanText returns the source a span covers
+func spanText(source string, span Span) string {
+	starts := lineStarts(source)
+	return source[starts[span.StartLine-1]+span.Start : starts[span.EndLine-1]+span.End]
+}
+
+func TestSpans(t *testing.T) {
+	tests := []struct {
+		source string
+		// want is the source each node covers, outermost first
+		want []string
+	}{
+		{"1 + 2 * 3", []string{"1 + 2 * 3", "1", "2 * 3", "2", "3"}},
+		{"(a + b) * c", []string{"(a + b) * c", "(a + b)", "a + b", "a", "b", "c"}},
+		{"-a.b", []string{"-a.b", "a.b", "a"}},
+		{"f(1, g(2))", []string{"f(1, g(2))", "f", "1", "g(2)", "g", "2"}},
+		{"a[1] = b", []string{"a[1] = b", "a", "1", "b"}},
+		{"a ? b : c", []string{"a ? b : c", "a", "b", "c"}},
+		{"[1, {\"k\": 2}]", []string{"[1, {\"k\": 2}]", "1", "{\"k\": 2}", "\"k\"", "2"}},
+		{"\"two\nlines\" + x", []string{"\"two\nlines\" + x", "\"two\nlines\"", "x"}},
+		{"a or\n  b", []string{"a or\n  b", "a", "b"}},
+	}
+	for _, test := range tests {
+		var errors []string
+		expr := RunParser(test.source, func(message string) {
+			errors = append(errors, message)
+		})
+		if len(errors) > 0 {
+			t.Errorf("%q reported %q", test.source, errors)
+			continue
+		}
+		var got []string
+		inspectExpr(expr, func(expr Expr) {
+			got = append(got, spanText(test.source, expr.Span()))
+		})
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q has spans covering %q, want %q", test.source, got, test.want)
+		}
+	}
+}
+
+func TestExprAt(t *testing.T) {
+	source := "foo(1 + 2,\n  bar)"
+	expr := RunParser(source, func(message string) {
+		t.Fatal(message)
+	})
+	tests := []struct {
+		line   int
+		column int
+		// want is the source the expression found covers, or "" for none
+		want string
+	}{
+		{1, 0, "foo"},
+		{1, 2, "foo"},
+		{1, 3, "foo(1 + 2,\n  bar)"},
+		{1, 4, "1"},
+		{1, 6, "1 + 2"},
+		{1, 8, "2"},
+		{2, 1, "foo(1 + 2,\n  bar)"},
+		{2, 3, "bar"},
+		{2, 5, "foo(1 + 2,\n  bar)"},
+		{2, 6, ""},
+		{3, 0, ""},
+	}
+	for _, test := range tests {
+		got := ""
+		if found := ExprAt(expr, test.line, test.column); found != nil {
+			got = spanText(source, found.Span())
+		}
+		if got != test.want {
+			t.Errorf("At %d:%d found %q, want %q", test.line, test.column, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package golox
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Capability grants scripts access to something outside the interpreter.
+// Natives that need a capability are always defined but fail with a runtime
+// error until it is allowed with Interpreter.Allow.
+type Capability int
+
+const (
+	// CapabilityInput lets scripts read lines from the interpreter's input
+	// with input()
+	CapabilityInput Capability = 1 << iota
+	// CapabilityReadFile lets scripts read any file with readFile()
+	CapabilityReadFile
+)
+
+// Allow grants scripts run by the interpreter the given capabilities, which
+// can be combined with |.
+func (i *Interpreter) Allow(capabilities Capability) {
+	i.capabilities |= capabilities
+}
+
+// SetInput sets where input() reads from. It defaults to os.Stdin.
+func (i *Interpreter) SetInput(in io.Reader) {
+	i.in = bufio.NewReader(in)
+}
+
+func (i *Interpreter) defineNative(name string, params int, fn NativeFunc) {
+	i.globals.define(name, &nativeFunction{name, params, fn})
+}
+
+func (i *Interpreter) defineStdlib() {
+	i.defineNative("clock", 0, func(args []interface{}) (interface{}, error) {
+		return float64(time.Now().UnixNano()) / float64(time.Second), nil
+	})
+
+	i.defineNative("len", 1, func(args []interface{}) (interface{}, error) {
+		switch v := args[0].(type) {
+		case *loxList:
+			return len(v.elements), nil
+		case *loxMap:
+			return len(v.keys), nil
+		}
+		s, err := stringArg("len", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return len([]rune(s)), nil
+	})
+	i.defineNative("substr", 3, func(args []interface{}) (interface{}, error) {
+		s, err := stringArg("substr", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		start, err := wholeArg("substr", args, 1)
+		if err != nil {
+			return nil, err
+		}
+		length, err := wholeArg("substr", args, 2)
+		if err != nil {
+			return nil, err
+		}
+		runes := []rune(s)
+		// Check the range before converting, the arguments may not fit in
+		// an int
+		size := float64(len(runes))
+		if start < 0 || length < 0 || start > size || length > size-start {
+			return nil, fmt.Errorf("substr() range %s to %s is outside a string of length %d", stringify(start), stringify(start+length), len(runes))
+		}
+		return string(runes[int(start) : int(start)+int(length)]), nil
+	})
+	i.defineNative("indexOf", 2, func(args []interface{}) (interface{}, error) {
+		s, err := stringArg("indexOf", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		sub, err := stringArg("indexOf", args, 1)
+		if err != nil {
+			return nil, err
+		}
+		index := strings.Index(s, sub)
+		if index < 0 {
+			return -1, nil
+		}
+		// Count in characters to match len() and substr()
+		return len([]rune(s[:index])), nil
+	})
+	i.defineNative("toUpper", 1, func(args []interface{}) (interface{}, error) {
+		s, err := stringArg("toUpper", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return strings.ToUpper(s), nil
+	})
+	i.defineNative("toLower", 1, func(args []interface{}) (interface{}, error) {
+		s, err := stringArg("toLower", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return strings.ToLower(s), nil
+	})
+	i.defineNative("str", 1, func(args []interface{}) (interface{}, error) {
+		return stringify(args[0]), nil
+	})
+	i.defineNative("num", 1, func(args []interface{}) (interface{}, error) {
+		s, err := stringArg("num", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		n, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return nil, fmt.Errorf("num() can't convert \"%s\" to a number", s)
+		}
+		return n, nil
+	})
+
+	i.defineNative("floor", 1, func(args []interface{}) (interface{}, error) {
+		n, err := numberArg("floor", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return math.Floor(n), nil
+	})
+	i.defineNative("sqrt", 1, func(args []interface{}) (interface{}, error) {
+		n, err := numberArg("sqrt", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return math.Sqrt(n), nil
+	})
+	i.defineNative("pow", 2, func(args []interface{}) (interface{}, error) {
+		base, err := numberArg("pow", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		exponent, err := numberArg("pow", args, 1)
+		if err != nil {
+			return nil, err
+		}
+		return math.Pow(base, exponent), nil
+	})
+	i.defineNative("random", 0, func(args []interface{}) (interface{}, error) {
+		return i.random.Float64(), nil
+	})
+	i.defineNative("seed", 1, func(args []interface{}) (interface{}, error) {
+		seed, err := intArg("seed", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		i.random.Seed(int64(seed))
+		return nil, nil
+	})
+
+	i.defineNative("input", 0, func(args []interface{}) (interface{}, error) {
+		if i.capabilities&CapabilityInput == 0 {
+			return nil, fmt.Errorf("input() is not allowed in this interpreter")
+		}
+		line, err := i.in.ReadString('\n')
+		if err == io.EOF && line == "" {
+			return nil, nil
+		}
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("input() failed: %v", err)
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	})
+	i.defineNative("readFile", 1, func(args []interface{}) (interface{}, error) {
+		if i.capabilities&CapabilityReadFile == 0 {
+			return nil, fmt.Errorf("readFile() is not allowed in this interpreter")
+		}
+		path, err := stringArg("readFile", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("readFile() failed: %v", err)
+		}
+		return string(b), nil
+	})
+}
+
+func typeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case *loxClass:
+		return "class"
+	case *loxInstance:
+		return "instance"
+	case *loxList:
+		return "list"
+	case *loxMap:
+		return "map"
+	case callable:
+		return "function"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func numberArg(name string, args []interface{}, index int) (float64, error) {
+	n, ok := args[index].(float64)
+	if !ok {
+		return 0, fmt.Errorf("%s() expects a number for argument %d but got %s", name, index+1, typeName(args[index]))
+	}
+	return n, nil
+}
+
+// wholeArg is numberArg for arguments that must be whole numbers
+func wholeArg(name string, args []interface{}, index int) (float64, error) {
+	n, err := numberArg(name, args, index)
+	if err != nil {
+		return 0, err
+	}
+	if n != math.Trunc(n) || math.IsInf(n, 0) {
+		return 0, fmt.Errorf("%s() expects a whole number for argument %d but got %s", name, index+1, stringify(n))
+	}
+	return n, nil
+}
+
+func intArg(name string, args []interface{}, index int) (int, error) {
+	n, err := wholeArg(name, args, index)
+	if err != nil {
+		return 0, err
+	}
+	// MaxInt rounds up to a power of two as a float, so it's out of range
+	if n < math.MinInt || n >= math.MaxInt {
+		return 0, fmt.Errorf("%s() argument %d is out of range: %s", name, index+1, stringify(n))
+	}
+	return int(n), nil
+}
+
+func stringArg(name string, args []interface{}, index int) (string, error) {
+	s, ok := args[index].(string)
+	if !ok {
+		return "", fmt.Errorf("%s() expects a string for argument %d but got %s", name, index+1, typeName(args[index]))
+	}
+	return s, nil
+}
+
+func newRandom() *rand.Rand {
+	return rand.New(rand.NewSource(time.Now().UnixNano()))
+}
@@ -0,0 +1,63 @@
+package golox
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSubstr(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{`print substr("abc", 0, 3);`, "abc\n"},
+		{`print substr("abc", 1, 1);`, "b\n"},
+		{`print substr("abc", 3, 0);`, "\n"},
+		{`print substr("héllo", 1, 3);`, "éll\n"},
+	}
+	for _, test := range tests {
+		out, err := runLox(t, test.source)
+		if err != nil {
+			t.Fatalf("%q failed: %v", test.source, err)
+		}
+		if out != test.want {
+			t.Errorf("%q printed %q, want %q", test.source, out, test.want)
+		}
+	}
+}
+
+func TestSubstrOutOfRange(t *testing.T) {
+	tests := []struct {
+		source  string
+		message string
+	}{
+		{`substr("abc", 4611686018427387904, 4611686018427387904);`, "is outside a string of length 3"},
+		{`substr("abc", 1, 9223372036854775807);`, "is outside a string of length 3"},
+		{`substr("abc", 100000000000000000000000, 0);`, "is outside a string of length 3"},
+		{`substr("abc", 4, 0);`, "range 4 to 4 is outside"},
+		{`substr("abc", 2, 2);`, "range 2 to 4 is outside"},
+		{`substr("abc", -1, 1);`, "is outside a string of length 3"},
+		{`substr("abc", 0, -1);`, "is outside a string of length 3"},
+		{`substr("abc", 1/0, 0);`, "expects a whole number for argument 2"},
+		{`substr("abc", 0, 0/0);`, "expects a whole number for argument 3"},
+		{`substr("abc", 0.5, 1);`, "expects a whole number for argument 2"},
+	}
+	for _, test := range tests {
+		_, err := runLox(t, test.source)
+		wantRuntimeError(t, test.source, err, test.message)
+	}
+}
+
+func TestSeed(t *testing.T) {
+	out, err := runLox(t, "seed(1700000000000); var a = random(); seed(1700000000000); print a == random();")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "true\n" {
+		t.Errorf("Reseeding printed %q, want true", out)
+	}
+	_, err = runLox(t, "seed(100000000000000000000000);")
+	if err == nil || !strings.Contains(err.Error(), "seed() argument 1 is out of range") {
+		t.Errorf("Seeding with a huge number returned %v", err)
+	}
+}
@@ -0,0 +1,280 @@
+package golox
+
+import "fmt"
+
+// StepKind says what changed at a step of a scanner or parser recording.
+type StepKind int
+
+const (
+	// MoveStep adds and removes nothing, the scanner moved along or the
+	// expression being parsed was filled in
+	MoveStep StepKind = iota
+	// TokenStep is the scanner adding a token
+	TokenStep
+	ExprPushStep
+	ExprPopStep
+	LogPushStep
+	LogPopStep
+	// StmtStep is the interpreter starting a statement
+	StmtStep
+	// ValueStep is the interpreter finishing evaluating an expression
+	ValueStep
+)
+
+var stepKindNames = []string{"Move", "Token", "ExprPush", "ExprPop", "LogPush", "LogPop", "Stmt", "Value"}
+
+// Recording is the steps of the scanner, parser or interpreter, which a
+// StepPlayer plays back. Steps are numbered from 0.
+type Recording interface {
+	Len() int
+	Kind(n int) StepKind
+	// TokenIndex is the token step n is at, or -1 when it isn't at one
+	TokenIndex(n int) int
+	// NodeOrder is the Order() of the expression step n is about, or 0
+	// when it isn't about one
+	NodeOrder(n int) int
+}
+
+func (k StepKind) String() string {
+	if k < 0 || int(k) >= len(stepKindNames) {
+		return fmt.Sprintf("StepKind(%d)", int(k))
+	}
+	return stepKindNames[k]
+}
+
+// ScannerRecording is the steps of a scan, stored as what changed at each
+// one instead of all the tokens so far. Step rebuilds any of them.
+type ScannerRecording struct {
+	Tokens []Token
+	steps  []scannerDelta
+}
+
+type scannerDelta struct {
+	kind StepKind
+	// tokens is how many of the recording's tokens had been added
+	tokens  int
+	current int
+	start   int
+	line    int
+}
+
+func (r *ScannerRecording) Len() int {
+	return len(r.steps)
+}
+
+func (r *ScannerRecording) Kind(n int) StepKind {
+	return r.steps[n].kind
+}
+
+// TokenIndex is the token being scanned, or the one just added at a
+// TokenStep.
+func (r *ScannerRecording) TokenIndex(n int) int {
+	if r.steps[n].kind == TokenStep {
+		return r.steps[n].tokens - 1
+	}
+	return r.steps[n].tokens
+}
+
+func (r *ScannerRecording) NodeOrder(n int) int {
+	return 0
+}
+
+// Step returns step n as RunScannerForSteps would have.
+func (r *ScannerRecording) Step(n int) ScannerStep {
+	d := r.steps[n]
+	return ScannerStep{Tokens: r.Tokens[:d.tokens:d.tokens], Current: d.current, Start: d.start, Line: d.line}
+}
+
+// Steps returns every step.
+func (r *ScannerRecording) Steps() []ScannerStep {
+	steps := make([]ScannerStep, len(r.steps))
+	for n := range steps {
+		steps[n] = r.Step(n)
+	}
+	return steps
+}
+
+// ParserRecording is the steps of a parse. Each step keeps the expression
+// and log stacks as linked lists sharing everything under the top with the
+// step before, and the top expression shares the children it had finished
+// parsing, so a step costs about the same however big the tree is.
+type ParserRecording struct {
+	steps []parserDelta
+}
+
+type parserDelta struct {
+	kind StepKind
+	// current is the index of the next token to be consumed
+	current int
+	exprs   *exprCell
+	logs    *logCell
+}
+
+// exprCell is an expression on the stack as it was at a step. The
+// expression is frozen, except that its child being parsed is whatever the
+// cell above holds.
+type exprCell struct {
+	expr  Expr
+	below *exprCell
+	depth int
+}
+
+type logCell struct {
+	log   string
+	below *logCell
+	depth int
+}
+
+func (r *ParserRecording) Len() int {
+	return len(r.steps)
+}
+
+func (r *ParserRecording) Kind(n int) StepKind {
+	return r.steps[n].kind
+}
+
+// TokenIndex is the next token to be consumed.
+func (r *ParserRecording) TokenIndex(n int) int {
+	return r.steps[n].current
+}
+
+// NodeOrder is the expression on top of the stack.
+func (r *ParserRecording) NodeOrder(n int) int {
+	if r.steps[n].exprs == nil {
+		return 0
+	}
+	return r.steps[n].exprs.expr.Order()
+}
+
+// Step rebuilds step n as RunParserForSteps would have returned it.
+func (r *ParserRecording) Step(n int) ParserStep {
+	d := r.steps[n]
+	step := ParserStep{Exprs: make([]Expr, 0), Logs: make([]string, 0), Current: d.current}
+	if d.exprs != nil {
+		step.Exprs = make([]Expr, d.exprs.depth)
+	}
+	for cell := d.exprs; cell != nil; cell = cell.below {
+		idx := cell.depth - 1
+		step.Exprs[idx] = cell.expr.Copy()
+		if idx+1 < len(step.Exprs) {
+			step.Exprs[idx].UpdateChildExpr(step.Exprs[idx+1])
+		}
+	}
+	if d.logs != nil {
+		step.Logs = make([]string, d.logs.depth)
+	}
+	for cell := d.logs; cell != nil; cell = cell.below {
+		step.Logs[cell.depth-1] = cell.log
+	}
+	return step
+}
+
+// Steps rebuilds every step.
+func (r *ParserRecording) Steps() []ParserStep {
+	steps := make([]ParserStep, len(r.steps))
+	for n := range steps {
+		steps[n] = r.Step(n)
+	}
+	return steps
+}
+
+// recordStep adds a step to the parser's recording. Between two steps the
+// parser only pushes or pops one expression or log, and only changes the
+// expression on top of the stack, so that's all that needs copying.
+func (p *parser) recordStep(kind StepKind) {
+	switch kind {
+	case LogPushStep:
+		p.logCell = &logCell{p.logs[len(p.logs)-1], p.logCell, len(p.logs)}
+	case LogPopStep:
+		p.logCell = p.logCell.below
+	}
+
+	n := len(p.exprs)
+	if len(p.exprCells) > n {
+		p.exprCells = p.exprCells[:n]
+	}
+	var top *exprCell
+	if n > 0 {
+		var below *exprCell
+		if n > 1 {
+			below = p.exprCells[n-2]
+		}
+		top = &exprCell{p.freeze(p.exprs[n-1]), below, n}
+		p.exprCells = append(p.exprCells[:n-1], top)
+	}
+	p.recording.steps = append(p.recording.steps, parserDelta{kind, p.current, top, p.logCell})
+}
+
+// freeze copies expr as it is now. Children that have been frozen before
+// are finished, so the copy shares them.
+func (p *parser) freeze(expr Expr) Expr {
+	if p.frozen == nil {
+		p.frozen = make(map[Expr]Expr)
+	}
+	frozen := copyExprWith(expr, func(child Expr) Expr {
+		if frozen, ok := p.frozen[child]; ok {
+			return frozen
+		}
+		return child.Copy()
+	})
+	p.frozen[expr] = frozen
+	return frozen
+}
+
+// EvalStep is a step of running a program, either a StmtStep for a
+// statement about to run or a ValueStep for an expression that has been
+// evaluated. Value is printed the way print would, Order is the
+// expression's Order().
+type EvalStep struct {
+	Kind  StepKind
+	Token Token
+	Order int
+	Value string
+}
+
+// EvaluatorRecording is the steps of running a program with
+// RunForRecording, along with the tokens the program was scanned into.
+type EvaluatorRecording struct {
+	Tokens []Token
+	steps  []EvalStep
+	// tokenIndexes are found from the steps' tokens once the run is over
+	tokenIndexes []int
+}
+
+func (r *EvaluatorRecording) Len() int {
+	return len(r.steps)
+}
+
+func (r *EvaluatorRecording) Kind(n int) StepKind {
+	return r.steps[n].Kind
+}
+
+func (r *EvaluatorRecording) Step(n int) EvalStep {
+	return r.steps[n]
+}
+
+func (r *EvaluatorRecording) TokenIndex(n int) int {
+	return r.tokenIndexes[n]
+}
+
+func (r *EvaluatorRecording) NodeOrder(n int) int {
+	return r.steps[n].Order
+}
+
+// findTokens looks up where each step's token is in tokens
+func (r *EvaluatorRecording) findTokens(tokens []Token) {
+	type position struct{ line, start int }
+	indexes := make(map[position]int, len(tokens))
+	for idx, token := range tokens {
+		indexes[position{token.StartLine, token.Start}] = idx
+	}
+	r.Tokens = tokens
+	r.tokenIndexes = make([]int, len(r.steps))
+	for n, step := range r.steps {
+		idx, ok := indexes[position{step.Token.StartLine, step.Token.Start}]
+		if !ok || step.Token.Lexeme == "" {
+			idx = -1
+		}
+		r.tokenIndexes[n] = idx
+	}
+}
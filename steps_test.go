@@ -0,0 +1,93 @@
+package golox
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestScannerRecording(t *testing.T) {
+	for _, source := range seedPrograms {
+		recording := RunScannerForRecording(source, func(string) {})
+		tokens := RunScanner(source, func(string) {})
+		if !reflect.DeepEqual(recording.Tokens, tokens) {
+			t.Errorf("%q recorded tokens %v, want %v", source, recording.Tokens, tokens)
+		}
+		steps := recording.Steps()
+		if len(steps) == 0 || len(steps[len(steps)-1].Tokens) != len(tokens) {
+			t.Errorf("%q doesn't end with every token", source)
+			continue
+		}
+		for n := 1; n < len(steps); n++ {
+			// Only a token step adds a token, and just the one
+			added := len(steps[n].Tokens) - len(steps[n-1].Tokens)
+			if (recording.Kind(n) == TokenStep) != (added == 1) || added < 0 || added > 1 {
+				t.Errorf("%q step %d is a %s step that added %d tokens", source, n, recording.Kind(n), added)
+			}
+			// Current is a column, it only goes back when a new line starts
+			if steps[n].Line < steps[n-1].Line || (steps[n].Line == steps[n-1].Line && steps[n].Current < steps[n-1].Current) {
+				t.Errorf("%q step %d went from %+v to %+v", source, n, steps[n-1], steps[n])
+			}
+		}
+	}
+}
+
+func TestParserRecording(t *testing.T) {
+	for _, source := range seedExprs {
+		recording, _ := RunParserForRecording(source, func(string) {})
+		steps := recording.Steps()
+		for n, step := range steps {
+			// Rebuilding a step on its own gives what rebuilding them all
+			// in order does
+			if got := recording.Step(n); formatSteps(got) != formatSteps(step) || !reflect.DeepEqual(got.Logs, step.Logs) {
+				t.Errorf("%q step %d rebuilt on its own is %+v, want %+v", source, n, got, step)
+			}
+			if n == 0 {
+				continue
+			}
+			exprs := len(step.Exprs) - len(steps[n-1].Exprs)
+			logs := len(step.Logs) - len(steps[n-1].Logs)
+			var want [2]int
+			switch recording.Kind(n) {
+			case ExprPushStep:
+				want = [2]int{1, 0}
+			case ExprPopStep:
+				want = [2]int{-1, 0}
+			case LogPushStep:
+				want = [2]int{0, 1}
+			case LogPopStep:
+				want = [2]int{0, -1}
+			}
+			// Popping the only expression isn't a step of its own, so
+			// the push replacing it leaves the stack as deep as it was
+			if recording.Kind(n) == ExprPushStep && exprs == 0 && len(step.Exprs) == 1 {
+				want[0] = 0
+			}
+			if [2]int{exprs, logs} != want {
+				t.Errorf("%q step %d is a %s step that changed the stacks by %d expressions and %d logs", source, n, recording.Kind(n), exprs, logs)
+			}
+		}
+
+		last := steps[len(steps)-1]
+		if want := FormatExpr(RunParser(source, func(string) {})); len(last.Exprs) != 1 || FormatExpr(last.Exprs[0]) != want {
+			t.Errorf("%q ends with\n%s\nwant %s", source, formatSteps(last), want)
+		}
+	}
+}
+
+func TestParserRecordingKeepsEachStep(t *testing.T) {
+	// Later steps fill in the tree, the earlier ones still show it as it was
+	steps, _ := RunParserForSteps("1 + 2 * 3", func(string) {})
+	var got []string
+	for _, step := range steps {
+		if len(step.Exprs) == 0 {
+			continue
+		}
+		if tree := FormatExpr(step.Exprs[0]); len(got) == 0 || got[len(got)-1] != tree {
+			got = append(got, tree)
+		}
+	}
+	want := []string{"1", "(+ 1 ??)", "(+ 1 2)", "(+ 1 (* 2 ??))", "(+ 1 (* 2 3))"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Went through %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,51 @@
+package golox
+
+// Stmt is a statement in a Lox program. The node types are generated from
+// ast.txt. Kind says which node type a statement is. Token returns the token
+// the statement starts with, which is used to report which line a statement
+// is on.
+type Stmt interface {
+	Kind() StmtKind
+	Token() Token
+}
+
+// NewExpressionStmt makes an ExpressionStmt for expression starting at token.
+func NewExpressionStmt(expression Expr, token Token) *ExpressionStmt {
+	return &ExpressionStmt{Expression: expression, token: token}
+}
+
+func (stmt *ExpressionStmt) Token() Token {
+	return stmt.token
+}
+
+func (stmt *PrintStmt) Token() Token {
+	return stmt.Keyword
+}
+
+func (stmt *VarStmt) Token() Token {
+	return stmt.Keyword
+}
+
+func (stmt *BlockStmt) Token() Token {
+	return stmt.Brace
+}
+
+func (stmt *IfStmt) Token() Token {
+	return stmt.Keyword
+}
+
+func (stmt *WhileStmt) Token() Token {
+	return stmt.Keyword
+}
+
+func (stmt *FunctionStmt) Token() Token {
+	return stmt.Name
+}
+
+func (stmt *ReturnStmt) Token() Token {
+	return stmt.Keyword
+}
+
+func (stmt *ClassStmt) Token() Token {
+	return stmt.Name
+}
@@ -0,0 +1,169 @@
+package golox
+
+import "strings"
+
+// TriviaKind says what a piece of Trivia is.
+type TriviaKind int
+
+const (
+	WhitespaceTrivia TriviaKind = iota
+	NewlineTrivia
+	CommentTrivia
+	// SkippedTrivia is source the scanner couldn't make a token from
+	SkippedTrivia
+)
+
+// Trivia is source text between tokens.
+type Trivia struct {
+	Kind TriviaKind
+	Text string
+}
+
+// SyntaxElement is a SyntaxNode or a SyntaxToken.
+type SyntaxElement interface {
+	// Text is the source the element was parsed from, trivia included
+	Text() string
+}
+
+// SyntaxToken is a token along with the trivia around it. Trailing trivia
+// runs up to the end of the token's line, the newline and everything after
+// it lead the next token.
+type SyntaxToken struct {
+	Token    Token
+	Leading  []Trivia
+	Trailing []Trivia
+}
+
+func (t *SyntaxToken) Text() string {
+	var b strings.Builder
+	t.writeText(&b)
+	return b.String()
+}
+
+func (t *SyntaxToken) writeText(b *strings.Builder) {
+	for _, trivia := range t.Leading {
+		b.WriteString(trivia.Text)
+	}
+	b.WriteString(t.Token.Lexeme)
+	for _, trivia := range t.Trailing {
+		b.WriteString(trivia.Text)
+	}
+}
+
+// SyntaxNode is a piece of a concrete syntax tree. Every token of the source
+// is in the tree exactly once, so the root's Text is the source it was
+// parsed from.
+type SyntaxNode struct {
+	// Kind names the construct, like "Binary", "Print" or "Program". Tokens
+	// skipped while recovering from a syntax error are in an "Error" node.
+	Kind     string
+	Children []SyntaxElement
+	// Expr or Stmt is the node of the abstract syntax tree parsed from
+	// this syntax, when it has one
+	Expr Expr
+	Stmt Stmt
+}
+
+func (n *SyntaxNode) Text() string {
+	var b strings.Builder
+	for _, token := range n.Tokens() {
+		token.writeText(&b)
+	}
+	return b.String()
+}
+
+// Tokens returns every token under the node in source order.
+func (n *SyntaxNode) Tokens() []*SyntaxToken {
+	var tokens []*SyntaxToken
+	for _, child := range n.Children {
+		switch child := child.(type) {
+		case *SyntaxToken:
+			tokens = append(tokens, child)
+		case *SyntaxNode:
+			tokens = append(tokens, child.Tokens()...)
+		}
+	}
+	return tokens
+}
+
+// Statements returns the abstract syntax tree of a program's syntax tree,
+// leaving out statements that failed to parse.
+func (n *SyntaxNode) Statements() []Stmt {
+	var statements []Stmt
+	for _, child := range n.Children {
+		if child, ok := child.(*SyntaxNode); ok && child.Stmt != nil {
+			statements = append(statements, child.Stmt)
+		}
+	}
+	return statements
+}
+
+// ParseSyntax parses source into a concrete syntax tree whose root is a
+// "Program" node.
+func ParseSyntax(source string, displayError func(string)) *SyntaxNode {
+	s := scanner{source: source, keepTrivia: true}
+	tokens, _ := s.scanTokens(displayError)
+	p := parser{tokens: tokens, displayError: displayError, syntax: &syntaxBuilder{tokens: s.syntaxTokens}}
+	p.parseProgram()
+	// The end of file token holds the trivia after the last token
+	p.syntax.elements = append(p.syntax.elements, s.syntaxTokens[len(s.syntaxTokens)-1])
+	return &SyntaxNode{Kind: "Program", Children: p.syntax.elements}
+}
+
+// syntaxBuilder puts together a syntax tree as the parser runs. Consumed
+// tokens are added to a list of elements, and once the parser knows what a
+// run of them was it replaces them with a node. The run can start before the
+// parser knew a node was coming, as with the left operand of a binary
+// expression.
+type syntaxBuilder struct {
+	tokens   []*SyntaxToken
+	elements []SyntaxElement
+}
+
+// syntaxMark is where a node starts, as an element of the syntax tree being
+// built and as the index of its first token
+type syntaxMark struct {
+	element int
+	token   int
+}
+
+// mark returns where a node the parser is about to parse starts
+func (p *parser) mark() syntaxMark {
+	mark := syntaxMark{token: p.current}
+	if p.syntax != nil {
+		mark.element = len(p.syntax.elements)
+	}
+	return mark
+}
+
+// finishNode wraps everything parsed since mark into a node. Nodes without
+// any tokens are left out.
+func (p *parser) finishNode(mark syntaxMark, kind string, expr Expr, stmt Stmt) {
+	if p.syntax == nil || mark.element >= len(p.syntax.elements) {
+		return
+	}
+	children := make([]SyntaxElement, len(p.syntax.elements)-mark.element)
+	copy(children, p.syntax.elements[mark.element:])
+	p.syntax.elements = append(p.syntax.elements[:mark.element], &SyntaxNode{Kind: kind, Children: children, Expr: expr, Stmt: stmt})
+}
+
+// finishExpr wraps everything parsed since mark into a node for the
+// expression on top of the stack and sets its span, noting the tokens it
+// was parsed from as well when they're being kept
+func (p *parser) finishExpr(mark syntaxMark) {
+	expr := p.getExpr()
+	if p.current > mark.token {
+		expr.setSpan(spanOf(p.tokens[mark.token], p.previous()))
+	}
+	if p.nodeTokens != nil {
+		p.nodeTokens[expr.Order()] = TokenRange{mark.token, p.current - 1}
+	}
+	p.finishNode(mark, expr.Kind().String(), expr, nil)
+}
+
+// addSyntaxToken adds the token at index to the syntax tree
+func (p *parser) addSyntaxToken(index int) {
+	if p.syntax != nil {
+		p.syntax.elements = append(p.syntax.elements, p.syntax.tokens[index])
+	}
+}
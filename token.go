@@ -4,9 +4,13 @@ type Token struct {
 	Ttype   TokenType
 	Lexeme  string
 	Literal interface{}
-	Line    int
-	// Start marks the start position of this token on this line
+	// Line is the line the token ends on, which is the one it's on unless
+	// it spans lines, like a multi-line string
+	Line int
+	// StartLine is the line the token starts on
+	StartLine int
+	// Start marks the start position of this token on StartLine
 	Start int
-	// End marks the end position of this token on this line
+	// End marks the end position of this token on Line
 	End int
 }
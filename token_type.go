@@ -69,6 +69,14 @@ const (
 	WhileKeyword  TokenType = 37
 
 	Eof TokenType = 38
+
+	// Collection tokens
+	LeftBracket  TokenType = 39
+	RightBracket TokenType = 40
+	Colon        TokenType = 41
+
+	// Conditional operator
+	Question TokenType = 42
 )
 
 func (ttype *TokenType) String() string {
@@ -158,6 +166,19 @@ func (ttype *TokenType) String() string {
 
 	case Eof:
 		return "Eof"
+
+	// Collection tokens
+	case LeftBracket:
+		return "LeftBracket"
+	case RightBracket:
+		return "RightBracket"
+	case Colon:
+		return "Colon"
+
+	// Conditional operator
+	case Question:
+		return "Question"
+
 	default:
 		return "Unknown"
 
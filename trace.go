@@ -0,0 +1,81 @@
+package golox
+
+import "io/ioutil"
+
+// TokenRange is the tokens from First to Last, both included, as indexes
+// into a scan's tokens.
+type TokenRange struct {
+	First int
+	Last  int
+}
+
+// Trace follows an expression through scanning, parsing and evaluating. The
+// stages share the one scan's tokens, so a parser step's TokenIndex, a node's
+// TokenRange and an evaluator step's token all index into Tokens, and the
+// evaluator's steps are about the same nodes, by Order(), as the parser's.
+type Trace struct {
+	Tokens    []Token
+	Scanner   *ScannerRecording
+	Parser    *ParserRecording
+	Evaluator *EvaluatorRecording
+	// Expr is what was parsed, it's only evaluated when there were no
+	// errors scanning or parsing
+	Expr Expr
+	// Value is the result printed the way print would, Err is the error
+	// evaluating stopped with
+	Value string
+	Err   error
+	// nodeTokens is keyed by Order()
+	nodeTokens map[int]TokenRange
+}
+
+// NodeTokens returns the tokens the expression with the given Order() was
+// parsed from, and whether there is such an expression. Placeholders left
+// by syntax errors don't have any.
+func (t *Trace) NodeTokens(order int) (TokenRange, bool) {
+	tokens, ok := t.nodeTokens[order]
+	return tokens, ok
+}
+
+// RunTrace scans, parses and evaluates the expression in source, recording
+// each stage.
+func RunTrace(source string, displayError func(string)) *Trace {
+	hadError := false
+	onError := func(errorMsg string) {
+		hadError = true
+		displayError(errorMsg)
+	}
+
+	s := scanner{source: source}
+	scanning, _ := s.scanTokensForRecording(onError)
+	p := parser{tokens: scanning.Tokens, displayError: onError, nodeTokens: make(map[int]TokenRange)}
+	parsing := p.parseForRecording()
+	trace := &Trace{
+		Tokens:     scanning.Tokens,
+		Scanner:    scanning,
+		Parser:     parsing,
+		Evaluator:  &EvaluatorRecording{},
+		Expr:       &UnknownExpr{},
+		nodeTokens: p.nodeTokens,
+	}
+	if len(p.exprs) > 0 {
+		trace.Expr = p.exprs[0]
+	}
+	if !hadError && !p.isAtEnd() {
+		p.error(p.peek(), "Expected end of expression")
+	}
+
+	if !hadError {
+		i := NewInterpreter(ioutil.Discard)
+		i.recording = trace.Evaluator
+		value, err := i.evaluate(trace.Expr)
+		if err != nil {
+			trace.Err = err
+			displayError(err.Error())
+		} else {
+			trace.Value = stringify(value)
+		}
+	}
+	trace.Evaluator.findTokens(trace.Tokens)
+	return trace
+}
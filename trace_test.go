@@ -0,0 +1,108 @@
+package golox
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// traceLexemes returns the lexemes in a range of the trace's tokens
+func traceLexemes(trace *Trace, tokens TokenRange) string {
+	var lexemes []string
+	for idx := tokens.First; idx <= tokens.Last; idx++ {
+		lexemes = append(lexemes, trace.Tokens[idx].Lexeme)
+	}
+	return strings.Join(lexemes, " ")
+}
+
+func TestTrace(t *testing.T) {
+	var errors []string
+	trace := RunTrace("(1 + 2) * -3", func(message string) {
+		errors = append(errors, message)
+	})
+	if len(errors) > 0 || trace.Err != nil {
+		t.Fatal(errors, trace.Err)
+	}
+	if trace.Value != "-9" {
+		t.Errorf("Evaluated to %q, want -9", trace.Value)
+	}
+	if !reflect.DeepEqual(trace.Scanner.Tokens, trace.Tokens) {
+		t.Error("The scanner's tokens aren't the trace's")
+	}
+
+	// Every node knows the tokens it was parsed from
+	var got []string
+	inspectExpr(trace.Expr, func(expr Expr) {
+		tokens, ok := trace.NodeTokens(expr.Order())
+		if !ok {
+			t.Errorf("%s has no tokens", FormatExpr(expr))
+			return
+		}
+		got = append(got, traceLexemes(trace, tokens))
+	})
+	want := []string{"( 1 + 2 ) * - 3", "( 1 + 2 )", "1 + 2", "1", "2", "- 3", "3"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Nodes were parsed from %q, want %q", got, want)
+	}
+
+	// The evaluator's steps are about the parser's nodes and the scan's
+	// tokens, and the last one is the whole expression's value
+	orders := make(map[int]bool)
+	inspectExpr(trace.Expr, func(expr Expr) {
+		orders[expr.Order()] = true
+	})
+	evaluator := trace.Evaluator
+	if evaluator.Len() == 0 {
+		t.Fatal("Nothing was evaluated")
+	}
+	for n := 0; n < evaluator.Len(); n++ {
+		if !orders[evaluator.NodeOrder(n)] {
+			t.Errorf("Evaluator step %d is about node %d, which wasn't parsed", n, evaluator.NodeOrder(n))
+		}
+		if idx := evaluator.TokenIndex(n); idx < 0 || trace.Tokens[idx] != evaluator.Step(n).Token {
+			t.Errorf("Evaluator step %d is at token %d, want %+v", n, idx, evaluator.Step(n).Token)
+		}
+	}
+	last := evaluator.Step(evaluator.Len() - 1)
+	if last.Order != trace.Expr.Order() || last.Value != "-9" {
+		t.Errorf("Last evaluated %+v, want the whole expression", last)
+	}
+
+	// A player over the parser's steps finds the node the evaluator is at
+	player := NewStepPlayer(trace.Parser)
+	if !player.SeekToNode(last.Order) || trace.Parser.NodeOrder(player.Current()) != last.Order {
+		t.Errorf("Couldn't find node %d in the parser's steps", last.Order)
+	}
+}
+
+func TestTraceErrors(t *testing.T) {
+	var errors []string
+	trace := RunTrace("1 + ", func(message string) {
+		errors = append(errors, message)
+	})
+	if len(errors) != 1 || trace.Evaluator.Len() != 0 || trace.Value != "" {
+		t.Errorf("Reported %q and evaluated %d steps, want a syntax error and nothing evaluated", errors, trace.Evaluator.Len())
+	}
+	if trace.Parser.Len() == 0 {
+		t.Error("No parser steps were recorded")
+	}
+
+	errors = nil
+	trace = RunTrace("1 2", func(message string) {
+		errors = append(errors, message)
+	})
+	if len(errors) != 1 || !strings.Contains(errors[0], "Expected end of expression") {
+		t.Errorf("Reported %q, want the trailing token to be an error", errors)
+	}
+
+	errors = nil
+	trace = RunTrace("1 + -\"a\"", func(message string) {
+		errors = append(errors, message)
+	})
+	if trace.Err == nil || len(errors) != 1 || errors[0] != trace.Err.Error() {
+		t.Errorf("Failed with %v and reported %q, want a runtime error", trace.Err, errors)
+	}
+	if trace.Evaluator.Len() == 0 {
+		t.Error("The steps before the runtime error weren't recorded")
+	}
+}
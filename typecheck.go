@@ -0,0 +1,715 @@
+package golox
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TypeKind says what kind of value a Type is.
+type TypeKind int
+
+const (
+	// AnyType is a value the checker can't be sure of the type of
+	AnyType TypeKind = iota
+	NumberType
+	StringType
+	BoolType
+	NilType
+	FunctionType
+	ClassType
+	InstanceType
+)
+
+// Type is what the type checker knows about a value.
+type Type struct {
+	Kind TypeKind
+	// Params and Return are a function's, or for a class its initializer's
+	// parameters and the instance it makes. Params is nil when how many
+	// there are isn't known, and a nil Return hasn't been worked out yet.
+	Params []*Type
+	Return *Type
+	// Class names a class, or the class of an instance
+	Class string
+	// Superclass is a class's superclass, or for an instance the type of
+	// the superclass's instances. It's nil without a superclass.
+	Superclass *Type
+}
+
+var (
+	anyType    = &Type{Kind: AnyType}
+	numberType = &Type{Kind: NumberType}
+	stringType = &Type{Kind: StringType}
+	boolType   = &Type{Kind: BoolType}
+	nilType    = &Type{Kind: NilType}
+)
+
+func (t *Type) String() string {
+	switch t.Kind {
+	case NumberType:
+		return "number"
+	case StringType:
+		return "string"
+	case BoolType:
+		return "bool"
+	case NilType:
+		return "nil"
+	case FunctionType:
+		params := make([]string, len(t.Params))
+		for idx, param := range t.Params {
+			params[idx] = param.String()
+		}
+		return fmt.Sprintf("fun(%s): %s", strings.Join(params, ", "), orAny(t.Return))
+	case ClassType:
+		return "class " + t.Class
+	case InstanceType:
+		return t.Class
+	}
+	return "any"
+}
+
+// orAny is t, or AnyType when it hasn't been worked out
+func orAny(t *Type) *Type {
+	if t == nil {
+		return anyType
+	}
+	return t
+}
+
+// sameType reports whether values of types a and b are always the same kind
+// of value
+func sameType(a *Type, b *Type) bool {
+	if a == b {
+		return true
+	}
+	if a == nil || b == nil || a.Kind != b.Kind {
+		return false
+	}
+	switch a.Kind {
+	case FunctionType:
+		if len(a.Params) != len(b.Params) {
+			return false
+		}
+		for idx := range a.Params {
+			if !sameType(a.Params[idx], b.Params[idx]) {
+				return false
+			}
+		}
+		return sameType(orAny(a.Return), orAny(b.Return))
+	case ClassType, InstanceType:
+		return a.Class == b.Class
+	}
+	return true
+}
+
+// joinTypes is a type covering values of both a and b, where nil is a type
+// that hasn't been worked out yet
+func joinTypes(a *Type, b *Type) *Type {
+	switch {
+	case a == nil:
+		return b
+	case b == nil || sameType(a, b):
+		return a
+	}
+	return anyType
+}
+
+// assignable reports whether a value of type value can go where a target
+// type is expected, anything the checker isn't sure of can
+func assignable(target *Type, value *Type) bool {
+	target, value = orAny(target), orAny(value)
+	if target.Kind == AnyType || value.Kind == AnyType {
+		return true
+	}
+	if target.Kind != value.Kind {
+		return false
+	}
+	switch target.Kind {
+	case FunctionType:
+		return len(target.Params) == len(value.Params)
+	case ClassType, InstanceType:
+		// Instances of a subclass can go where its superclass's are
+		// expected. Redeclared classes can inherit from each other in a
+		// loop, so the chain is only followed until it repeats.
+		seen := make(map[*Type]bool)
+		for ; value != nil && !seen[value]; value = value.Superclass {
+			if value.Kind == AnyType || value.Class == target.Class {
+				return true
+			}
+			seen[value] = true
+		}
+		return false
+	}
+	return true
+}
+
+// CheckTypes infers the types in the program and reports where they're
+// sure to be wrong when it runs, along with type annotations that don't name
+// a type. Anything without annotations is only reported when every way it
+// could run goes wrong, so code that runs without errors passes.
+func (a *Analysis) CheckTypes() []Diagnostic {
+	return checkTypes(a.Statements)
+}
+
+// SetTypeChecking has the interpreter check the types in programs before
+// running them, reporting anything that's sure to go wrong as a
+// *SyntaxError.
+func (i *Interpreter) SetTypeChecking(on bool) {
+	i.typeChecking = on
+}
+
+// maxTypePasses is how many times the checker goes over a program working
+// out types before giving up on finding any more
+const maxTypePasses = 10
+
+// checkTypes goes over statements until what it knows of the types stops
+// changing, then once more to report what's wrong
+func checkTypes(statements []Stmt) []Diagnostic {
+	return inferTypes(statements, nil)
+}
+
+// inferTypes is checkTypes, filling in types with the type of each
+// expression as well when it isn't nil
+func inferTypes(statements []Stmt, types map[Expr]*Type) []Diagnostic {
+	return newTypeChecker(types).infer(statements)
+}
+
+// inferValueTypes is inferTypes ignoring type annotations, so every type
+// comes from values the program uses. An annotation can be wrong, like on a
+// variable declared without a value, which holds nil until it gets one.
+func inferValueTypes(statements []Stmt, types map[Expr]*Type) {
+	c := newTypeChecker(types)
+	c.ignoreAnnotations = true
+	c.infer(statements)
+}
+
+func newTypeChecker(types map[Expr]*Type) *typeChecker {
+	return &typeChecker{
+		globals:   make(map[string]*typedVariable),
+		locals:    make(map[Token]*typedVariable),
+		functions: make(map[*FunctionStmt]*Type),
+		classes:   make(map[string]*Type),
+		types:     types,
+	}
+}
+
+func (c *typeChecker) infer(statements []Stmt) []Diagnostic {
+	for pass := 0; pass < maxTypePasses; pass++ {
+		c.changed = false
+		c.checkStmts(statements)
+		if !c.changed {
+			break
+		}
+	}
+	c.report = true
+	c.checkStmts(statements)
+	return c.diagnostics
+}
+
+// typeChecker infers the types of a program's variables and expressions.
+// It is an ExprVisitor, each visit checks an expression and returns its type.
+type typeChecker struct {
+	// globals are found by name, redeclaring one adds to its types
+	globals map[string]*typedVariable
+	scopes  []map[string]*typedVariable
+	// locals keeps what's known about local variables from pass to pass,
+	// by the token declaring them
+	locals map[Token]*typedVariable
+	// functions holds the type of each function, which calls are checked
+	// against, and classes each class's by name
+	functions map[*FunctionStmt]*Type
+	classes   map[string]*Type
+	function  *typedFunction
+	class     string
+	// changed is set when a pass learns something new
+	changed     bool
+	report      bool
+	diagnostics []Diagnostic
+	// types gets the type of each expression in the reporting pass
+	types map[Expr]*Type
+	// ignoreAnnotations treats every annotation as missing
+	ignoreAnnotations bool
+}
+
+// typedVariable is a variable, with the type it was declared with or the
+// types of every value assigned to it
+type typedVariable struct {
+	declared *Type
+	inferred *Type
+}
+
+func (v *typedVariable) typeOf() *Type {
+	if v.declared != nil {
+		return v.declared
+	}
+	return orAny(v.inferred)
+}
+
+// typedFunction is the function whose body is being checked
+type typedFunction struct {
+	stmt        *FunctionStmt
+	declared    *Type
+	initializer bool
+	returns     *Type
+}
+
+func (c *typeChecker) error(token Token, message string) {
+	if c.report {
+		c.diagnostics = append(c.diagnostics, Diagnostic{token, message})
+	}
+}
+
+func (c *typeChecker) declare(name Token, declared *Type) *typedVariable {
+	if len(c.scopes) == 0 {
+		variable, ok := c.globals[name.Lexeme]
+		if !ok {
+			variable = &typedVariable{}
+			c.globals[name.Lexeme] = variable
+		}
+		if declared != nil {
+			variable.declared = declared
+		}
+		return variable
+	}
+	variable, ok := c.locals[name]
+	if !ok {
+		variable = &typedVariable{declared: declared}
+		c.locals[name] = variable
+	}
+	c.scopes[len(c.scopes)-1][name.Lexeme] = variable
+	return variable
+}
+
+// lookUp finds a variable by name, returning nil for a global the program
+// doesn't declare, like a native function
+func (c *typeChecker) lookUp(name string) *typedVariable {
+	for idx := len(c.scopes) - 1; idx >= 0; idx-- {
+		if variable, ok := c.scopes[idx][name]; ok {
+			return variable
+		}
+	}
+	return c.globals[name]
+}
+
+// assign checks a value of type value going into variable, or adds it to
+// the types the variable has held when it wasn't declared with one
+func (c *typeChecker) assign(variable *typedVariable, name Token, value *Type) {
+	if variable == nil {
+		return
+	}
+	if variable.declared != nil {
+		if !assignable(variable.declared, value) {
+			c.error(name, fmt.Sprintf("Can't assign %s to '%s' of type %s", value, name.Lexeme, variable.declared))
+		}
+		return
+	}
+	joined := joinTypes(variable.inferred, value)
+	if !sameType(joined, variable.inferred) {
+		variable.inferred = joined
+		c.changed = true
+	}
+}
+
+// annotated returns the type an annotation names, nil when there isn't one
+func (c *typeChecker) annotated(typeName Token) *Type {
+	if c.ignoreAnnotations {
+		return nil
+	}
+	switch typeName.Lexeme {
+	case "":
+		return nil
+	case "number":
+		return numberType
+	case "string":
+		return stringType
+	case "bool":
+		return boolType
+	case "nil":
+		return nilType
+	}
+	if class, ok := c.classes[typeName.Lexeme]; ok {
+		return class.Return
+	}
+	c.error(typeName, fmt.Sprintf("Unknown type '%s'", typeName.Lexeme))
+	return anyType
+}
+
+func (c *typeChecker) beginScope() {
+	c.scopes = append(c.scopes, make(map[string]*typedVariable))
+}
+
+func (c *typeChecker) endScope() {
+	c.scopes = c.scopes[:len(c.scopes)-1]
+}
+
+func (c *typeChecker) checkStmts(statements []Stmt) {
+	for _, stmt := range statements {
+		c.checkStmt(stmt)
+	}
+}
+
+func (c *typeChecker) checkStmt(stmt Stmt) {
+	switch stmt := stmt.(type) {
+	case *ExpressionStmt:
+		c.checkExpr(stmt.Expression)
+	case *PrintStmt:
+		c.checkExpr(stmt.Expression)
+	case *VarStmt:
+		declared := c.annotated(stmt.Type)
+		value := nilType
+		if stmt.Initializer != nil {
+			value = c.checkExpr(stmt.Initializer)
+		}
+		variable := c.declare(stmt.Name, declared)
+		// A declaration without a value is waiting for one, it isn't nil
+		// for good
+		if stmt.Initializer != nil || declared == nil {
+			c.assign(variable, stmt.Name, value)
+		}
+	case *BlockStmt:
+		c.beginScope()
+		c.checkStmts(stmt.Statements)
+		c.endScope()
+	case *IfStmt:
+		c.checkExpr(stmt.Condition)
+		c.checkStmt(stmt.ThenBranch)
+		if stmt.ElseBranch != nil {
+			c.checkStmt(stmt.ElseBranch)
+		}
+	case *WhileStmt:
+		c.checkExpr(stmt.Condition)
+		c.checkStmt(stmt.Body)
+	case *FunctionStmt:
+		functionType := c.functionType(stmt)
+		c.assign(c.declare(stmt.Name, nil), stmt.Name, functionType)
+		c.checkFunction(stmt, functionType, false)
+	case *ReturnStmt:
+		value := nilType
+		if stmt.Value != nil {
+			value = c.checkExpr(stmt.Value)
+		}
+		c.checkReturn(stmt.Keyword, value)
+	case *ClassStmt:
+		c.checkClass(stmt)
+	}
+}
+
+// functionType returns the type of a function, the same one every pass so
+// the variable holding it doesn't look like it changed
+func (c *typeChecker) functionType(stmt *FunctionStmt) *Type {
+	functionType, ok := c.functions[stmt]
+	if !ok {
+		functionType = &Type{Kind: FunctionType}
+		c.functions[stmt] = functionType
+	}
+	functionType.Params = make([]*Type, len(stmt.Params))
+	for idx, paramType := range stmt.ParamTypes {
+		functionType.Params[idx] = orAny(c.annotated(paramType))
+	}
+	if declared := c.annotated(stmt.ReturnType); declared != nil {
+		functionType.Return = declared
+	}
+	return functionType
+}
+
+func (c *typeChecker) checkFunction(stmt *FunctionStmt, functionType *Type, initializer bool) {
+	enclosing := c.function
+	c.function = &typedFunction{stmt: stmt, initializer: initializer}
+	if stmt.ReturnType.Lexeme != "" {
+		c.function.declared = functionType.Return
+	}
+	c.beginScope()
+	for idx, param := range stmt.Params {
+		variable := c.declare(param, functionType.Params[idx])
+		variable.inferred = anyType
+	}
+	c.checkStmts(stmt.Body)
+	c.endScope()
+
+	if !alwaysReturns(stmt.Body) {
+		c.checkReturn(stmt.Name, nilType)
+	}
+	if c.function.declared == nil && !initializer {
+		returns := joinTypes(functionType.Return, c.function.returns)
+		if !sameType(returns, functionType.Return) {
+			functionType.Return = returns
+			c.changed = true
+		}
+	}
+	c.function = enclosing
+}
+
+// checkReturn checks a function returning a value of type value at token,
+// which is the function's name for the nil it returns by reaching its end
+func (c *typeChecker) checkReturn(token Token, value *Type) {
+	if c.function == nil || c.function.initializer {
+		return
+	}
+	c.function.returns = joinTypes(c.function.returns, value)
+	declared := c.function.declared
+	if declared == nil || assignable(declared, value) {
+		return
+	}
+	if token == c.function.stmt.Name {
+		c.error(token, fmt.Sprintf("Function '%s' can end without returning %s", token.Lexeme, declared))
+	} else {
+		c.error(token, fmt.Sprintf("Can't return %s from a function returning %s", value, declared))
+	}
+}
+
+// alwaysReturns reports whether statements are sure to end in a return, or
+// in a loop that never ends, so they never run past their end
+func alwaysReturns(statements []Stmt) bool {
+	for _, stmt := range statements {
+		switch stmt := stmt.(type) {
+		case *ReturnStmt:
+			return true
+		case *BlockStmt:
+			if alwaysReturns(stmt.Statements) {
+				return true
+			}
+		case *IfStmt:
+			if stmt.ElseBranch != nil && alwaysReturns([]Stmt{stmt.ThenBranch}) && alwaysReturns([]Stmt{stmt.ElseBranch}) {
+				return true
+			}
+		case *WhileStmt:
+			// Lox has no break, so only a return leaves a loop on true
+			if literal, ok := stmt.Condition.(*LiteralExpr); ok && isTruthy(literal.Value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c *typeChecker) checkClass(stmt *ClassStmt) {
+	classType, ok := c.classes[stmt.Name.Lexeme]
+	if !ok {
+		classType = &Type{Kind: ClassType, Class: stmt.Name.Lexeme, Return: &Type{Kind: InstanceType, Class: stmt.Name.Lexeme}}
+		c.classes[stmt.Name.Lexeme] = classType
+	}
+	c.assign(c.declare(stmt.Name, nil), stmt.Name, classType)
+	// Without an init of its own a class takes its superclass's
+	classType.Params = []*Type{}
+	classType.Superclass, classType.Return.Superclass = nil, nil
+	if stmt.Superclass != nil {
+		superclass := c.checkExpr(stmt.Superclass)
+		classType.Superclass, classType.Return.Superclass = anyType, anyType
+		if superclass.Kind == ClassType {
+			classType.Superclass, classType.Return.Superclass = superclass, superclass.Return
+		} else if superclass.Kind != AnyType {
+			c.error(stmt.Superclass.Name, "Superclass must be a class")
+		}
+		classType.Params = superclass.Params
+	}
+
+	enclosing := c.class
+	c.class = stmt.Name.Lexeme
+	for _, method := range stmt.Methods {
+		methodType := c.functionType(method)
+		initializer := method.Name.Lexeme == "init"
+		if initializer {
+			classType.Params = methodType.Params
+		}
+		c.checkFunction(method, methodType, initializer)
+	}
+	c.class = enclosing
+}
+
+func (c *typeChecker) checkExprs(exprs []Expr) {
+	for _, expr := range exprs {
+		c.checkExpr(expr)
+	}
+}
+
+// checkExpr returns the type of expr, reporting anything that's sure to go
+// wrong evaluating it
+func (c *typeChecker) checkExpr(expr Expr) *Type {
+	exprType := AcceptExpr[*Type](expr, c)
+	if c.report && c.types != nil {
+		c.types[expr] = exprType
+	}
+	return exprType
+}
+
+func (c *typeChecker) VisitLiteralExpr(expr *LiteralExpr) *Type {
+	switch expr.Value.(type) {
+	case float64:
+		return numberType
+	case string:
+		return stringType
+	case bool:
+		return boolType
+	case nil:
+		return nilType
+	}
+	return anyType
+}
+
+func (c *typeChecker) VisitGroupingExpr(expr *GroupingExpr) *Type {
+	return c.checkExpr(expr.Expression)
+}
+
+func (c *typeChecker) VisitUnaryExpr(expr *UnaryExpr) *Type {
+	right := c.checkExpr(expr.Right)
+	switch expr.Operator.Ttype {
+	case Bang:
+		return boolType
+	case Minus:
+		if !assignable(numberType, right) {
+			c.error(expr.Operator, "Operand must be a number")
+		}
+		return numberType
+	}
+	return anyType
+}
+
+func (c *typeChecker) VisitVariableExpr(expr *VariableExpr) *Type {
+	if variable := c.lookUp(expr.Name.Lexeme); variable != nil {
+		return variable.typeOf()
+	}
+	return anyType
+}
+
+func (c *typeChecker) VisitAssignExpr(expr *AssignExpr) *Type {
+	value := c.checkExpr(expr.Value)
+	c.assign(c.lookUp(expr.Name.Lexeme), expr.Name, value)
+	return value
+}
+
+func (c *typeChecker) VisitLogicalExpr(expr *LogicalExpr) *Type {
+	return orAny(joinTypes(c.checkExpr(expr.Left), c.checkExpr(expr.Right)))
+}
+
+func (c *typeChecker) VisitConditionalExpr(expr *ConditionalExpr) *Type {
+	c.checkExpr(expr.Condition)
+	return orAny(joinTypes(c.checkExpr(expr.Then), c.checkExpr(expr.Else)))
+}
+
+func (c *typeChecker) VisitCommaExpr(expr *CommaExpr) *Type {
+	c.checkExpr(expr.Left)
+	return c.checkExpr(expr.Right)
+}
+
+func (c *typeChecker) VisitGetExpr(expr *GetExpr) *Type {
+	if isPrimitive(c.checkExpr(expr.Object)) {
+		c.error(expr.Name, "Only instances, lists and maps have properties")
+	}
+	return anyType
+}
+
+func (c *typeChecker) VisitSetExpr(expr *SetExpr) *Type {
+	if isPrimitive(c.checkExpr(expr.Object)) {
+		c.error(expr.Name, "Only instances have fields")
+	}
+	return c.checkExpr(expr.Value)
+}
+
+func (c *typeChecker) VisitThisExpr(expr *ThisExpr) *Type {
+	if class, ok := c.classes[c.class]; ok {
+		return class.Return
+	}
+	return anyType
+}
+
+func (c *typeChecker) VisitUnknownExpr(expr *UnknownExpr) *Type {
+	return c.checkChildren(expr)
+}
+
+func (c *typeChecker) VisitSuperExpr(expr *SuperExpr) *Type {
+	return c.checkChildren(expr)
+}
+
+func (c *typeChecker) VisitListExpr(expr *ListExpr) *Type {
+	return c.checkChildren(expr)
+}
+
+func (c *typeChecker) VisitMapExpr(expr *MapExpr) *Type {
+	return c.checkChildren(expr)
+}
+
+func (c *typeChecker) VisitIndexExpr(expr *IndexExpr) *Type {
+	return c.checkChildren(expr)
+}
+
+func (c *typeChecker) VisitIndexSetExpr(expr *IndexSetExpr) *Type {
+	return c.checkChildren(expr)
+}
+
+func (c *typeChecker) VisitPostfixExpr(expr *PostfixExpr) *Type {
+	return c.checkChildren(expr)
+}
+
+// checkChildren checks the operands of an expression whose own type isn't
+// tracked
+func (c *typeChecker) checkChildren(expr Expr) *Type {
+	c.checkExprs(expr.Children())
+	return anyType
+}
+
+func isPrimitive(t *Type) bool {
+	switch t.Kind {
+	case NumberType, StringType, BoolType, NilType:
+		return true
+	}
+	return false
+}
+
+func (c *typeChecker) VisitBinaryExpr(expr *BinaryExpr) *Type {
+	left := c.checkExpr(expr.Left)
+	right := c.checkExpr(expr.Right)
+	switch expr.Operator.Ttype {
+	case EqualEqual, BangEqual:
+		return boolType
+	case Plus:
+		switch {
+		case left.Kind == AnyType && right.Kind == AnyType:
+			return anyType
+		case assignable(numberType, left) && assignable(numberType, right):
+			return numberType
+		case assignable(stringType, left) && assignable(stringType, right):
+			return stringType
+		}
+		c.error(expr.Operator, "Operands must be two numbers or two strings")
+		return anyType
+	case Minus, Slash, Star, Greater, GreaterEqual, Less, LessEqual:
+		if !assignable(numberType, left) || !assignable(numberType, right) {
+			c.error(expr.Operator, "Operands must be numbers")
+		}
+		switch expr.Operator.Ttype {
+		case Minus, Slash, Star:
+			return numberType
+		}
+		return boolType
+	}
+	return anyType
+}
+
+func (c *typeChecker) VisitCallExpr(expr *CallExpr) *Type {
+	callee := c.checkExpr(expr.Callee)
+	arguments := make([]*Type, len(expr.Arguments))
+	for idx, argument := range expr.Arguments {
+		arguments[idx] = c.checkExpr(argument)
+	}
+	switch callee.Kind {
+	case FunctionType, ClassType:
+	case AnyType:
+		return anyType
+	default:
+		c.error(expr.Paren, "Can only call functions and classes")
+		return anyType
+	}
+
+	if callee.Params == nil {
+		return orAny(callee.Return)
+	}
+	if len(arguments) != len(callee.Params) {
+		c.error(expr.Paren, fmt.Sprintf("Expected %d arguments but got %d", len(callee.Params), len(arguments)))
+	} else {
+		for idx, argument := range arguments {
+			if !assignable(callee.Params[idx], argument) {
+				c.error(expr.Arguments[idx].Token(), fmt.Sprintf("Argument %d must be %s, not %s", idx+1, callee.Params[idx], argument))
+			}
+		}
+	}
+	return orAny(callee.Return)
+}
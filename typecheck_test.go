@@ -0,0 +1,127 @@
+package golox
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// runChecked runs source with type checking on, returning what it printed
+func runChecked(source string) (string, error) {
+	var out strings.Builder
+	interpreter := NewInterpreter(&out)
+	interpreter.SetTypeChecking(true)
+	err := interpreter.Run(source)
+	return out.String(), err
+}
+
+func TestTypeCheckingPasses(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+	}{
+		{"arithmetic", "print 1 + 2 * 3; print \"a\" + \"b\";"},
+		{"unannotated variables", "var a = 1; a = \"now a string\"; print a + \"!\";"},
+		{"unannotated function", "fun add(a, b) { return a + b; } print add(1, 2); print add(\"a\", \"b\");"},
+		{"annotations", "var n: number = 1; fun f(s: string): string { return s + \"!\"; } print f(\"hi\") + \"\"; print n * 2;"},
+		{"nil annotation", "var x: nil = nil; print x;"},
+		{"annotated variable without a value", "var x: number; x = 2; print x;"},
+		{"subclass where a superclass is expected", "class A {} class B < A {} var a: A = B(); print a;"},
+		{"subclass of a subclass", "class A {} class B < A {} class C < B {} fun f(a: A): A { return a; } print f(C());"},
+		{"this in a subclass", "class A {} class B < A { me(): A { return this; } } print B().me();"},
+		{"return from an endless loop", "fun f(): number { while (true) { return 1; } } print f();"},
+		{"return from an endless for loop", "fun f(): number { for (;;) { return 2; } } print f();"},
+		{"return on every branch", "fun f(n): number { if (n > 1) return 1; else { return 2; } } print f(3);"},
+		{"recursion", "fun fib(n) { if (n < 2) return n; return fib(n - 1) + fib(n - 2); } print fib(10) - 1;"},
+		{"mutual recursion across passes", "fun isEven(n) { if (n == 0) return true; return isOdd(n - 1); }\nfun isOdd(n) { if (n == 0) return false; return isEven(n - 1); }\nprint isEven(10);"},
+		{"used before it's declared", "fun f() { return g() * 2; } fun g() { return 21; } print f();"},
+		{"classes", "class P { init(x) { this.x = x; } get() { return this.x; } } print P(3).get() + 1;"},
+		{"lists and maps", "var xs = [1, \"a\"]; var m = {\"k\": xs}; print m[\"k\"][0] + 1;"},
+		{"native functions", "print len(\"abc\") + 1; print clock() > 0;"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			want, err := runLox(t, test.source)
+			if err != nil {
+				t.Fatalf("Fails without type checking: %v", err)
+			}
+			got, err := runChecked(test.source)
+			if err != nil {
+				t.Fatalf("Fails type checking: %v", err)
+			}
+			if got != want {
+				t.Errorf("Printed %q with type checking, %q without", got, want)
+			}
+		})
+	}
+}
+
+func TestTypeCheckingReports(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		errors []string
+	}{
+		{"subtracting from a string", "print \"a\" - 1;", []string{"Error on line 1 at '-': Operands must be numbers"}},
+		{"negating a string", "print -\"a\";", []string{"Error on line 1 at '-': Operand must be a number"}},
+		{"adding a number to a string", "var s = \"a\"; print s + 1;", []string{"Error on line 1 at '+': Operands must be two numbers or two strings"}},
+		{"too few arguments", "fun f(a, b) { return a; } f(1);", []string{"Error on line 1 at ')': Expected 2 arguments but got 1"}},
+		{"too many arguments to a class", "class P { init(x) {} } P(1, 2);", []string{"Error on line 1 at ')': Expected 1 arguments but got 2"}},
+		{"calling a number", "var n = 1; n();", []string{"Error on line 1 at ')': Can only call functions and classes"}},
+		{"wrong variable annotation", "var n: number = \"x\";", []string{"Error on line 1 at 'n': Can't assign string to 'n' of type number"}},
+		{"wrong argument", "fun f(n: number) { return n; } f(\"x\");", []string{"Error on line 1 at '\"x\"': Argument 1 must be number, not string"}},
+		{"wrong return", "fun f(): string { return 1; }", []string{"Error on line 1 at 'return': Can't return number from a function returning string"}},
+		{"missing return", "fun f(n): number { if (n) return 1; }", []string{"Error on line 1 at 'f': Function 'f' can end without returning number"}},
+		{"loop that can end", "fun f(n): number { while (n) { return 1; } }", []string{"Error on line 1 at 'f': Function 'f' can end without returning number"}},
+		{"unknown type", "var n: numbr = 1;", []string{"Error on line 1 at 'numbr': Unknown type 'numbr'"}},
+		{"superclass where a subclass is expected", "class A {} class B < A {} var b: B = A();", []string{"Error on line 1 at 'b': Can't assign A to 'b' of type B"}},
+		{"unrelated class", "class A {} class B {} var a: A = B();", []string{"Error on line 1 at 'a': Can't assign B to 'a' of type A"}},
+		{"property of a number", "var n = 1; print n.x;", []string{"Error on line 1 at 'x': Only instances, lists and maps have properties"}},
+		{"type learned in a later pass", "fun f() { return s - 1; }\nvar s = \"s\";\nf();", []string{"Error on line 1 at '-': Operands must be numbers"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := runChecked(test.source)
+			syntaxErr, ok := err.(*SyntaxError)
+			if !ok {
+				t.Fatalf("Returned %v, want a *SyntaxError", err)
+			}
+			if !reflect.DeepEqual(syntaxErr.Messages, test.errors) {
+				t.Errorf("Reported %q, want %q", syntaxErr.Messages, test.errors)
+			}
+		})
+	}
+}
+
+func TestTypeAnnotationsParse(t *testing.T) {
+	source := "var n: number = 1;\nvar s: string;\nfun f(a: string, b): bool { return a == b; }\nclass A { m(x: A): A { return this; } }\n"
+	var errors []string
+	displayError := func(message string) {
+		errors = append(errors, message)
+	}
+	p := parser{tokens: RunScanner(source, displayError), displayError: displayError}
+	statements, _ := p.parseProgram()
+	if len(errors) > 0 {
+		t.Fatal(errors)
+	}
+	want := "(var n: number 1)\n(var s: string)\n(fun f (a: string b): bool\n  (return (== a b)))\n(class A\n  (fun m (x: A): A\n    (return this)))"
+	if got := FormatStmts(statements); got != want {
+		t.Errorf("Parsed as\n%s\nwant\n%s", got, want)
+	}
+
+	// Annotations are only checked when asked, the program runs the same
+	// without them
+	annotated := "var n: number = \"not a number\"; fun f(a: bool): nil { return a; } print f(n);"
+	plain := "var n = \"not a number\"; fun f(a) { return a; } print f(n);"
+	got, err := runLox(t, annotated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err = runLox(t, plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("Printed %q with annotations, %q without", got, want)
+	}
+}
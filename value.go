@@ -0,0 +1,134 @@
+package golox
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// ToLox converts a Go value into the value Lox code sees. Numbers of any Go
+// numeric type become float64, maps with string keys become instances whose
+// fields are the map's entries, and NativeFuncs become callable functions.
+// Values that are already Lox values are returned unchanged.
+func ToLox(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case nil, bool, string, float64:
+		return v, nil
+	case int:
+		return float64(v), nil
+	case int8:
+		return float64(v), nil
+	case int16:
+		return float64(v), nil
+	case int32:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case uint:
+		return float64(v), nil
+	case uint8:
+		return float64(v), nil
+	case uint16:
+		return float64(v), nil
+	case uint32:
+		return float64(v), nil
+	case uint64:
+		return float64(v), nil
+	case float32:
+		return float64(v), nil
+	case NativeFunc:
+		return &nativeFunction{"native", -1, v}, nil
+	case func([]interface{}) (interface{}, error):
+		return &nativeFunction{"native", -1, v}, nil
+	case []interface{}:
+		list := &loxList{make([]interface{}, len(v))}
+		for i, element := range v {
+			converted, err := ToLox(element)
+			if err != nil {
+				return nil, err
+			}
+			list.elements[i] = converted
+		}
+		return list, nil
+	case map[string]interface{}:
+		instance := &loxInstance{&loxClass{name: "Object"}, make(map[string]interface{})}
+		for name, field := range v {
+			converted, err := ToLox(field)
+			if err != nil {
+				return nil, err
+			}
+			instance.fields[name] = converted
+		}
+		return instance, nil
+	case callable, *loxInstance, *loxList, *loxMap:
+		return v, nil
+	}
+	return nil, fmt.Errorf("Can't convert %T to a Lox value", value)
+}
+
+// ToGo converts a Lox value into a plain Go value. Instances become maps of
+// their fields, lists become slices and maps become maps keyed by the Lox
+// keys. Everything else is returned as is, functions and classes are opaque
+// and can only be passed back to the interpreter.
+func ToGo(value interface{}) interface{} {
+	return toGo(value, make(map[interface{}]interface{}))
+}
+
+// seen lets values that refer back to themselves convert to Go values that
+// do the same instead of recursing forever
+func toGo(value interface{}, seen map[interface{}]interface{}) interface{} {
+	if converted, ok := seen[value]; ok {
+		return converted
+	}
+	switch v := value.(type) {
+	case *loxInstance:
+		fields := make(map[string]interface{}, len(v.fields))
+		seen[v] = fields
+		for name, field := range v.fields {
+			fields[name] = toGo(field, seen)
+		}
+		return fields
+	case *loxList:
+		elements := make([]interface{}, len(v.elements))
+		seen[v] = elements
+		for i, element := range v.elements {
+			elements[i] = toGo(element, seen)
+		}
+		return elements
+	case *loxMap:
+		entries := make(map[interface{}]interface{}, len(v.entries))
+		seen[v] = entries
+		for key, entry := range v.entries {
+			entries[key] = toGo(entry, seen)
+		}
+		return entries
+	}
+	return value
+}
+
+func isTruthy(value interface{}) bool {
+	if value == nil {
+		return false
+	}
+	if b, ok := value.(bool); ok {
+		return b
+	}
+	return true
+}
+
+func isEqual(a interface{}, b interface{}) bool {
+	return a == b
+}
+
+func stringify(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "nil"
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		return v
+	case fmt.Stringer:
+		return v.String()
+	}
+	return fmt.Sprint(value)
+}